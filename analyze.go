@@ -0,0 +1,327 @@
+package SpecSmash
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// SpecIssue is a problem found by AnalyzeSpec.
+// Pointer is the JSON pointer of the offending schema within the document.
+type SpecIssue struct {
+	Pointer string
+	Message string
+}
+
+func (i SpecIssue) String() string {
+	return i.Pointer + ": " + i.Message
+}
+
+// formatTypes lists, for every format we know about, the types it may be used with.
+// Formats not in this table are not checked.
+var formatTypes = map[string][]string{
+	"uuid":                  {"string"},
+	"date-time":             {"string"},
+	"date":                  {"string"},
+	"email":                 {"string"},
+	"hostname":              {"string"},
+	"ipv4":                  {"string"},
+	"ipv6":                  {"string"},
+	"uri":                   {"string"},
+	"uri-reference":         {"string"},
+	"byte":                  {"string"},
+	"binary":                {"string"},
+	"password":              {"string"},
+	"time":                  {"string"},
+	"duration":              {"string"},
+	"regex":                 {"string"},
+	"json-pointer":          {"string"},
+	"iri":                   {"string"},
+	"iri-reference":         {"string"},
+	"uri-template":          {"string"},
+	"idn-email":             {"string"},
+	"idn-hostname":          {"string"},
+	"relative-json-pointer": {"string"},
+	"color":                 {"string"},
+	"hex-color":             {"string"},
+	"iban":                  {"string"},
+	"bic":                   {"string"},
+	"json":                  {"string"},
+
+	// int32/int64 are also used on strings carrying string-encoded integers
+	"int32":  {"integer", "number", "string"},
+	"int64":  {"integer", "number", "string"},
+	"uint64": {"integer", "number", "string"},
+	"float":  {"number"},
+	"double": {"number"},
+}
+
+// AnalyzeSpec walks every schema in the document, see walkDocSchemas, and reports problems that would
+// make generation silently produce something other than what the spec author intended.
+func AnalyzeSpec(doc *openapi3.T) []SpecIssue {
+	var issues []SpecIssue
+	check := func(schema *openapi3.Schema, pointer string) {
+		issues = append(issues, checkTypeFormat(schema, pointer)...)
+		issues = append(issues, checkBounds(schema, pointer)...)
+		issues = append(issues, checkEnum(schema, pointer)...)
+	}
+
+	walkDocSchemas(doc, check)
+	return issues
+}
+
+func checkTypeFormat(schema *openapi3.Schema, pointer string) []SpecIssue {
+	if schema.Format == "" || schema.Type == nil || len(*schema.Type) == 0 {
+		return nil
+	}
+	allowed, known := formatTypes[schema.Format]
+	if !known {
+		return nil
+	}
+
+	var issues []SpecIssue
+	for _, typ := range *schema.Type {
+		if !contains(allowed, typ) {
+			issues = append(issues, SpecIssue{
+				Pointer: pointer,
+				Message: fmt.Sprintf("format '%s' is not compatible with type '%s'", schema.Format, typ),
+			})
+		}
+	}
+	return issues
+}
+
+// checkBounds reports lower bounds above their upper bound, which leave no valid value
+func checkBounds(schema *openapi3.Schema, pointer string) []SpecIssue {
+	var issues []SpecIssue
+	inverted := func(minName string, minimum uint64, maxName string, maximum *uint64) {
+		if maximum != nil && *maximum < minimum {
+			issues = append(issues, SpecIssue{
+				Pointer: pointer,
+				Message: fmt.Sprintf("%s %d is less than %s %d", maxName, *maximum, minName, minimum),
+			})
+		}
+	}
+	inverted("minLength", schema.MinLength, "maxLength", schema.MaxLength)
+	inverted("minItems", schema.MinItems, "maxItems", schema.MaxItems)
+	inverted("minProperties", schema.MinProps, "maxProperties", schema.MaxProps)
+
+	if schema.Min != nil && schema.Max != nil && (*schema.Max < *schema.Min ||
+		*schema.Max == *schema.Min && (schema.ExclusiveMin || schema.ExclusiveMax)) {
+		issues = append(issues, SpecIssue{
+			Pointer: pointer,
+			Message: fmt.Sprintf("no number lies between minimum %v and maximum %v", *schema.Min, *schema.Max),
+		})
+	}
+	return issues
+}
+
+// checkEnum reports enum members listed more than once, and members that violate
+// the schema's other keywords, which generation may pick but validation rejects
+func checkEnum(schema *openapi3.Schema, pointer string) []SpecIssue {
+	if len(schema.Enum) == 0 {
+		return nil
+	}
+	withoutEnum := *schema
+	withoutEnum.Enum = nil
+
+	var issues []SpecIssue
+	seen := make(map[string]bool, len(schema.Enum))
+	for _, member := range schema.Enum {
+		encoded := string(marshal(member))
+		if seen[encoded] {
+			issues = append(issues, SpecIssue{
+				Pointer: pointer,
+				Message: fmt.Sprintf("enum member %s is listed more than once", encoded),
+			})
+			continue
+		}
+		seen[encoded] = true
+
+		if err := withoutEnum.VisitJSON(member); err != nil {
+			reason := err.Error()
+			var schemaErr *openapi3.SchemaError
+			if errors.As(err, &schemaErr) {
+				reason = schemaErr.Reason
+			}
+			issues = append(issues, SpecIssue{
+				Pointer: pointer,
+				Message: fmt.Sprintf("enum member %s does not satisfy the schema: %s", encoded, reason),
+			})
+		}
+	}
+	return issues
+}
+
+// ---------------- Schema Walking ----------------
+
+// walkDocSchemas calls visit for every schema of the document: those defined in
+// components, and the schemas of parameters, request bodies, responses and their
+// headers, in components and in the path items and operations of paths and webhooks.
+// Referenced components are only visited at their definition, so every schema is
+// reported under one pointer.
+func walkDocSchemas(doc *openapi3.T, visit func(schema *openapi3.Schema, pointer string)) {
+	if doc.Components != nil {
+		for _, name := range sortedKeys(doc.Components.Schemas) {
+			walkSchema(doc.Components.Schemas[name], "/components/schemas/"+escapePointer(name), visit)
+		}
+		for _, name := range sortedKeys(doc.Components.Parameters) {
+			walkParameter(doc.Components.Parameters[name], "/components/parameters/"+escapePointer(name), visit)
+		}
+		for _, name := range sortedKeys(doc.Components.RequestBodies) {
+			body := doc.Components.RequestBodies[name]
+			if body == nil || body.Ref != "" || body.Value == nil {
+				continue
+			}
+			walkContent(body.Value.Content, "/components/requestBodies/"+escapePointer(name), visit)
+		}
+		for _, name := range sortedKeys(doc.Components.Responses) {
+			walkResponse(doc.Components.Responses[name], "/components/responses/"+escapePointer(name), visit)
+		}
+		for _, name := range sortedKeys(doc.Components.Headers) {
+			walkHeader(doc.Components.Headers[name], "/components/headers/"+escapePointer(name), visit)
+		}
+	}
+
+	for _, located := range pathItems(doc) {
+		for i, param := range located.item.Parameters {
+			walkParameter(param, located.pointer+"/parameters/"+strconv.Itoa(i), visit)
+		}
+		ops := located.item.Operations()
+		for _, method := range sortedKeys(ops) {
+			op := ops[method]
+			pointer := located.pointer + "/" + strings.ToLower(method)
+			for i, param := range op.Parameters {
+				walkParameter(param, pointer+"/parameters/"+strconv.Itoa(i), visit)
+			}
+			if body := resolveRequestBody(op, doc); body != nil && op.RequestBody.Ref == "" {
+				walkContent(body.Content, pointer+"/requestBody", visit)
+			}
+			if op.Responses == nil {
+				continue
+			}
+			responses := op.Responses.Map()
+			for _, status := range sortedKeys(responses) {
+				walkResponse(responses[status], pointer+"/responses/"+escapePointer(status), visit)
+			}
+		}
+	}
+}
+
+// walkParameter walks the schema of a parameter, or of its content
+func walkParameter(ref *openapi3.ParameterRef, pointer string, visit func(schema *openapi3.Schema, pointer string)) {
+	if ref == nil || ref.Ref != "" || ref.Value == nil {
+		return
+	}
+	walkSchema(ref.Value.Schema, pointer+"/schema", visit)
+	walkContent(ref.Value.Content, pointer, visit)
+}
+
+// walkResponse walks the schemas of a response's content and headers
+func walkResponse(ref *openapi3.ResponseRef, pointer string, visit func(schema *openapi3.Schema, pointer string)) {
+	if ref == nil || ref.Ref != "" || ref.Value == nil {
+		return
+	}
+	walkContent(ref.Value.Content, pointer, visit)
+	for _, name := range sortedKeys(ref.Value.Headers) {
+		walkHeader(ref.Value.Headers[name], pointer+"/headers/"+escapePointer(name), visit)
+	}
+}
+
+// walkHeader walks the schema of a header, or of its content
+func walkHeader(ref *openapi3.HeaderRef, pointer string, visit func(schema *openapi3.Schema, pointer string)) {
+	if ref == nil || ref.Ref != "" || ref.Value == nil {
+		return
+	}
+	walkSchema(ref.Value.Schema, pointer+"/schema", visit)
+	walkContent(ref.Value.Content, pointer, visit)
+}
+
+// walkContent walks the schema of every media type in content
+func walkContent(content openapi3.Content, pointer string, visit func(schema *openapi3.Schema, pointer string)) {
+	for _, mediaType := range sortedKeys(content) {
+		if media := content[mediaType]; media != nil {
+			walkSchema(media.Schema, pointer+"/content/"+escapePointer(mediaType)+"/schema", visit)
+		}
+	}
+}
+
+func walkSchema(ref *openapi3.SchemaRef, pointer string, visit func(schema *openapi3.Schema, pointer string)) {
+	if ref == nil || ref.Value == nil || ref.Ref != "" {
+		return
+	}
+	schema := ref.Value
+	visit(schema, pointer)
+
+	for _, name := range sortedKeys(schema.Properties) {
+		walkSchema(schema.Properties[name], pointer+"/properties/"+escapePointer(name), visit)
+	}
+	walkSchema(schema.Items, pointer+"/items", visit)
+	walkSchema(schema.AdditionalProperties.Schema, pointer+"/additionalProperties", visit)
+	walkSchema(schema.Not, pointer+"/not", visit)
+	for i, sub := range schema.AllOf {
+		walkSchema(sub, pointer+"/allOf/"+strconv.Itoa(i), visit)
+	}
+	for i, sub := range schema.AnyOf {
+		walkSchema(sub, pointer+"/anyOf/"+strconv.Itoa(i), visit)
+	}
+	for i, sub := range schema.OneOf {
+		walkSchema(sub, pointer+"/oneOf/"+strconv.Itoa(i), visit)
+	}
+
+	// subschemas of the JSON Schema keywords kin-openapi keeps in the extensions
+	for _, name := range []string{"contains", "additionalItems", "unevaluatedProperties", "contentSchema", "propertyNames"} {
+		if raw, ok := keyword(schema, name); ok {
+			walkSchema(keywordSchema(raw), pointer+"/"+name, visit)
+		}
+	}
+	if raw, ok := keyword(schema, "prefixItems"); ok {
+		items, _ := raw.([]any)
+		for i, item := range items {
+			walkSchema(keywordSchema(item), pointer+"/prefixItems/"+strconv.Itoa(i), visit)
+		}
+	}
+	for _, name := range []string{"patternProperties", "dependentSchemas"} {
+		if raw, ok := keyword(schema, name); ok {
+			subs, _ := raw.(map[string]any)
+			for _, key := range sortedKeys(subs) {
+				walkSchema(keywordSchema(subs[key]), pointer+"/"+name+"/"+escapePointer(key), visit)
+			}
+		}
+	}
+}
+
+// keywordSchema converts a subschema of one of the schemaKeywords for walking. It
+// returns nil for references, which are visited at their definition, and for
+// subschemas that are not valid schemas.
+func keywordSchema(raw any) (ref *openapi3.SchemaRef) {
+	if m, ok := raw.(map[string]any); ok {
+		if _, isRef := m["$ref"]; isRef {
+			return nil
+		}
+	}
+	defer func() {
+		if recover() != nil {
+			ref = nil
+		}
+	}()
+	return &openapi3.SchemaRef{Value: subSchema(raw)}
+}
+
+// escapePointer escapes a single JSON pointer reference token (RFC 6901)
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
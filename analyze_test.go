@@ -0,0 +1,281 @@
+package SpecSmash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readSpecString(t *testing.T, spec string) *openapi3.T {
+	doc, err := ReadSpecFromReader(strings.NewReader(spec))
+	require.NoError(t, err)
+	return doc
+}
+
+func TestAnalyzeSpecTypeFormatMismatch(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: mismatch
+  version: 1.0.0
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                contact:
+                  type: integer
+                  format: email
+      responses:
+        '200':
+          description: ok
+components:
+  schemas:
+    User:
+      type: object
+      properties:
+        id:
+          type: string
+          format: uuid
+        age:
+          type: integer
+          format: int32
+        score:
+          type: boolean
+          format: double
+`)
+
+	issues := AnalyzeSpec(doc)
+
+	assert.Equal(t, []SpecIssue{
+		{
+			Pointer: "/components/schemas/User/properties/score",
+			Message: "format 'double' is not compatible with type 'boolean'",
+		},
+		{
+			Pointer: "/paths/~1users/post/requestBody/content/application~1json/schema/properties/contact",
+			Message: "format 'email' is not compatible with type 'integer'",
+		},
+	}, issues)
+}
+
+func TestAnalyzeSpecWalksEverySchema(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: everywhere
+  version: 1.0.0
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: boolean
+          format: uuid
+    get:
+      parameters:
+        - name: contact
+          in: query
+          schema:
+            type: integer
+            format: email
+        - $ref: '#/components/parameters/Page'
+      responses:
+        '200':
+          description: ok
+          headers:
+            X-Rate:
+              schema:
+                type: string
+                format: double
+          content:
+            application/json:
+              schema:
+                type: integer
+                format: date
+        '404':
+          $ref: '#/components/responses/NotFound'
+components:
+  parameters:
+    Page:
+      name: page
+      in: query
+      schema:
+        type: boolean
+        format: int32
+  responses:
+    NotFound:
+      description: not found
+      content:
+        application/json:
+          schema:
+            type: number
+            format: uri
+  schemas:
+    Keywords:
+      type: object
+      patternProperties:
+        "^x-":
+          type: integer
+          format: email
+      dependentSchemas:
+        a:
+          properties:
+            b:
+              type: boolean
+              format: date
+      properties:
+        tuple:
+          type: array
+          items: {}
+          prefixItems:
+            - type: integer
+              format: uuid
+          contains:
+            type: number
+            format: hostname
+`)
+
+	var pointers []string
+	for _, issue := range AnalyzeSpec(doc) {
+		pointers = append(pointers, issue.Pointer)
+	}
+	assert.Equal(t, []string{
+		"/components/schemas/Keywords/properties/tuple/contains",
+		"/components/schemas/Keywords/properties/tuple/prefixItems/0",
+		"/components/schemas/Keywords/patternProperties/^x-",
+		"/components/schemas/Keywords/dependentSchemas/a/properties/b",
+		"/components/parameters/Page/schema",
+		"/components/responses/NotFound/content/application~1json/schema",
+		"/paths/~1users~1{id}/parameters/0/schema",
+		"/paths/~1users~1{id}/get/parameters/0/schema",
+		"/paths/~1users~1{id}/get/responses/200/content/application~1json/schema",
+		"/paths/~1users~1{id}/get/responses/200/headers/X-Rate/schema",
+	}, pointers)
+}
+
+func TestAnalyzeSpecInvertedBounds(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: bounds
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Order:
+      type: object
+      minProperties: 3
+      maxProperties: 1
+      properties:
+        code:
+          type: string
+          minLength: 5
+          maxLength: 2
+        items:
+          type: array
+          minItems: 4
+          maxItems: 3
+          items:
+            type: string
+        quantity:
+          type: integer
+          minimum: 10
+          maximum: 10
+          exclusiveMaximum: true
+        total:
+          type: number
+          minimum: 1
+          maximum: 1
+`)
+
+	issues := AnalyzeSpec(doc)
+
+	assert.Equal(t, []SpecIssue{
+		{
+			Pointer: "/components/schemas/Order",
+			Message: "maxProperties 1 is less than minProperties 3",
+		},
+		{
+			Pointer: "/components/schemas/Order/properties/code",
+			Message: "maxLength 2 is less than minLength 5",
+		},
+		{
+			Pointer: "/components/schemas/Order/properties/items",
+			Message: "maxItems 3 is less than minItems 4",
+		},
+		{
+			Pointer: "/components/schemas/Order/properties/quantity",
+			Message: "no number lies between minimum 10 and maximum 10",
+		},
+	}, issues)
+}
+
+func TestAnalyzeSpecBadEnumMembers(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: enums
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Order:
+      type: object
+      properties:
+        status:
+          type: string
+          maxLength: 8
+          enum: [open, closed, cancelled, open]
+        quantity:
+          type: integer
+          minimum: 1
+          enum: [1, 2, 0, 2.5]
+        note:
+          type: string
+          nullable: true
+          enum: [fragile, null]
+`)
+
+	issues := AnalyzeSpec(doc)
+
+	assert.Equal(t, []SpecIssue{
+		{
+			Pointer: "/components/schemas/Order/properties/quantity",
+			Message: "enum member 0 does not satisfy the schema: number must be at least 1",
+		},
+		{
+			Pointer: "/components/schemas/Order/properties/quantity",
+			Message: "enum member 2.5 does not satisfy the schema: value must be an integer",
+		},
+		{
+			Pointer: "/components/schemas/Order/properties/status",
+			Message: "enum member \"cancelled\" does not satisfy the schema: maximum string length is 8",
+		},
+		{
+			Pointer: "/components/schemas/Order/properties/status",
+			Message: "enum member \"open\" is listed more than once",
+		},
+	}, issues)
+}
+
+func TestAnalyzeSpecTestdataIsClean(t *testing.T) {
+	for _, specPath := range []string{
+		"testdata/openapi_simple.yaml",
+		"testdata/openapi_comprehensive.yaml",
+		"testdata/openapi_ultra_comprehensive.yaml",
+	} {
+		doc, err := ReadSpec(specPath)
+		require.NoError(t, err)
+		assert.Empty(t, AnalyzeSpec(doc), specPath)
+	}
+}
@@ -0,0 +1,73 @@
+package SpecSmash
+
+import (
+	"flag"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"pgregory.net/rapid"
+)
+
+// rapidFlagsMu serializes the changes CheckNoShrink and Check make to rapid's flags
+var rapidFlagsMu sync.Mutex
+
+// withRapidFlag runs f with rapid's flag name set to value, and restores it after
+func withRapidFlag(name, value string, f func()) {
+	rapidFlagsMu.Lock()
+	defer rapidFlagsMu.Unlock()
+
+	rapidFlag := flag.Lookup(name)
+	previous := rapidFlag.Value.String()
+	if err := rapidFlag.Value.Set(value); err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := rapidFlag.Value.Set(previous); err != nil {
+			panic(err)
+		}
+	}()
+	f()
+}
+
+// CheckNoShrink is rapid.Check without shrinking: the first failing case is
+// reported as generated, without the time rapid spends on minimizing it, which
+// can be long for complex schemas. Use it while debugging; rapid.Check reports
+// smaller, easier to read counterexamples.
+//
+// Shrinking is disabled through rapid's global -rapid.shrinktime flag, which is
+// restored afterwards. rapid.Check calls running meanwhile would not shrink
+// either, so do not use CheckNoShrink in tests that call t.Parallel.
+func CheckNoShrink(t rapid.TB, prop func(*rapid.T)) {
+	t.Helper()
+	withRapidFlag("rapid.shrinktime", "0s", func() {
+		rapid.Check(t, prop)
+	})
+}
+
+// SeedFromString hashes s, e.g. "bug-1234", into a rapid seed. The same string
+// always gives the same seed.
+func SeedFromString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	if seed := h.Sum64(); seed != 0 {
+		return seed
+	}
+	// rapid treats seed 0 as "pick a random one"
+	return 1
+}
+
+// Check is rapid.Check, seeded with Seed if it is set, so the draws of prop are
+// the same in every run. Like -rapid.seed, which it sets while prop runs, the
+// seed applies to rapid.Check calls running meanwhile too, so do not use a
+// seeded Check in tests that call t.Parallel.
+func (opts *GenerationOptions) Check(t rapid.TB, prop func(*rapid.T)) {
+	t.Helper()
+	if opts.Seed == 0 {
+		rapid.Check(t, prop)
+		return
+	}
+	withRapidFlag("rapid.seed", strconv.FormatUint(opts.Seed, 10), func() {
+		rapid.Check(t, prop)
+	})
+}
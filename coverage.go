@@ -0,0 +1,267 @@
+package SpecSmash
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// GenCoverageSuite returns a deterministic set of payloads that together cover
+// every enum member, every oneOf/anyOf branch, optional properties both present
+// and absent, and the numeric and length bounds of the schema.
+func GenCoverageSuite(schema *openapi3.Schema) []json.RawMessage {
+	return NewGenerationOptions().GenCoverageSuite(schema)
+}
+
+// GenCoverageSuite is GenCoverageSuite using these options, so mode, overrides
+// and pattern functions apply to the suite as well
+func (opts *GenerationOptions) GenCoverageSuite(schema *openapi3.Schema) []json.RawMessage {
+	return dedupe(opts.coverageValues(schema))
+}
+
+// coverageValues returns values for schema that cover its own enum members,
+// branches and bounds, and those of the schemas nested in it
+func (opts *GenerationOptions) coverageValues(schema *openapi3.Schema) []json.RawMessage {
+	if _, ok := opts.Overrides[opts.pointer]; ok || schema == nil || opts.atMaxDepth() {
+		return opts.examples(schema, 1)
+	}
+	if isFalseSchema(schema) {
+		return nil
+	}
+
+	var values []json.RawMessage
+	switch {
+	case len(schema.Enum) > 0:
+		// genEnum also handles an enum narrowed by const
+		if _, ok := keyword(schema, "const"); ok {
+			values = opts.examples(schema, 1)
+		} else {
+			for _, e := range schema.Enum {
+				values = append(values, marshal(e))
+			}
+		}
+	case hasKeyword(schema, "const"):
+		c, _ := keyword(schema, "const")
+		values = []json.RawMessage{marshal(c)}
+	case len(schema.AllOf) > 0:
+		values = opts.allOfCoverage(schema)
+	case len(schema.AnyOf) > 0:
+		values = opts.branchCoverage(schema, schema.AnyOf, "anyOf")
+	case len(schema.OneOf) > 0:
+		values = opts.branchCoverage(schema, schema.OneOf, "oneOf")
+	case schema.Type == nil || len(*schema.Type) != 1:
+		values = opts.examples(schema, 1)
+	case schema.Type.Is("string"):
+		values = opts.stringCoverage(schema)
+	case schema.Type.Is("integer"), schema.Type.Is("number"):
+		values = opts.numberCoverage(schema)
+	case schema.Type.Is("boolean"):
+		values = []json.RawMessage{marshal(true), marshal(false)}
+	case schema.Type.Is("array"):
+		values = opts.arrayCoverage(schema)
+	case schema.Type.Is("object"):
+		values = opts.objectCoverage(schema)
+	default:
+		values = opts.examples(schema, 1)
+	}
+
+	if isNullable(schema) && len(schema.Enum) == 0 {
+		// an enum only allows null as one of its members
+		values = append(values, json.RawMessage("null"))
+	}
+	return values
+}
+
+func hasKeyword(schema *openapi3.Schema, name string) bool {
+	_, ok := keyword(schema, name)
+	return ok
+}
+
+// examples draws n values from the random generator with fixed seeds, offset by Seed
+func (opts *GenerationOptions) examples(schema *openapi3.Schema, n int) []json.RawMessage {
+	gen := opts.GenFromSchema(schema)
+	values := make([]json.RawMessage, n)
+	for i := range values {
+		// adding in uint64 wraps around instead of overflowing int, and Example
+		// turns the int back into the same uint64 seed
+		values[i] = gen.Example(int(opts.Seed + uint64(i)))
+	}
+	return values
+}
+
+func (opts *GenerationOptions) stringCoverage(schema *openapi3.Schema) []json.RawMessage {
+	if schema.Format != "" || schema.Pattern != "" {
+		return opts.examples(schema, 1)
+	}
+	values := []json.RawMessage{marshal(strings.Repeat("a", int(schema.MinLength)))}
+	if schema.MaxLength != nil && *schema.MaxLength != schema.MinLength {
+		values = append(values, marshal(strings.Repeat("z", int(*schema.MaxLength))))
+	}
+	return values
+}
+
+func (opts *GenerationOptions) numberCoverage(schema *openapi3.Schema) []json.RawMessage {
+	if schema.MultipleOf != nil {
+		return opts.examples(schema, 2)
+	}
+
+	integer := schema.Type.Is("integer")
+	var values []json.RawMessage
+	bound := func(limit float64, exclusive bool, integerBound func(float64, bool) (int64, bool)) {
+		if integer {
+			if n, ok := integerBound(limit, exclusive); ok {
+				values = append(values, marshal(n))
+			}
+		} else if !exclusive {
+			values = append(values, marshal(limit))
+		}
+	}
+	if schema.Min != nil {
+		bound(*schema.Min, schema.ExclusiveMin, integerMinimum)
+	}
+	if schema.Max != nil {
+		bound(*schema.Max, schema.ExclusiveMax, integerMaximum)
+	}
+	if len(values) == 0 {
+		if schema.Min != nil || schema.Max != nil {
+			// only exclusive number bounds, which have no closest value
+			return opts.examples(schema, 2)
+		}
+		// without bounds, zero is the interesting boundary
+		values = append(values, marshal(0))
+	}
+	return values
+}
+
+func (opts *GenerationOptions) arrayCoverage(schema *openapi3.Schema) []json.RawMessage {
+	var items []json.RawMessage
+	if schema.Items != nil {
+		items = opts.childAt(schema.Items, "items").coverageValues(schema.Items.Value)
+	}
+
+	size := max(int(schema.MinItems), 1)
+	if schema.MaxItems != nil && int(*schema.MaxItems) < size || len(items) == 0 {
+		return opts.examples(schema, 1)
+	}
+
+	var values []json.RawMessage
+	if schema.MinItems == 0 {
+		values = append(values, json.RawMessage("[]"))
+	}
+	// every item value appears in some array, arrays are padded to minItems
+	for start := 0; start < len(items); start += size {
+		chunk := make([]json.RawMessage, size)
+		for i := range chunk {
+			chunk[i] = items[(start+i)%len(items)]
+		}
+		if schema.UniqueItems && len(dedupe(chunk)) != len(chunk) {
+			values = append(values, opts.examples(schema, 1)...)
+			continue
+		}
+		values = append(values, marshal(chunk))
+	}
+	return values
+}
+
+func (opts *GenerationOptions) objectCoverage(schema *openapi3.Schema) []json.RawMessage {
+	var required, optional []string
+	for _, name := range sortedKeys(schema.Properties) {
+		prop := schema.Properties[name]
+		if prop != nil && opts.Mode.excludes(prop.Value) {
+			continue
+		}
+		if contains(schema.Required, name) {
+			required = append(required, name)
+		} else if prop == nil || !isFalseSchema(prop.Value) {
+			optional = append(optional, name)
+		}
+	}
+
+	propValues := make(map[string][]json.RawMessage)
+	rows := 1
+	for _, name := range append(required, optional...) {
+		prop := schema.Properties[name]
+		var propSchema *openapi3.Schema
+		if prop != nil {
+			propSchema = prop.Value
+		}
+		values := opts.childAt(prop, "properties", name).coverageValues(propSchema)
+		if len(values) == 0 {
+			continue
+		}
+		propValues[name] = values
+		rows = max(rows, len(values))
+	}
+
+	// every row has all properties, cycling through their values, and one
+	// more row has only the required properties
+	build := func(row int, names []string) json.RawMessage {
+		obj := make(map[string]json.RawMessage)
+		for _, name := range names {
+			if values, ok := propValues[name]; ok {
+				obj[name] = values[row%len(values)]
+			}
+		}
+		return marshal(obj)
+	}
+	var values []json.RawMessage
+	for row := range rows {
+		values = append(values, build(row, append(required, optional...)))
+	}
+	if len(optional) > 0 {
+		values = append(values, build(0, required))
+	}
+	return values
+}
+
+func (opts *GenerationOptions) branchCoverage(schema *openapi3.Schema, branches openapi3.SchemaRefs, token string) []json.RawMessage {
+	var values []json.RawMessage
+	for i, sub := range branches {
+		if sub == nil {
+			continue
+		}
+		for _, value := range opts.childAt(sub, token, strconv.Itoa(i)).coverageValues(sub.Value) {
+			// a oneOf value matching several branches would be invalid
+			if validAgainst(schema, value) {
+				values = append(values, value)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return opts.examples(schema, 1)
+	}
+	return values
+}
+
+func (opts *GenerationOptions) allOfCoverage(schema *openapi3.Schema) []json.RawMessage {
+	var merged openapi3.Schema
+	for _, sub := range schema.AllOf {
+		if sub != nil && sub.Value != nil && (len(sub.Value.OneOf) > 0 || len(sub.Value.AnyOf) > 0) {
+			// the combination with a choice is left to the random generator
+			return opts.examples(schema, 2)
+		}
+		merged = mergeSchema(merged, sub)
+	}
+	return opts.objectCoverage(&merged)
+}
+
+// dedupe removes repeated values, keeping the first occurrence
+func dedupe(values []json.RawMessage) []json.RawMessage {
+	var unique []json.RawMessage
+	for _, v := range values {
+		seen := false
+		for _, u := range unique {
+			if bytes.Equal(u, v) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
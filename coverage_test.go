@@ -0,0 +1,79 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenCoverageSuite(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["kind", "count"],
+		"properties": {
+			"kind": {"type": "string", "enum": ["a", "b", "c"]},
+			"count": {"type": "integer", "minimum": 1, "maximum": 9},
+			"note": {"type": "string", "maxLength": 4},
+			"shape": {
+				"oneOf": [
+					{"type": "object", "required": ["radius"], "properties": {"radius": {"type": "number"}}, "additionalProperties": false},
+					{"type": "object", "required": ["side"], "properties": {"side": {"type": "integer"}}, "additionalProperties": false}
+				]
+			},
+			"tags": {"type": "array", "items": {"type": "string", "enum": ["x", "y"]}}
+		}
+	}`)
+
+	suite := GenCoverageSuite(schema)
+	assert.Equal(t, suite, GenCoverageSuite(schema), "the suite is deterministic")
+
+	kinds := map[string]bool{}
+	counts := map[float64]bool{}
+	tags := map[string]bool{}
+	var withRadius, withSide, withoutNote bool
+	for _, payload := range suite {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(payload, &obj))
+		require.NoError(t, schema.VisitJSON(obj), string(payload))
+
+		kinds[obj["kind"].(string)] = true
+		counts[obj["count"].(float64)] = true
+		if _, ok := obj["note"]; !ok {
+			withoutNote = true
+		}
+		if shape, ok := obj["shape"].(map[string]any); ok {
+			_, r := shape["radius"]
+			_, s := shape["side"]
+			withRadius = withRadius || r
+			withSide = withSide || s
+		}
+		if list, ok := obj["tags"].([]any); ok {
+			for _, tag := range list {
+				tags[tag.(string)] = true
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "c": true}, kinds)
+	assert.Equal(t, map[float64]bool{1: true, 9: true}, counts)
+	assert.Equal(t, map[string]bool{"x": true, "y": true}, tags)
+	assert.True(t, withRadius, "oneOf branch with radius")
+	assert.True(t, withSide, "oneOf branch with side")
+	assert.True(t, withoutNote, "optional properties absent")
+}
+
+func TestCoverageExamplesWithLargeSeeds(t *testing.T) {
+	schema := mustSchema(t, `{"type": "integer", "minimum": 0, "maximum": 1000000}`)
+	for _, seed := range []uint64{math.MaxInt64, math.MaxUint64, SeedFromString("bug-1234")} {
+		opts := NewGenerationOptions()
+		opts.Seed = seed
+		values := opts.examples(schema, 3)
+		assert.Equal(t, values, opts.examples(schema, 3), "seed %d", seed)
+		for _, v := range values {
+			assert.True(t, validAgainst(schema, v), string(v))
+		}
+	}
+}
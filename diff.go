@@ -0,0 +1,223 @@
+package SpecSmash
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// GenAgainstDiff generates payloads that are valid against oldSchema and exercise
+// what changed in newSchema, see GenerationOptions.GenAgainstDiff
+func GenAgainstDiff(oldSchema, newSchema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	return NewGenerationOptions().GenAgainstDiff(oldSchema, newSchema)
+}
+
+// GenAgainstDiff generates payloads that are valid against oldSchema and exercise
+// what changed in newSchema, e.g. to test that a new API version handles the
+// payloads of old clients. Each payload targets one change:
+//   - a tightened minimum, maximum, length or item count, with values the old schema
+//     allows but the new one does not, and values on the new bound
+//   - enum members and null removed from the new schema
+//   - properties the new schema adds (if the old schema allows them), removes or
+//     makes required, and properties whose schema changed, at any depth
+//   - array items whose schema changed
+//
+// Other changes, such as a different type, pattern or format, are not targeted.
+// Without any targeted change, payloads are drawn from oldSchema.
+func (opts *GenerationOptions) GenAgainstDiff(oldSchema, newSchema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	targets := diffTargets(oldSchema, newSchema, make(map[[2]*openapi3.Schema]bool))
+	if len(targets) == 0 {
+		return opts.GenFromSchema(oldSchema)
+	}
+	gens := make([]*rapid.Generator[json.RawMessage], len(targets))
+	for i, target := range targets {
+		gens[i] = opts.GenFromSchema(target)
+	}
+	return rapid.OneOf(gens...)
+}
+
+// diffTargets returns narrowed copies of oldSchema, each of which only allows
+// values that exercise one difference to newSchema. visited breaks cycles of
+// recursive schemas.
+func diffTargets(oldSchema, newSchema *openapi3.Schema, visited map[[2]*openapi3.Schema]bool) []*openapi3.Schema {
+	if oldSchema == nil || newSchema == nil || visited[[2]*openapi3.Schema{oldSchema, newSchema}] {
+		return nil
+	}
+	visited[[2]*openapi3.Schema{oldSchema, newSchema}] = true
+	defer delete(visited, [2]*openapi3.Schema{oldSchema, newSchema})
+
+	var targets []*openapi3.Schema
+	narrowed := func(change func(*openapi3.Schema)) {
+		target := *oldSchema
+		change(&target)
+		targets = append(targets, &target)
+	}
+
+	if isNullable(oldSchema) && !isNullable(newSchema) && len(oldSchema.Enum) == 0 {
+		narrowed(func(s *openapi3.Schema) { s.Enum = []any{nil} })
+	}
+	if removed := removedEnumMembers(oldSchema.Enum, newSchema.Enum); len(removed) > 0 {
+		narrowed(func(s *openapi3.Schema) { s.Enum = removed })
+	}
+	if len(oldSchema.Enum) > 0 {
+		// the other keywords do not matter for enum members
+		return targets
+	}
+
+	switch {
+	case oldSchema.Type.Is("integer"), oldSchema.Type.Is("number"):
+		targets = append(targets, numberDiffTargets(oldSchema, newSchema)...)
+	case oldSchema.Type.Is("string"):
+		oldMax, newMax := lengthLimit(oldSchema.MaxLength), lengthLimit(newSchema.MaxLength)
+		if newSchema.MinLength > oldSchema.MinLength {
+			narrowed(func(s *openapi3.Schema) { s.MaxLength = openapi3.Uint64Ptr(min(newSchema.MinLength-1, oldMax)) })
+		}
+		if newMax < oldMax && newMax >= oldSchema.MinLength {
+			narrowed(func(s *openapi3.Schema) { s.MinLength = newMax + 1 })
+		}
+	case oldSchema.Type.Is("array"):
+		oldMax, newMax := lengthLimit(oldSchema.MaxItems), lengthLimit(newSchema.MaxItems)
+		if newSchema.MinItems > oldSchema.MinItems {
+			narrowed(func(s *openapi3.Schema) { s.MaxItems = openapi3.Uint64Ptr(min(newSchema.MinItems-1, oldMax)) })
+		}
+		if newMax < oldMax && newMax >= oldSchema.MinItems {
+			narrowed(func(s *openapi3.Schema) { s.MinItems = newMax + 1 })
+		}
+		if oldSchema.Items != nil && newSchema.Items != nil && oldMax > 0 {
+			for _, items := range diffTargets(oldSchema.Items.Value, newSchema.Items.Value, visited) {
+				narrowed(func(s *openapi3.Schema) {
+					s.Items = &openapi3.SchemaRef{Value: items}
+					s.MinItems = max(s.MinItems, 1)
+				})
+			}
+		}
+	case oldSchema.Type.Is("object"):
+		targets = append(targets, objectDiffTargets(oldSchema, newSchema, visited)...)
+	}
+	return targets
+}
+
+// numberDiffTargets narrows oldSchema to the values below a raised minimum or above
+// a lowered maximum of newSchema, and to the new bounds themselves
+func numberDiffTargets(oldSchema, newSchema *openapi3.Schema) []*openapi3.Schema {
+	var targets []*openapi3.Schema
+	narrowed := func(change func(*openapi3.Schema)) {
+		target := *oldSchema
+		change(&target)
+		// narrowing may leave no value the old schema allows
+		if target.Min == nil || target.Max == nil || *target.Min < *target.Max ||
+			*target.Min == *target.Max && !target.ExclusiveMin && !target.ExclusiveMax {
+			targets = append(targets, &target)
+		}
+	}
+
+	if newSchema.Min != nil && (oldSchema.Min == nil || *newSchema.Min > *oldSchema.Min ||
+		*newSchema.Min == *oldSchema.Min && newSchema.ExclusiveMin && !oldSchema.ExclusiveMin) {
+		newMin := *newSchema.Min
+		narrowed(func(s *openapi3.Schema) {
+			if s.Max == nil || newMin <= *s.Max {
+				s.Max, s.ExclusiveMax = &newMin, !newSchema.ExclusiveMin
+			}
+		})
+		if !newSchema.ExclusiveMin {
+			narrowed(func(s *openapi3.Schema) {
+				s.Min, s.ExclusiveMin, s.Max, s.ExclusiveMax = &newMin, false, &newMin, false
+			})
+		}
+	}
+	if newSchema.Max != nil && (oldSchema.Max == nil || *newSchema.Max < *oldSchema.Max ||
+		*newSchema.Max == *oldSchema.Max && newSchema.ExclusiveMax && !oldSchema.ExclusiveMax) {
+		newMax := *newSchema.Max
+		narrowed(func(s *openapi3.Schema) {
+			if s.Min == nil || newMax >= *s.Min {
+				s.Min, s.ExclusiveMin = &newMax, !newSchema.ExclusiveMax
+			}
+		})
+		if !newSchema.ExclusiveMax {
+			narrowed(func(s *openapi3.Schema) {
+				s.Min, s.ExclusiveMin, s.Max, s.ExclusiveMax = &newMax, false, &newMax, false
+			})
+		}
+	}
+	return targets
+}
+
+// objectDiffTargets narrows oldSchema to objects with one added, removed or changed
+// property present, or with a property the new schema requires absent
+func objectDiffTargets(oldSchema, newSchema *openapi3.Schema, visited map[[2]*openapi3.Schema]bool) []*openapi3.Schema {
+	var targets []*openapi3.Schema
+	withProperty := func(name string, prop *openapi3.SchemaRef) {
+		target := *oldSchema
+		target.Properties = maps.Clone(oldSchema.Properties)
+		if target.Properties == nil {
+			target.Properties = make(openapi3.Schemas)
+		}
+		target.Properties[name] = prop
+		if !contains(target.Required, name) {
+			target.Required = append(slices.Clone(target.Required), name)
+		}
+		targets = append(targets, &target)
+	}
+	// added properties are valid against the old schema if it allows any additional property
+	openOld := oldSchema.AdditionalProperties.Schema == nil &&
+		(oldSchema.AdditionalProperties.Has == nil || *oldSchema.AdditionalProperties.Has)
+
+	for _, name := range sortedKeys(newSchema.Properties) {
+		newProp := newSchema.Properties[name]
+		oldProp, ok := oldSchema.Properties[name]
+		switch {
+		case !ok && openOld && newProp != nil && newProp.Value != nil:
+			withProperty(name, newProp)
+		case ok && oldProp != nil && newProp != nil:
+			for _, target := range diffTargets(oldProp.Value, newProp.Value, visited) {
+				withProperty(name, &openapi3.SchemaRef{Value: target})
+			}
+		}
+	}
+	for _, name := range sortedKeys(oldSchema.Properties) {
+		if _, ok := newSchema.Properties[name]; !ok && oldSchema.Properties[name] != nil {
+			withProperty(name, oldSchema.Properties[name])
+		}
+	}
+
+	for _, name := range newSchema.Required {
+		if contains(oldSchema.Required, name) {
+			continue
+		}
+		target := *oldSchema
+		target.Properties = maps.Clone(oldSchema.Properties)
+		if _, ok := target.Properties[name]; ok {
+			// an optional property with the false schema is never generated
+			target.Properties[name] = &openapi3.SchemaRef{Value: subSchema(false)}
+		}
+		targets = append(targets, &target)
+	}
+	return targets
+}
+
+// removedEnumMembers returns the members of oldEnum missing in newEnum. A schema
+// without enum allows every member.
+func removedEnumMembers(oldEnum, newEnum []any) []any {
+	if len(newEnum) == 0 {
+		return nil
+	}
+	var removed []any
+	for _, member := range oldEnum {
+		if !slices.ContainsFunc(newEnum, func(other any) bool { return bytes.Equal(marshal(member), marshal(other)) }) {
+			removed = append(removed, member)
+		}
+	}
+	return removed
+}
+
+// lengthLimit returns a maxLength or maxItems, unbounded if unset
+func lengthLimit(limit *uint64) uint64 {
+	if limit == nil {
+		return ^uint64(0)
+	}
+	return *limit
+}
@@ -0,0 +1,71 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenAgainstDiff(t *testing.T) {
+	oldSchema := mustSchema(t, `{
+		"type": "object",
+		"required": ["age"],
+		"properties": {
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"name": {"type": "string", "maxLength": 20},
+			"status": {"type": "string", "enum": ["active", "inactive", "legacy"]},
+			"nickname": {"type": "string"}
+		}
+	}`)
+	newSchema := mustSchema(t, `{
+		"type": "object",
+		"required": ["age", "name"],
+		"properties": {
+			"age": {"type": "integer", "minimum": 18, "maximum": 120},
+			"name": {"type": "string", "maxLength": 10},
+			"status": {"type": "string", "enum": ["active", "inactive"]},
+			"email": {"type": "string", "enum": ["a@example.com"]}
+		}
+	}`)
+	gen := GenAgainstDiff(oldSchema, newSchema)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 500; i++ {
+		payload := gen.Example(i)
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(payload, &obj))
+		require.NoError(t, oldSchema.VisitJSON(obj), "%s", payload)
+
+		age := obj["age"].(float64)
+		seen["age below new minimum"] = seen["age below new minimum"] || age < 18
+		seen["age above new maximum"] = seen["age above new maximum"] || age > 120
+		seen["age on new bound"] = seen["age on new bound"] || age == 18 || age == 120
+		name, ok := obj["name"].(string)
+		seen["name missing"] = seen["name missing"] || !ok
+		seen["name too long"] = seen["name too long"] || len([]rune(name)) > 10
+		seen["removed status"] = seen["removed status"] || obj["status"] == "legacy"
+		_, ok = obj["email"]
+		seen["added email"] = seen["added email"] || ok
+		_, ok = obj["nickname"]
+		seen["removed nickname"] = seen["removed nickname"] || ok
+		seen["invalid against new"] = seen["invalid against new"] || newSchema.VisitJSON(obj) != nil
+	}
+	for _, change := range []string{
+		"age below new minimum", "age above new maximum", "age on new bound", "name missing",
+		"name too long", "removed status", "added email", "removed nickname", "invalid against new",
+	} {
+		assert.True(t, seen[change], change)
+	}
+}
+
+func TestGenAgainstDiffUnchanged(t *testing.T) {
+	schema := mustSchema(t, `{"type": "integer", "minimum": 1, "maximum": 3}`)
+	gen := GenAgainstDiff(schema, schema)
+	for i := 0; i < 20; i++ {
+		var v float64
+		require.NoError(t, json.Unmarshal(gen.Example(i), &v))
+		assert.True(t, v >= 1 && v <= 3, v)
+	}
+}
@@ -0,0 +1,99 @@
+package SpecSmash
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// ValidateExamples validates the examples written in the spec against their schemas,
+// to find examples that drifted from the schema. It checks schema examples, and the
+// example/examples of parameters, request bodies and responses. Each error starts
+// with the JSON pointer of the example.
+func ValidateExamples(doc *openapi3.T) []error {
+	var errs []error
+	check := func(pointer string, schema *openapi3.SchemaRef, value any, opts ...openapi3.SchemaValidationOption) {
+		if schema == nil || schema.Value == nil {
+			return
+		}
+		if err := schema.Value.VisitJSON(value, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("%s: example does not match its schema: %w", pointer, err))
+		}
+	}
+
+	walkDocSchemas(doc, func(schema *openapi3.Schema, pointer string) {
+		if schema.Example != nil {
+			check(pointer+"/example", &openapi3.SchemaRef{Value: schema}, schema.Example)
+		}
+	})
+
+	for _, located := range pathItems(doc) {
+		ops := located.item.Operations()
+		for _, method := range sortedKeys(ops) {
+			op := ops[method]
+			pointer := located.pointer + "/" + strings.ToLower(method)
+
+			for i, param := range op.Parameters {
+				if param == nil || param.Value == nil {
+					continue
+				}
+				paramPointer := fmt.Sprintf("%s/parameters/%d", pointer, i)
+				forExamples(param.Value.Example, param.Value.Examples, paramPointer, func(examplePointer string, value any) {
+					check(examplePointer, param.Value.Schema, value, openapi3.VisitAsRequest())
+				})
+			}
+
+			if body := resolveRequestBody(op, doc); body != nil {
+				checkContent(body.Content, pointer+"/requestBody", check, openapi3.VisitAsRequest())
+			}
+
+			if op.Responses == nil {
+				continue
+			}
+			responses := op.Responses.Map()
+			for _, status := range sortedKeys(responses) {
+				response := responses[status]
+				if response == nil || response.Value == nil {
+					continue
+				}
+				checkContent(response.Value.Content, pointer+"/responses/"+escapePointer(status), check, openapi3.VisitAsResponse())
+			}
+		}
+	}
+	return errs
+}
+
+// checkContent checks the examples of every media type in content
+func checkContent(
+	content openapi3.Content,
+	pointer string,
+	check func(pointer string, schema *openapi3.SchemaRef, value any, opts ...openapi3.SchemaValidationOption),
+	opts ...openapi3.SchemaValidationOption,
+) {
+	for _, mediaType := range sortedKeys(content) {
+		media := content[mediaType]
+		if media == nil {
+			continue
+		}
+		mediaPointer := pointer + "/content/" + escapePointer(mediaType)
+		forExamples(media.Example, media.Examples, mediaPointer, func(examplePointer string, value any) {
+			check(examplePointer, media.Schema, value, opts...)
+		})
+	}
+}
+
+// forExamples calls visit for the example and each of the named examples.
+// Examples given by externalValue are not loaded and are skipped.
+func forExamples(example any, examples openapi3.Examples, pointer string, visit func(pointer string, value any)) {
+	if example != nil {
+		visit(pointer+"/example", example)
+	}
+	for _, name := range sortedKeys(examples) {
+		ref := examples[name]
+		if ref == nil || ref.Value == nil || ref.Value.Value == nil {
+			continue
+		}
+		visit(pointer+"/examples/"+escapePointer(name), ref.Value.Value)
+	}
+}
@@ -0,0 +1,94 @@
+package SpecSmash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExamples(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: examples
+  version: 1.0.0
+paths:
+  /users:
+    post:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            maximum: 10
+          examples:
+            ok:
+              value: 5
+            tooLarge:
+              value: 50
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/User'
+            example:
+              name: ann
+              age: -1
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+              examples:
+                current:
+                  value:
+                    name: bob
+                    age: 30
+                stale:
+                  value:
+                    fullName: bob
+components:
+  schemas:
+    User:
+      type: object
+      required: [name]
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+          example: 42
+        age:
+          type: integer
+          minimum: 0
+`)
+
+	errs := ValidateExamples(doc)
+
+	var pointers []string
+	for _, err := range errs {
+		pointer, _, ok := strings.Cut(err.Error(), ": ")
+		require.True(t, ok, err.Error())
+		pointers = append(pointers, pointer)
+	}
+	assert.Equal(t, []string{
+		"/components/schemas/User/properties/name/example",
+		"/paths/~1users/post/parameters/0/examples/tooLarge",
+		"/paths/~1users/post/requestBody/content/application~1json/example",
+		"/paths/~1users/post/responses/200/content/application~1json/examples/stale",
+	}, pointers)
+}
+
+func TestValidateExamplesTestdata(t *testing.T) {
+	for _, specPath := range []string{
+		"testdata/openapi_simple.yaml",
+		"testdata/openapi_comprehensive.yaml",
+	} {
+		doc, err := ReadSpec(specPath)
+		require.NoError(t, err)
+		assert.Empty(t, ValidateExamples(doc), specPath)
+	}
+}
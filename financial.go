@@ -0,0 +1,75 @@
+package SpecSmash
+
+import (
+	"fmt"
+	"strings"
+
+	"pgregory.net/rapid"
+)
+
+// ibanCountry is the structure of the IBANs of one country: the pattern of its
+// basic bank account number (BBAN), which follows the country code and check digits
+type ibanCountry struct {
+	code string
+	bban string
+}
+
+// ibanCountries are the countries IBANs and BICs are generated for
+var ibanCountries = []ibanCountry{
+	{"BE", `[0-9]{12}`},
+	{"CH", `[0-9]{5}[A-Z0-9]{12}`},
+	{"DE", `[0-9]{18}`},
+	{"ES", `[0-9]{20}`},
+	{"FR", `[0-9]{10}[A-Z0-9]{11}[0-9]{2}`},
+	{"GB", `[A-Z]{4}[0-9]{14}`},
+	{"IT", `[A-Z][0-9]{10}[A-Z0-9]{12}`},
+	{"NL", `[A-Z]{4}[0-9]{10}`},
+}
+
+// drawIBAN draws an IBAN (ISO 13616) in its electronic form, without spaces,
+// with a BBAN of the country's structure and valid check digits
+func drawIBAN(t *rapid.T) string {
+	country := rapid.SampledFrom(ibanCountries).Draw(t, "iban-country")
+	bban := rapid.StringMatching(country.bban).Draw(t, "iban-bban")
+	return country.code + ibanCheckDigits(country.code, bban) + bban
+}
+
+// ibanCheckDigits computes the two check digits of an IBAN with ISO 7064 MOD 97-10:
+// 98 minus the remainder of the BBAN, country code and 00 read as one number,
+// with letters counting as 10 (A) to 35 (Z)
+func ibanCheckDigits(countryCode, bban string) string {
+	return fmt.Sprintf("%02d", 98-mod97(bban+countryCode+"00"))
+}
+
+// validIBAN reports whether the check digits of iban are valid
+func validIBAN(iban string) bool {
+	if len(iban) < 5 {
+		return false
+	}
+	return mod97(iban[4:]+iban[:4]) == 1
+}
+
+// mod97 returns the remainder of s divided by 97, reading letters as two digits
+func mod97(s string) int {
+	remainder := 0
+	for _, c := range strings.ToUpper(s) {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return -1
+		}
+	}
+	return remainder
+}
+
+// drawBIC draws a BIC (ISO 9362): a bank code, the country code, a location code
+// and, in some draws, a branch code
+func drawBIC(t *rapid.T) string {
+	country := rapid.SampledFrom(ibanCountries).Draw(t, "bic-country")
+	// a 0 as the second location character denotes a test BIC
+	return rapid.StringMatching(`[A-Z]{4}`).Draw(t, "bic-bank") + country.code +
+		rapid.StringMatching(`[A-Z2-9][A-NP-Z1-9]([A-Z0-9]{3})?`).Draw(t, "bic-location")
+}
@@ -0,0 +1,37 @@
+package SpecSmash
+
+import (
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// formatValidators are the string formats registered with RegisterFormatValidator
+var formatValidators = struct {
+	mu         sync.RWMutex
+	validators map[string]func(string) error
+}{validators: make(map[string]func(string) error)}
+
+// RegisterFormatValidator registers fn as the validator of the string format name,
+// both with kin-openapi, so ValidatePayload rejects values fn rejects, and with the
+// generators, so generated values pass it: values of the format are drawn from
+// its built-in generator if SpecSmash has one, else from the PatternFunc (called
+// with an empty pattern and the format), else as plain strings, and drawn again
+// until fn accepts them. Registered formats are known to WithUnknownFormatPolicy.
+//
+// kin-openapi keeps format validators in a global map, so register formats before
+// loading specs and generating, e.g. in an init function or TestMain.
+func RegisterFormatValidator(name string, fn func(string) error) {
+	formatValidators.mu.Lock()
+	defer formatValidators.mu.Unlock()
+	formatValidators.validators[name] = fn
+	openapi3.DefineStringFormatValidator(name, openapi3.NewCallbackValidator(fn))
+}
+
+// formatValidator returns the validator registered for format, if any
+func formatValidator(format string) (func(string) error, bool) {
+	formatValidators.mu.RLock()
+	defer formatValidators.mu.RUnlock()
+	fn, ok := formatValidators.validators[format]
+	return fn, ok
+}
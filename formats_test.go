@@ -0,0 +1,68 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestRegisterFormatValidator(t *testing.T) {
+	skuPattern := regexp.MustCompile(`^SKU-[0-9]{4}$`)
+	RegisterFormatValidator("sku", func(s string) error {
+		if !skuPattern.MatchString(s) {
+			return errors.New("not a SKU")
+		}
+		return nil
+	})
+
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: formats
+  version: 1.0.0
+paths:
+  /products:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [sku]
+              properties:
+                sku:
+                  type: string
+                  format: sku
+      responses:
+        '200':
+          description: ok
+`)
+	op := doc.Paths.Value("/products").Post
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+
+	// the PatternFunc also draws values the validator rejects, which are drawn again
+	gen := NewGenerationOptions().WithUnknownFormatPolicy(UnknownFormatError).WithPatternFunc(
+		func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+			require.Equal(t, "sku", format)
+			return rapid.StringMatching(`SKU-[0-9]{3,5}`).Draw(t, "sku")
+		},
+	).GenFromSchema(schema.Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		var product struct {
+			SKU string `json:"sku"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &product))
+		assert.Regexp(t, skuPattern, product.SKU)
+		assert.NoError(t, ValidatePayload(rt.Context(), payload, "/products", op))
+	})
+
+	assert.Error(t, ValidatePayload(t.Context(), []byte(`{"sku": "SKU-12"}`), "/products", op))
+}
@@ -0,0 +1,249 @@
+package SpecSmash
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// GenAllMediaTypes returns a generator of encoded request bodies for every media type
+// of op's request body that has a schema, keyed by media type. Bodies are encoded by
+// media type: JSON for application/json and +json types, XML following the schemas'
+// xml objects for application/xml, text/xml and +xml types, key=value pairs for
+// application/x-www-form-urlencoded, and the bare string for text/plain. Other media
+// types are given the JSON encoding. A request body given as a $ref that was not
+// loaded is looked up in doc if it is passed, see GetSchema.
+func (opts *GenerationOptions) GenAllMediaTypes(op *openapi3.Operation, doc ...*openapi3.T) map[string]*rapid.Generator[[]byte] {
+	gens := make(map[string]*rapid.Generator[[]byte])
+	body := resolveRequestBody(op, doc...)
+	if body == nil {
+		return gens
+	}
+
+	located := *opts
+	if strings.HasPrefix(op.RequestBody.Ref, "#/") {
+		located.pointer = op.RequestBody.Ref[1:]
+	}
+	for mediaType, media := range body.Content {
+		if media == nil || media.Schema == nil {
+			continue
+		}
+		schemaRef := media.Schema
+		valueGen := located.childAt(schemaRef, "content", mediaType, "schema").GenFromSchema(schemaRef.Value)
+		encode := mediaTypeEncoder(mediaType, schemaRef)
+		gens[mediaType] = rapid.Custom(func(t *rapid.T) []byte {
+			return encode(valueGen.Draw(t, mediaType))
+		})
+	}
+	return gens
+}
+
+// ValidateAllMediaTypes validates payload against the schema of every JSON media type
+// of op's request body, i.e. application/json and +json types such as
+// application/*+json, so a payload that only some of them accept can be detected.
+// The result maps each media type to its validation error, nil if the payload is
+// valid for it. Media types without a schema are left out. Like GenAllMediaTypes, a
+// request body $ref that was not loaded is looked up in doc if it is passed.
+func ValidateAllMediaTypes(payload []byte, op *openapi3.Operation, doc ...*openapi3.T) map[string]error {
+	results := make(map[string]error)
+	body := resolveRequestBody(op, doc...)
+	if body == nil {
+		return results
+	}
+
+	var value any
+	decodeErr := json.Unmarshal(payload, &value)
+	for mediaType, media := range body.Content {
+		if !isJSONMediaType(mediaType) || media == nil || media.Schema == nil || media.Schema.Value == nil {
+			continue
+		}
+		if decodeErr != nil {
+			results[mediaType] = fmt.Errorf("payload is not valid JSON: %w", decodeErr)
+			continue
+		}
+		results[mediaType] = media.Schema.Value.VisitJSON(value, openapi3.VisitAsRequest())
+	}
+	return results
+}
+
+// mediaTypeEssence returns mediaType in lower case, without parameters
+func mediaTypeEssence(mediaType string) string {
+	essence, _, _ := strings.Cut(strings.ToLower(mediaType), ";")
+	return strings.TrimSpace(essence)
+}
+
+// isJSONMediaType reports whether mediaType is application/json or a +json type
+func isJSONMediaType(mediaType string) bool {
+	essence := mediaTypeEssence(mediaType)
+	return essence == "application/json" || strings.HasSuffix(essence, "+json")
+}
+
+// mediaTypeEncoder returns how generated JSON values are encoded as mediaType
+func mediaTypeEncoder(mediaType string, schemaRef *openapi3.SchemaRef) func(json.RawMessage) []byte {
+	essence := mediaTypeEssence(mediaType)
+	switch {
+	case essence == "application/xml" || essence == "text/xml" || strings.HasSuffix(essence, "+xml"):
+		name := "root"
+		if schemaRef.Ref != "" {
+			name = path.Base(schemaRef.Ref)
+		}
+		return func(value json.RawMessage) []byte { return encodeXML(name, schemaRef.Value, value) }
+	case essence == "application/x-www-form-urlencoded":
+		return encodeForm
+	case essence == "text/plain":
+		return func(value json.RawMessage) []byte {
+			var s string
+			if json.Unmarshal(value, &s) == nil {
+				return []byte(s)
+			}
+			return value
+		}
+	}
+	return func(value json.RawMessage) []byte { return value }
+}
+
+// encodeForm encodes an object as application/x-www-form-urlencoded. Arrays of
+// scalars repeat their key, other nested values are sent as JSON text.
+func encodeForm(value json.RawMessage) []byte {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(value, &obj); err != nil {
+		panic(fmt.Sprintf("form bodies must be objects, got %s", value))
+	}
+	form := url.Values{}
+	for _, key := range sortedKeys(obj) {
+		var items []json.RawMessage
+		if json.Unmarshal(obj[key], &items) == nil && !containsNested(items) {
+			for _, item := range items {
+				form.Add(key, scalarText(item))
+			}
+			continue
+		}
+		form.Add(key, scalarText(obj[key]))
+	}
+	return []byte(form.Encode())
+}
+
+// containsNested reports whether some item is an array or object
+func containsNested(items []json.RawMessage) bool {
+	for _, item := range items {
+		if trimmed := bytes.TrimSpace(item); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarText returns strings unquoted and any other value as JSON text
+func scalarText(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	return string(value)
+}
+
+// encodeXML encodes value as an XML element, named and shaped by the xml objects of
+// schema and its properties and items: name, prefix, namespace, attribute and wrapped.
+// Property names are used as element names as they are.
+func encodeXML(name string, schema *openapi3.Schema, value json.RawMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	writeXMLElement(&buf, name, schema, value, true)
+	return buf.Bytes()
+}
+
+// writeXMLElement writes value as the element name. Arrays are written as repeated
+// elements, inside an element of the same name when wrapped or at the root.
+func writeXMLElement(buf *bytes.Buffer, name string, schema *openapi3.Schema, value json.RawMessage, root bool) {
+	var xmlns string
+	if schema != nil && schema.XML != nil {
+		if schema.XML.Name != "" {
+			name = schema.XML.Name
+		}
+		if schema.XML.Namespace != "" {
+			xmlns = xmlnsAttr(schema.XML.Prefix, schema.XML.Namespace)
+		}
+		if schema.XML.Prefix != "" {
+			name = schema.XML.Prefix + ":" + name
+		}
+	}
+
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		_ = json.Unmarshal(value, &items)
+		var itemSchema *openapi3.Schema
+		if schema != nil && schema.Items != nil {
+			itemSchema = schema.Items.Value
+		}
+		wrapped := root || schema != nil && schema.XML != nil && schema.XML.Wrapped
+		if wrapped {
+			fmt.Fprintf(buf, "<%s%s>", name, xmlns)
+		}
+		for _, item := range items {
+			writeXMLElement(buf, name, itemSchema, item, false)
+		}
+		if wrapped {
+			fmt.Fprintf(buf, "</%s>", name)
+		}
+		return
+	}
+
+	fmt.Fprintf(buf, "<%s%s", name, xmlns)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var obj map[string]json.RawMessage
+		_ = json.Unmarshal(value, &obj)
+		var children []string
+		for _, key := range sortedKeys(obj) {
+			propSchema := propertySchema(schema, key)
+			if propSchema != nil && propSchema.XML != nil && propSchema.XML.Attribute {
+				attrName := key
+				if propSchema.XML.Name != "" {
+					attrName = propSchema.XML.Name
+				}
+				fmt.Fprintf(buf, " %s=\"%s\"", attrName, xmlEscape(scalarText(obj[key])))
+				continue
+			}
+			children = append(children, key)
+		}
+		buf.WriteByte('>')
+		for _, key := range children {
+			writeXMLElement(buf, key, propertySchema(schema, key), obj[key], false)
+		}
+	} else {
+		buf.WriteByte('>')
+		if string(trimmed) != "null" {
+			buf.WriteString(xmlEscape(scalarText(value)))
+		}
+	}
+	fmt.Fprintf(buf, "</%s>", name)
+}
+
+// propertySchema returns the schema of the property name, or nil if it is not declared
+func propertySchema(schema *openapi3.Schema, name string) *openapi3.Schema {
+	if schema == nil || schema.Properties[name] == nil {
+		return nil
+	}
+	return schema.Properties[name].Value
+}
+
+// xmlnsAttr declares namespace, for prefix if it is set
+func xmlnsAttr(prefix, namespace string) string {
+	if prefix != "" {
+		prefix = ":" + prefix
+	}
+	return fmt.Sprintf(" xmlns%s=\"%s\"", prefix, xmlEscape(namespace))
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,98 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestGenAllMediaTypes(t *testing.T) {
+	doc, err := ReadSpec("testdata/openapi_media_types.yaml")
+	require.NoError(t, err)
+	op := doc.Paths.Find("/pets").Post
+
+	gens := NewGenerationOptions().GenAllMediaTypes(op)
+	require.Len(t, gens, 4)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		jsonBody := gens["application/json"].Draw(rt, "json")
+		assert.NoError(t, ValidatePayload(rt.Context(), jsonBody, "/pets", op), string(jsonBody))
+
+		var pet struct {
+			XMLName xml.Name `xml:"pet"`
+			ID      int      `xml:"id,attr"`
+			Name    string   `xml:"name"`
+			Tags    []string `xml:"tags>tag"`
+		}
+		xmlBody := gens["application/xml"].Draw(rt, "xml")
+		require.NoError(t, xml.Unmarshal(xmlBody, &pet), string(xmlBody))
+		assert.GreaterOrEqual(t, pet.ID, 1, string(xmlBody))
+
+		formBody := gens["application/x-www-form-urlencoded"].Draw(rt, "form")
+		form, err := url.ParseQuery(string(formBody))
+		require.NoError(t, err)
+		id, err := strconv.Atoi(form.Get("id"))
+		require.NoError(t, err, string(formBody))
+		assert.GreaterOrEqual(t, id, 1)
+		assert.True(t, form.Has("name"), string(formBody))
+
+		text := gens["text/plain"].Draw(rt, "text")
+		assert.NotEmpty(t, text)
+		assert.False(t, json.Valid(text) && text[0] == '"', "text/plain bodies are not JSON strings")
+	})
+}
+
+func TestValidateAllMediaTypes(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: media types
+  version: 1.0.0
+paths:
+  /events:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name:
+                  type: string
+          application/*+json:
+            schema:
+              type: object
+              required: [name, version]
+              properties:
+                name:
+                  type: string
+                version:
+                  type: integer
+          text/plain:
+            schema:
+              type: string
+      responses:
+        '200':
+          description: ok
+`)
+	op := doc.Paths.Find("/events").Post
+
+	results := ValidateAllMediaTypes([]byte(`{"name": "deploy"}`), op)
+	require.Len(t, results, 2)
+	assert.NoError(t, results["application/json"])
+	assert.ErrorContains(t, results["application/*+json"], "version")
+
+	results = ValidateAllMediaTypes([]byte(`{"name": "deploy", "version": 2}`), op)
+	assert.NoError(t, results["application/json"])
+	assert.NoError(t, results["application/*+json"])
+
+	results = ValidateAllMediaTypes([]byte(`{"name"`), op)
+	assert.ErrorContains(t, results["application/json"], "not valid JSON")
+}
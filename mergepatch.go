@@ -0,0 +1,109 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// mergePatchMediaType is the media type of JSON merge patches (RFC 7386)
+const mergePatchMediaType = "application/merge-patch+json"
+
+// MergePatchSchema returns a copy of schema that accepts merge patches of its values:
+// no property is required, and every declared, additional or pattern property may be
+// null to delete it. Nested objects are partial as well, array items are not, since a
+// patch replaces arrays as a whole. A partial patch may fit several oneOf branches, so
+// oneOf becomes anyOf and the discriminator, which a patch need not set, is dropped.
+func MergePatchSchema(schema *openapi3.Schema) *openapi3.Schema {
+	return mergePatchSchema(schema, make(map[*openapi3.Schema]*openapi3.Schema))
+}
+
+// mergePatchSchema is MergePatchSchema, with the copies made so far for recursive schemas
+func mergePatchSchema(schema *openapi3.Schema, copies map[*openapi3.Schema]*openapi3.Schema) *openapi3.Schema {
+	if schema == nil || isFalseSchema(schema) {
+		return schema
+	}
+	if patched, ok := copies[schema]; ok {
+		return patched
+	}
+	patched := *schema
+	copies[schema] = &patched
+
+	patched.Required = nil
+	if schema.Properties != nil {
+		patched.Properties = make(openapi3.Schemas, len(schema.Properties))
+		for name, prop := range schema.Properties {
+			patched.Properties[name] = deletableSchema(prop, copies)
+		}
+	}
+	if schema.AdditionalProperties.Schema != nil {
+		patched.AdditionalProperties.Schema = deletableSchema(schema.AdditionalProperties.Schema, copies)
+	}
+	if raw, ok := keyword(schema, "patternProperties"); ok {
+		if byPattern, ok := raw.(map[string]any); ok {
+			deletable := make(map[string]any, len(byPattern))
+			for pattern, sub := range byPattern {
+				deletable[pattern] = deletableSchema(&openapi3.SchemaRef{Value: subSchema(sub)}, copies).Value
+			}
+			patched.Extensions = maps.Clone(schema.Extensions)
+			patched.Extensions["patternProperties"] = deletable
+		}
+	}
+	patchRefs := func(refs openapi3.SchemaRefs) openapi3.SchemaRefs {
+		if refs == nil {
+			return nil
+		}
+		out := make(openapi3.SchemaRefs, len(refs))
+		for i, ref := range refs {
+			if ref == nil {
+				continue
+			}
+			out[i] = &openapi3.SchemaRef{Ref: ref.Ref, Value: mergePatchSchema(ref.Value, copies)}
+		}
+		return out
+	}
+	patched.AllOf = patchRefs(schema.AllOf)
+	patched.AnyOf = patchRefs(schema.AnyOf)
+	patched.OneOf = nil
+	patched.Discriminator = nil
+	if oneOf := patchRefs(schema.OneOf); len(oneOf) > 0 {
+		if len(patched.AnyOf) == 0 {
+			patched.AnyOf = oneOf
+		} else {
+			patched.AllOf = append(slices.Clip(patched.AllOf), &openapi3.SchemaRef{Value: &openapi3.Schema{AnyOf: oneOf}})
+		}
+	}
+	return &patched
+}
+
+// deletableSchema returns the merge patch schema of a property that may also be
+// null, to delete the property
+func deletableSchema(prop *openapi3.SchemaRef, copies map[*openapi3.Schema]*openapi3.Schema) *openapi3.SchemaRef {
+	if prop == nil || prop.Value == nil || isFalseSchema(prop.Value) {
+		return prop
+	}
+	deletable := *mergePatchSchema(prop.Value, copies)
+	deletable.Nullable = true
+	if len(deletable.Enum) > 0 {
+		deletable.Enum = append(append([]any(nil), deletable.Enum...), nil)
+	}
+	return &openapi3.SchemaRef{Ref: prop.Ref, Value: &deletable}
+}
+
+// ValidateMergePatch validates payload as a merge patch for the request body of op,
+// against the MergePatchSchema of its application/json or application/merge-patch+json schema.
+// A request body $ref that was not loaded is looked up in doc if it is passed, see GetSchema.
+func ValidateMergePatch(payload []byte, op *openapi3.Operation, doc ...*openapi3.T) error {
+	schemaRef, ok := GetSchema(op, doc...)
+	if !ok || schemaRef == nil || schemaRef.Value == nil {
+		return fmt.Errorf("operation has no JSON request body schema")
+	}
+	var value any
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return fmt.Errorf("merge patch is not valid JSON: %w", err)
+	}
+	return MergePatchSchema(schemaRef.Value).VisitJSON(value, openapi3.VisitAsRequest())
+}
@@ -0,0 +1,145 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestMergePatchMode(t *testing.T) {
+	doc, err := ReadSpec("testdata/openapi_merge_patch.yaml")
+	require.NoError(t, err)
+	op := doc.Paths.Find("/users/{id}").Patch
+	schemaRef, ok := GetSchema(op)
+	require.True(t, ok)
+
+	gen := NewGenerationOptions().WithMode(ModeMergePatch).GenFromSchema(schemaRef.Value)
+
+	var sawPartial, sawDeletion bool
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "patch")
+		require.NoError(t, ValidateMergePatch(payload, op), string(payload))
+
+		var patch map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(payload, &patch))
+		assert.NotContains(t, patch, "id")
+		if _, ok := patch["name"]; !ok {
+			sawPartial = true
+		}
+		for _, value := range patch {
+			if string(value) == "null" {
+				sawDeletion = true
+			}
+		}
+
+		// array items are replaced, not patched, so they are complete
+		if roles, ok := patch["roles"]; ok && string(roles) != "null" {
+			var items []map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(roles, &items))
+			for _, item := range items {
+				assert.Contains(t, item, "name")
+				assert.NotEqual(t, "null", string(item["name"]))
+			}
+		}
+	})
+	assert.True(t, sawPartial, "no patch left out a required property")
+	assert.True(t, sawDeletion, "no patch deleted a property")
+}
+
+func TestValidateMergePatch(t *testing.T) {
+	doc, err := ReadSpec("testdata/openapi_merge_patch.yaml")
+	require.NoError(t, err)
+	op := doc.Paths.Find("/users/{id}").Patch
+
+	assert.NoError(t, ValidateMergePatch([]byte(`{}`), op))
+	assert.NoError(t, ValidateMergePatch([]byte(`{"status": null, "address": {"city": "Delft"}}`), op))
+	assert.Error(t, ValidateMergePatch([]byte(`{"name": ""}`), op))
+	assert.Error(t, ValidateMergePatch([]byte(`{"roles": [{"scope": "admin"}]}`), op))
+	assert.Error(t, ValidateMergePatch([]byte(`{"nickname": "x"}`), op))
+
+	// complete bodies of the merge patch only operation are sent as merge patches
+	schemaRef, ok := GetSchema(op)
+	require.True(t, ok)
+	gen := NewGenerationOptions().WithMode(ModeRequest).GenFromSchema(schemaRef.Value)
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "body")
+		assert.NoError(t, ValidatePayload(rt.Context(), payload, "/users/{id}", op), string(payload))
+	})
+
+	// a request body $ref that was not loaded is looked up in doc
+	shared := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: shared merge patch
+  version: 1.0.0
+paths:
+  /users:
+    patch:
+      requestBody:
+        $ref: '#/components/requestBodies/UserPatch'
+      responses:
+        '200':
+          description: updated
+components:
+  requestBodies:
+    UserPatch:
+      content:
+        application/merge-patch+json:
+          schema:
+            type: object
+            required: [name]
+            properties:
+              name:
+                type: string
+`)
+	unresolved := &openapi3.Operation{RequestBody: &openapi3.RequestBodyRef{Ref: "#/components/requestBodies/UserPatch"}}
+	assert.ErrorContains(t, ValidateMergePatch([]byte(`{}`), unresolved), "no JSON request body schema")
+	assert.NoError(t, ValidateMergePatch([]byte(`{"name": null}`), unresolved, shared))
+	assert.Error(t, ValidateMergePatch([]byte(`{"name": 1}`), unresolved, shared))
+}
+
+func TestMergePatchSchemaAdditionalPropertiesAndOneOf(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"properties": {"name": {"type": "string"}},
+		"additionalProperties": {"type": "integer"},
+		"oneOf": [
+			{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}},
+			{"type": "object", "required": ["count"], "properties": {"count": {"type": "integer"}}}
+		]
+	}`)
+	patch := MergePatchSchema(schema)
+
+	// additional properties may be deleted like declared ones
+	assert.NoError(t, patch.VisitJSON(map[string]any{"extra": nil}))
+	assert.Error(t, patch.VisitJSON(map[string]any{"extra": "x"}))
+	// a partial patch fits every branch, which oneOf would reject
+	assert.NoError(t, patch.VisitJSON(map[string]any{}))
+	require.NoError(t, AssertOneOfExclusive(schema, json.RawMessage(`{"count": 1}`)))
+
+	gen := NewGenerationOptions().WithMode(ModeMergePatch).GenFromSchema(schema)
+	var sawDeletedExtra bool
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "patch")
+		var value map[string]any
+		require.NoError(t, json.Unmarshal(payload, &value))
+		require.NoError(t, patch.VisitJSON(value), string(payload))
+		for key, v := range value {
+			if key != "name" && key != "count" && v == nil {
+				sawDeletedExtra = true
+			}
+		}
+	})
+	assert.True(t, sawDeletedExtra, "no patch deleted an additional property")
+
+	patterned := MergePatchSchema(mustSchema(t, `{
+		"type": "object",
+		"patternProperties": {"^x-": {"type": "string"}}
+	}`))
+	require.Len(t, patternProperties(patterned), 1)
+	assert.NoError(t, patternProperties(patterned)[0].schema.VisitJSON(nil))
+}
@@ -0,0 +1,205 @@
+package SpecSmash
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// GenerationResult is a generated payload with the decisions taken to generate it
+type GenerationResult struct {
+	Payload json.RawMessage
+	Meta    GenMeta
+}
+
+// GenMeta describes the decisions taken in one draw. Values are identified by their
+// JSON pointer in the payload, e.g. /pets/0 for the first item of the pets property,
+// so every element of an array of oneOf items has its own entry. Decisions taken for
+// values that were drawn and discarded, e.g. redrawn to satisfy a schema, are left out.
+type GenMeta struct {
+	// OneOf holds the index of the branch chosen for every value of a oneOf schema
+	OneOf map[string]int
+	// AnyOf holds the indices of the branches satisfied for every value of an anyOf schema
+	AnyOf map[string][]int
+	// Null reports whether the payload is null
+	Null bool
+	// Size is the length of the payload in bytes
+	Size int
+}
+
+// metaRecorder collects the GenMeta of the draw in progress
+type metaRecorder struct {
+	mu   sync.Mutex
+	meta GenMeta
+	// location holds the reference tokens of the value being drawn
+	location []string
+}
+
+// instance returns the JSON pointer of the value being drawn
+func (r *metaRecorder) instance() string {
+	if len(r.location) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(r.location, "/")
+}
+
+// rename changes the keys of the decisions recorded below the current location
+// with rename, which returns the new key and whether to keep the decision
+func (r *metaRecorder) rename(rename func(token, rest string) (string, bool)) {
+	prefix := r.instance() + "/"
+	renamed := func(key string) (string, bool) {
+		below, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			return key, true
+		}
+		token, rest, _ := strings.Cut(below, "/")
+		if rest != "" {
+			rest = "/" + rest
+		}
+		newToken, keep := rename(token, rest)
+		return prefix + newToken + rest, keep
+	}
+	oneOf := make(map[string]int, len(r.meta.OneOf))
+	for key, idx := range r.meta.OneOf {
+		if newKey, keep := renamed(key); keep {
+			oneOf[newKey] = idx
+		}
+	}
+	anyOf := make(map[string][]int, len(r.meta.AnyOf))
+	for key, indices := range r.meta.AnyOf {
+		if newKey, keep := renamed(key); keep {
+			anyOf[newKey] = indices
+		}
+	}
+	r.meta.OneOf, r.meta.AnyOf = oneOf, anyOf
+}
+
+// GenFromSchemaWithMeta generates payloads for schema together with their GenMeta
+func GenFromSchemaWithMeta(schema *openapi3.Schema) *rapid.Generator[GenerationResult] {
+	return NewGenerationOptions().GenFromSchemaWithMeta(schema)
+}
+
+// GenFromSchemaWithMeta is GenFromSchema, but every draw also reports its GenMeta.
+// Draws of the returned generator are serialized, so each one records its own decisions.
+func (opts *GenerationOptions) GenFromSchemaWithMeta(schema *openapi3.Schema) *rapid.Generator[GenerationResult] {
+	recorder := &metaRecorder{}
+	inner := *opts
+	inner.meta = recorder
+	gen := inner.GenFromSchema(schema)
+	return rapid.Custom(func(t *rapid.T) GenerationResult {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+
+		recorder.meta = GenMeta{OneOf: make(map[string]int), AnyOf: make(map[string][]int)}
+		payload := gen.Draw(t, "payload")
+		meta := recorder.meta
+		meta.Null = bytes.Equal(payload, []byte("null"))
+		meta.Size = len(payload)
+		return GenerationResult{Payload: payload, Meta: meta}
+	})
+}
+
+// recordOneOf records the branch chosen for the oneOf value being drawn
+func (opts *GenerationOptions) recordOneOf(idx int) {
+	if opts.meta != nil {
+		opts.meta.meta.OneOf[opts.meta.instance()] = idx
+	}
+}
+
+// recordAnyOf records the branches satisfied for the anyOf value being drawn
+func (opts *GenerationOptions) recordAnyOf(indices []int) {
+	if opts.meta != nil {
+		opts.meta.meta.AnyOf[opts.meta.instance()] = indices
+	}
+}
+
+// recordingAfter returns gen, recording its decision with record after each draw, so
+// decisions of the values nested in it are recorded, and discarded, first
+func (opts *GenerationOptions) recordingAfter(gen *rapid.Generator[json.RawMessage], record func()) *rapid.Generator[json.RawMessage] {
+	if opts.meta == nil {
+		return gen
+	}
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		value := gen.Draw(t, "recorded")
+		record()
+		return value
+	})
+}
+
+// resetMeta drops the decisions recorded for the value being drawn and the values
+// nested in it, before it is drawn again
+func (opts *GenerationOptions) resetMeta() {
+	if opts.meta == nil {
+		return
+	}
+	at := opts.meta.instance()
+	for key := range opts.meta.meta.OneOf {
+		if key == at || strings.HasPrefix(key, at+"/") {
+			delete(opts.meta.meta.OneOf, key)
+		}
+	}
+	for key := range opts.meta.meta.AnyOf {
+		if key == at || strings.HasPrefix(key, at+"/") {
+			delete(opts.meta.meta.AnyOf, key)
+		}
+	}
+}
+
+// drawAt draws the value at token below the value being drawn, an array index or
+// a property name, dropping the decisions of a value drawn there before
+func (opts *GenerationOptions) drawAt(gen *rapid.Generator[json.RawMessage], t *rapid.T, label, token string) json.RawMessage {
+	if opts.meta == nil {
+		return gen.Draw(t, label)
+	}
+	opts.meta.location = append(opts.meta.location, escapePointer(token))
+	defer func() { opts.meta.location = opts.meta.location[:len(opts.meta.location)-1] }()
+	opts.resetMeta()
+	return gen.Draw(t, label)
+}
+
+// indexedItems returns itemGen drawing the items of one array at successive indices
+// from first, for arrays drawn with rapid.SliceOfN
+func (opts *GenerationOptions) indexedItems(itemGen *rapid.Generator[json.RawMessage], first int) *rapid.Generator[json.RawMessage] {
+	if opts.meta == nil {
+		return itemGen
+	}
+	next := first
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		next++
+		return opts.drawAt(itemGen, t, "item", strconv.Itoa(next-1))
+	})
+}
+
+// dropItemsFrom drops the decisions of the items from index n on of the array being
+// drawn, and of items drawn at the end, which were discarded
+func (opts *GenerationOptions) dropItemsFrom(n int) {
+	if opts.meta == nil {
+		return
+	}
+	opts.meta.rename(func(token, rest string) (string, bool) {
+		idx, err := strconv.Atoi(token)
+		return token, err == nil && idx < n
+	})
+}
+
+// insertItem moves the decisions of the item drawn at the end, /-, of the array
+// being drawn to index at, after those of the items from at on
+func (opts *GenerationOptions) insertItem(at int) {
+	if opts.meta == nil {
+		return
+	}
+	opts.meta.rename(func(token, rest string) (string, bool) {
+		if token == "-" {
+			return strconv.Itoa(at), true
+		}
+		if idx, err := strconv.Atoi(token); err == nil && idx >= at {
+			return strconv.Itoa(idx + 1), true
+		}
+		return token, true
+	})
+}
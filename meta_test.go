@@ -0,0 +1,202 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestGenFromSchemaWithMeta(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["pet"],
+		"properties": {
+			"pet": {
+				"nullable": true,
+				"oneOf": [
+					{"type": "string", "enum": ["cat"]},
+					{"type": "integer", "minimum": 0}
+				]
+			},
+			"tags": {
+				"anyOf": [
+					{"type": "object", "required": ["a"], "properties": {"a": {"type": "boolean"}}},
+					{"type": "object", "required": ["b"], "properties": {"b": {"type": "boolean"}}}
+				]
+			}
+		}
+	}`)
+	gen := GenFromSchemaWithMeta(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		result := gen.Draw(rt, "result")
+		assert.False(t, result.Meta.Null)
+		assert.Equal(t, len(result.Payload), result.Meta.Size)
+
+		var obj map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(result.Payload, &obj))
+
+		if string(obj["pet"]) != "null" {
+			idx, ok := result.Meta.OneOf["/pet"]
+			require.True(t, ok, result.Meta)
+			if idx == 0 {
+				assert.Equal(t, `"cat"`, string(obj["pet"]))
+			} else {
+				assert.NotEqual(t, `"cat"`, string(obj["pet"]))
+			}
+		}
+
+		if tags, ok := obj["tags"]; ok {
+			var tagObj map[string]bool
+			require.NoError(t, json.Unmarshal(tags, &tagObj))
+			for _, idx := range result.Meta.AnyOf["/tags"] {
+				assert.Contains(t, tagObj, []string{"a", "b"}[idx])
+			}
+		} else {
+			assert.NotContains(t, result.Meta.AnyOf, "/tags")
+		}
+	})
+}
+
+func TestGenFromSchemaWithMetaArrays(t *testing.T) {
+	// every item is recorded at its own index, also for polymorphic arrays
+	polymorphic := GenFromSchemaWithMeta(mustSchema(t, `{
+		"type": "array",
+		"items": {"oneOf": [{"type": "integer"}, {"type": "string"}]}
+	}`))
+	rapid.Check(t, func(rt *rapid.T) {
+		result := polymorphic.Draw(rt, "result")
+		var items []any
+		require.NoError(t, json.Unmarshal(result.Payload, &items))
+		require.Len(t, result.Meta.OneOf, len(items), result.Meta)
+		for i, item := range items {
+			want := 0
+			if _, ok := item.(string); ok {
+				want = 1
+			}
+			assert.Equal(t, want, result.Meta.OneOf[fmt.Sprintf("/%d", i)], result.Meta)
+		}
+	})
+
+	// items placed between others by contains are recorded at their final index
+	contained := GenFromSchemaWithMeta(mustSchema(t, `{
+		"type": "array",
+		"minItems": 3,
+		"items": {"oneOf": [{"type": "integer"}, {"type": "string"}]},
+		"contains": {"type": "string"},
+		"maxContains": 1
+	}`))
+	rapid.Check(t, func(rt *rapid.T) {
+		result := contained.Draw(rt, "result")
+		var items []any
+		require.NoError(t, json.Unmarshal(result.Payload, &items))
+		for i, item := range items {
+			// the matching item may be drawn from contains, which has no oneOf
+			idx, ok := result.Meta.OneOf[fmt.Sprintf("/%d", i)]
+			if _, isString := item.(string); isString {
+				assert.True(t, !ok || idx == 1, result.Meta)
+			} else {
+				assert.True(t, ok && idx == 0, result.Meta)
+			}
+		}
+		assert.LessOrEqual(t, len(result.Meta.OneOf), len(items), result.Meta)
+	})
+
+	// items drawn again as duplicates leave no decisions behind
+	unique := GenFromSchemaWithMeta(mustSchema(t, `{
+		"type": "object",
+		"required": ["tags"],
+		"properties": {
+			"tags": {
+				"type": "array",
+				"uniqueItems": true,
+				"items": {"oneOf": [{"enum": ["a"]}, {"enum": ["b"]}]}
+			}
+		}
+	}`))
+	rapid.Check(t, func(rt *rapid.T) {
+		result := unique.Draw(rt, "result")
+		var obj struct {
+			Tags []string `json:"tags"`
+		}
+		require.NoError(t, json.Unmarshal(result.Payload, &obj))
+		require.Len(t, result.Meta.OneOf, len(obj.Tags), result.Meta)
+		for i, tag := range obj.Tags {
+			assert.Equal(t, []string{"a", "b"}[result.Meta.OneOf[fmt.Sprintf("/tags/%d", i)]], tag, result.Meta)
+		}
+	})
+}
+
+func TestGenFromSchemaWithMetaNull(t *testing.T) {
+	schema := mustSchema(t, `{"type": "string", "nullable": true}`)
+	gen := GenFromSchemaWithMeta(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		result := gen.Draw(rt, "result")
+		assert.Equal(t, string(result.Payload) == "null", result.Meta.Null)
+		assert.Empty(t, result.Meta.OneOf)
+	})
+}
+
+func TestConstDiscriminator(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: const discriminator
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Pet:
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+        - $ref: '#/components/schemas/Dog'
+      discriminator:
+        propertyName: type
+    Cat:
+      type: object
+      required: [type, lives]
+      properties:
+        type:
+          const: cat
+        lives:
+          type: integer
+          minimum: 1
+          maximum: 9
+    Dog:
+      type: object
+      required: [type]
+      properties:
+        type:
+          const: dog
+        goodBoy:
+          type: boolean
+`)
+	pet := doc.Components.Schemas["Pet"].Value
+	gen := GenFromSchemaWithMeta(pet)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		result := gen.Draw(rt, "pet")
+		var obj map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(result.Payload, &obj))
+
+		// the oneOf is the generated schema itself, so its choice is recorded at ""
+		want := []string{`"cat"`, `"dog"`}[result.Meta.OneOf[""]]
+		assert.Equal(t, want, string(obj["type"]))
+		if want == `"dog"` {
+			assert.NotContains(t, obj, "lives")
+		}
+	})
+
+	// a branch generated on its own always emits its const
+	cat := GenFromSchema(doc.Components.Schemas["Cat"].Value)
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(cat.Draw(rt, "cat"), &obj))
+		assert.Equal(t, `"cat"`, string(obj["type"]))
+	})
+}
@@ -0,0 +1,270 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// NewMockHandler returns an http.Handler that serves generated responses for the
+// operations of doc, see GenerationOptions.NewMockHandler
+func NewMockHandler(doc *openapi3.T) http.Handler {
+	return NewGenerationOptions().NewMockHandler(doc)
+}
+
+// NewMockHandler returns an http.Handler that mocks the API of doc: requests are
+// routed to the operation of their path and method, and answered with the lowest
+// declared 2xx status (else 2XX or default, as 200) and a response body generated in
+// ModeResponse. The media type of the body is the one of the response's media types
+// the Accept header prefers, application/json without Accept. Bodies are encoded as
+// GenAllMediaTypes encodes them. Paths may carry the path of a server URL as prefix.
+//
+// Unknown paths get 404, unknown methods 405, and requests accepting none of the
+// media types 406. Request bodies and parameters are not validated.
+func (opts *GenerationOptions) NewMockHandler(doc *openapi3.T) http.Handler {
+	mockOpts := *opts
+	mockOpts.Mode = ModeResponse
+
+	handler := &mockHandler{}
+	for _, server := range doc.Servers {
+		if u, err := url.Parse(server.URL); err == nil && !strings.Contains(u.Path, "{") {
+			if base := strings.TrimSuffix(u.Path, "/"); base != "" {
+				handler.basePaths = append(handler.basePaths, base)
+			}
+		}
+	}
+
+	var paths map[string]*openapi3.PathItem
+	if doc.Paths != nil {
+		paths = doc.Paths.Map()
+	}
+	for _, path := range sortedKeys(paths) {
+		route := mockRoute{path: path, ops: make(map[string]*mockOperation)}
+		route.pattern, route.params = pathTemplatePattern(path)
+		for method, op := range paths[path].Operations() {
+			pointer := "/paths/" + escapePointer(path) + "/" + strings.ToLower(method)
+			route.ops[method] = mockOpts.mockOperation(pointer, op)
+		}
+		handler.routes = append(handler.routes, route)
+	}
+	// literal path segments win over templated ones, e.g. /pets/mine over /pets/{id}
+	slices.SortStableFunc(handler.routes, func(a, b mockRoute) int { return a.params - b.params })
+	return handler
+}
+
+// mockHandler is the http.Handler of NewMockHandler
+type mockHandler struct {
+	routes []mockRoute
+	// basePaths are the paths of the server URLs, without trailing /
+	basePaths []string
+	// draws seeds each response differently
+	draws atomic.Int64
+}
+
+// mockRoute is a path of the spec, with its operations by method
+type mockRoute struct {
+	path    string
+	pattern *regexp.Regexp
+	// params is the number of path parameters
+	params int
+	ops    map[string]*mockOperation
+}
+
+// mockOperation is the success response of an operation
+type mockOperation struct {
+	status int
+	// mediaTypes are the keys of bodies, sorted
+	mediaTypes []string
+	bodies     map[string]mockBody
+}
+
+// mockBody generates and encodes the body of one response media type. gen is nil
+// for media types without a schema.
+type mockBody struct {
+	gen    *rapid.Generator[json.RawMessage]
+	encode func(json.RawMessage) []byte
+}
+
+// pathTemplateParam matches the parameters of path templates, e.g. {id}
+var pathTemplateParam = regexp.MustCompile(`\{[^{}/]+\}`)
+
+// pathTemplatePattern returns a regexp matching the paths of a path template, and
+// its number of parameters
+func pathTemplatePattern(path string) (*regexp.Regexp, int) {
+	literals := pathTemplateParam.Split(path, -1)
+	for i, literal := range literals {
+		literals[i] = regexp.QuoteMeta(literal)
+	}
+	return regexp.MustCompile("^" + strings.Join(literals, "[^/]+") + "$"), len(literals) - 1
+}
+
+// mockOperation prepares the generators of the success response of op, found at pointer
+func (opts *GenerationOptions) mockOperation(pointer string, op *openapi3.Operation) *mockOperation {
+	mock := &mockOperation{status: http.StatusOK, bodies: make(map[string]mockBody)}
+	if op.Responses == nil {
+		return mock
+	}
+	responses := op.Responses.Map()
+	code := ""
+	for _, candidate := range sortedKeys(responses) {
+		if status, err := strconv.Atoi(candidate); err == nil && status >= 200 && status < 300 {
+			code, mock.status = candidate, status
+			break
+		}
+	}
+	for _, fallback := range []string{"2XX", "default"} {
+		if _, ok := responses[fallback]; code == "" && ok {
+			code = fallback
+		}
+	}
+	response := responses[code]
+	if response == nil || response.Value == nil {
+		return mock
+	}
+
+	located := *opts
+	located.pointer = pointer + "/responses/" + code
+	if strings.HasPrefix(response.Ref, "#/") {
+		located.pointer = response.Ref[1:]
+	}
+	for mediaType, media := range response.Value.Content {
+		mock.mediaTypes = append(mock.mediaTypes, mediaType)
+		if media == nil || media.Schema == nil {
+			mock.bodies[mediaType] = mockBody{}
+			continue
+		}
+		schemaRef := media.Schema
+		mock.bodies[mediaType] = mockBody{
+			gen:    located.childAt(schemaRef, "content", mediaType, "schema").GenFromSchema(schemaRef.Value),
+			encode: mediaTypeEncoder(mediaType, schemaRef),
+		}
+	}
+	slices.Sort(mock.mediaTypes)
+	return mock
+}
+
+func (h *mockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route := h.route(r.URL.Path)
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+	op := route.ops[r.Method]
+	if op == nil {
+		w.Header().Set("Allow", strings.Join(sortedKeys(route.ops), ", "))
+		http.Error(w, fmt.Sprintf("%s has no %s operation", route.path, r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	if len(op.mediaTypes) == 0 {
+		w.WriteHeader(op.status)
+		return
+	}
+
+	mediaType := negotiateMediaType(op.mediaTypes, r.Header.Get("Accept"))
+	if mediaType == "" {
+		http.Error(w, fmt.Sprintf("none of the media types %s is acceptable", strings.Join(op.mediaTypes, ", ")), http.StatusNotAcceptable)
+		return
+	}
+	body := op.bodies[mediaType]
+	w.Header().Set("Content-Type", mediaType)
+	if body.gen == nil {
+		w.WriteHeader(op.status)
+		return
+	}
+	payload, err := drawMockBody(body.gen, int(h.draws.Add(1)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(op.status)
+	_, _ = w.Write(body.encode(payload))
+}
+
+// route returns the route matching path, with or without a server base path
+func (h *mockHandler) route(path string) *mockRoute {
+	candidates := []string{path}
+	for _, base := range h.basePaths {
+		if rest, ok := strings.CutPrefix(path, base); ok && strings.HasPrefix(rest, "/") {
+			candidates = append(candidates, rest)
+		}
+	}
+	for i := range h.routes {
+		for _, candidate := range candidates {
+			if h.routes[i].pattern.MatchString(candidate) {
+				return &h.routes[i]
+			}
+		}
+	}
+	return nil
+}
+
+// drawMockBody draws one value of gen, turning failed generation into an error
+func drawMockBody(gen *rapid.Generator[json.RawMessage], seed int) (payload json.RawMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("generating the response failed: %v", r)
+		}
+	}()
+	return gen.Example(seed), nil
+}
+
+// negotiateMediaType returns the media type of mediaTypes the Accept header accept
+// prefers, or "" if it accepts none of them. Without Accept, application/json is
+// preferred, else the first media type.
+func negotiateMediaType(mediaTypes []string, accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		if slices.Contains(mediaTypes, "application/json") {
+			return "application/json"
+		}
+		return mediaTypes[0]
+	}
+
+	type mediaRange struct {
+		value string
+		q     float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		r := mediaRange{value: strings.ToLower(strings.TrimSpace(value)), q: 1}
+		for _, param := range strings.Split(params, ";") {
+			if name, q, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(q), 64); err == nil {
+					r.q = parsed
+				}
+			}
+		}
+		if r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, r := range ranges {
+		for _, mediaType := range mediaTypes {
+			essence := mediaTypeEssence(mediaType)
+			if r.value == "*/*" || r.value == essence ||
+				strings.HasSuffix(r.value, "/*") && strings.HasPrefix(essence, strings.TrimSuffix(r.value, "*")) {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
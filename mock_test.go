@@ -0,0 +1,132 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mockSpec = `
+openapi: 3.0.3
+info:
+  title: pets
+  version: 1.0.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+    get:
+      responses:
+        '404':
+          description: not found
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+            application/xml:
+              schema:
+                $ref: '#/components/schemas/Pet'
+    delete:
+      responses:
+        '204':
+          description: deleted
+  /pets/mine:
+    get:
+      responses:
+        default:
+          description: ok
+          content:
+            text/plain:
+              schema:
+                type: string
+                enum: [mine]
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      properties:
+        id:
+          type: integer
+          minimum: 1
+          readOnly: true
+        name:
+          type: string
+        secret:
+          type: string
+          writeOnly: true
+`
+
+func mockRequest(t *testing.T, server *httptest.Server, method, path, accept string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	require.NoError(t, err)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestNewMockHandler(t *testing.T) {
+	doc := readSpecString(t, mockSpec)
+	server := httptest.NewServer(NewMockHandler(doc))
+	defer server.Close()
+	petSchema := doc.Components.Schemas["Pet"].Value
+
+	for range 20 {
+		resp, body := mockRequest(t, server, http.MethodGet, "/pets/42", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var pet map[string]any
+		require.NoError(t, json.Unmarshal(body, &pet), string(body))
+		assert.NoError(t, petSchema.VisitJSON(pet, openapi3.VisitAsResponse()), string(body))
+		assert.NotContains(t, pet, "secret", "writeOnly properties are not in responses")
+	}
+
+	resp, body := mockRequest(t, server, http.MethodGet, "/v1/pets/42", "text/html;q=0.9, application/xml")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/xml", resp.Header.Get("Content-Type"))
+	var pet struct {
+		XMLName xml.Name
+		Name    string `xml:"name"`
+	}
+	assert.NoError(t, xml.Unmarshal(body, &pet), string(body))
+	assert.Equal(t, "Pet", pet.XMLName.Local)
+
+	resp, body = mockRequest(t, server, http.MethodGet, "/pets/mine", "text/*")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "mine", string(body), "literal paths win over templates")
+
+	resp, body = mockRequest(t, server, http.MethodDelete, "/pets/42", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, body)
+
+	resp, _ = mockRequest(t, server, http.MethodGet, "/pets/42", "text/html")
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+
+	resp, _ = mockRequest(t, server, http.MethodPost, "/pets/42", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "DELETE, GET", resp.Header.Get("Allow"))
+
+	resp, _ = mockRequest(t, server, http.MethodGet, "/owners", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
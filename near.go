@@ -0,0 +1,150 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"maps"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// GenNear generates variations of base, a payload valid against schema, see
+// GenerationOptions.GenNear
+func GenNear(schema *openapi3.Schema, base json.RawMessage) *rapid.Generator[json.RawMessage] {
+	return NewGenerationOptions().GenNear(schema, base)
+}
+
+// GenNear generates variations of base, a payload valid against schema, e.g. to
+// explore the neighbourhood of a failing payload. The variations keep the structure
+// of base: objects have the same properties, arrays the same length, oneOf and anyOf
+// values the branch base matches, with the discriminator property unchanged, and
+// null stays null. Scalar values are drawn anew. Where base does not match the
+// schema, or under allOf, uniqueItems and contains, values are drawn from the schema.
+func (opts *GenerationOptions) GenNear(schema *openapi3.Schema, base json.RawMessage) *rapid.Generator[json.RawMessage] {
+	var value any
+	if err := json.Unmarshal(base, &value); err != nil {
+		return genFail("base is not valid JSON: " + err.Error())
+	}
+	return opts.GenFromSchema(nearSchema(schema, value))
+}
+
+// nearSchema narrows schema to the values with the structure of value
+func nearSchema(schema *openapi3.Schema, value any) *openapi3.Schema {
+	if schema == nil {
+		schema = &openapi3.Schema{}
+	}
+	if value == nil {
+		return &openapi3.Schema{Type: getType("null")}
+	}
+	if len(schema.Enum) > 0 || hasKeyword(schema, "const") || len(schema.AllOf) > 0 {
+		return schema
+	}
+	if len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 {
+		return nearBranch(schema, value)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if schema.Type == nil || schema.Type.Is("object") {
+			return nearObject(schema, v)
+		}
+	case []any:
+		if schema.Type == nil || schema.Type.Is("array") {
+			return nearArray(schema, v)
+		}
+	}
+	return schema
+}
+
+// nearBranch narrows a oneOf or anyOf schema to the first branch value is valid
+// against. Schemas with properties of their own next to the branches are kept.
+func nearBranch(schema *openapi3.Schema, value any) *openapi3.Schema {
+	if len(schema.Properties) > 0 {
+		return schema
+	}
+	for _, branch := range append(append(openapi3.SchemaRefs{}, schema.OneOf...), schema.AnyOf...) {
+		if branch == nil || branch.Value == nil || branch.Value.VisitJSON(value) != nil {
+			continue
+		}
+		narrowed := nearSchema(branch.Value, value)
+		obj, isObject := value.(map[string]any)
+		if schema.Discriminator == nil || !isObject || narrowed == branch.Value || narrowed.Properties == nil {
+			return narrowed
+		}
+		// the discriminator names the branch, so it keeps its value
+		name := schema.Discriminator.PropertyName
+		if tag, ok := obj[name]; ok {
+			pinned := &openapi3.Schema{Enum: []any{tag}}
+			narrowed.Properties[name] = &openapi3.SchemaRef{Value: pinned}
+		}
+		return narrowed
+	}
+	return schema
+}
+
+// nearObject narrows an object schema to objects with exactly the properties of obj.
+// Properties that are not declared get the schema of the patternProperties they
+// match, or of additionalProperties.
+func nearObject(schema *openapi3.Schema, obj map[string]any) *openapi3.Schema {
+	target := *schema
+	target.Properties = make(openapi3.Schemas, len(obj))
+	target.Required = nil
+	target.AdditionalProperties = openapi3.AdditionalProperties{Has: openapi3.BoolPtr(false)}
+	target.Extensions = maps.Clone(schema.Extensions)
+	delete(target.Extensions, "patternProperties")
+	delete(target.Extensions, "unevaluatedProperties")
+
+	patternProps := patternProperties(schema)
+	for _, name := range sortedKeys(obj) {
+		prop := schema.Properties[name]
+		if prop == nil {
+			if matched := matchingPatterns(patternProps, name); matched != nil {
+				prop = &openapi3.SchemaRef{Value: matched[0].schema}
+			} else {
+				prop = schema.AdditionalProperties.Schema
+			}
+		}
+		var propSchema *openapi3.Schema
+		ref := ""
+		if prop != nil {
+			propSchema, ref = prop.Value, prop.Ref
+		}
+		target.Properties[name] = &openapi3.SchemaRef{Ref: ref, Value: nearSchema(propSchema, obj[name])}
+		target.Required = append(target.Required, name)
+	}
+	return &target
+}
+
+// nearArray narrows an array schema to arrays as long as arr, whose items have the
+// structure of the items of arr at the same position
+func nearArray(schema *openapi3.Schema, arr []any) *openapi3.Schema {
+	target := *schema
+	length := uint64(len(arr))
+	target.MinItems, target.MaxItems = length, &length
+	if schema.UniqueItems || hasKeyword(schema, "contains") {
+		return &target
+	}
+
+	rawPrefix, _ := keyword(schema, "prefixItems")
+	prefix, _ := rawPrefix.([]any)
+	var items *openapi3.Schema
+	if schema.Items != nil {
+		items = schema.Items.Value
+	}
+	narrowed := make([]any, len(arr))
+	for i, item := range arr {
+		itemSchema := items
+		if i < len(prefix) {
+			itemSchema = subSchema(prefix[i])
+		}
+		narrowed[i] = nearSchema(itemSchema, item)
+	}
+	target.Extensions = maps.Clone(schema.Extensions)
+	if target.Extensions == nil {
+		target.Extensions = make(map[string]any)
+	}
+	target.Extensions["prefixItems"] = narrowed
+	target.Items = &openapi3.SchemaRef{Value: subSchema(false)}
+	delete(target.Extensions, "additionalItems")
+	return &target
+}
@@ -0,0 +1,122 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+// shape replaces the scalars of a decoded JSON value by their kind
+func shape(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		shaped := make(map[string]any, len(v))
+		for key, item := range v {
+			shaped[key] = shape(item)
+		}
+		return shaped
+	case []any:
+		shaped := make([]any, len(v))
+		for i, item := range v {
+			shaped[i] = shape(item)
+		}
+		return shaped
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return value
+}
+
+func TestGenNear(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: near
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Owner:
+      type: object
+      required: [pets]
+      properties:
+        name:
+          type: string
+        nickname:
+          type: string
+          nullable: true
+        age:
+          type: integer
+          minimum: 0
+        pets:
+          type: array
+          items:
+            $ref: '#/components/schemas/Pet'
+    Pet:
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+        - $ref: '#/components/schemas/Dog'
+      discriminator:
+        propertyName: kind
+    Cat:
+      type: object
+      required: [kind, lives]
+      properties:
+        kind:
+          type: string
+        lives:
+          type: integer
+          minimum: 1
+          maximum: 9
+    Dog:
+      type: object
+      required: [kind, breed]
+      properties:
+        kind:
+          type: string
+        breed:
+          type: string
+        tags:
+          type: array
+          items:
+            type: string
+`)
+	schema := doc.Components.Schemas["Owner"].Value
+	base := json.RawMessage(`{
+		"nickname": null,
+		"age": 7,
+		"pets": [
+			{"kind": "Dog", "breed": "collie", "tags": ["a", "b"]},
+			{"kind": "Cat", "lives": 3}
+		]
+	}`)
+	var baseValue any
+	require.NoError(t, json.Unmarshal(base, &baseValue))
+	gen := GenNear(schema, base)
+
+	sawVariation := false
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		var value any
+		require.NoError(t, json.Unmarshal(payload, &value))
+		assert.NoError(t, schema.VisitJSON(value), string(payload))
+		assert.Equal(t, shape(baseValue), shape(value), string(payload))
+
+		pets := value.(map[string]any)["pets"].([]any)
+		assert.Equal(t, "Dog", pets[0].(map[string]any)["kind"])
+		assert.Equal(t, "Cat", pets[1].(map[string]any)["kind"])
+		sawVariation = sawVariation || value.(map[string]any)["age"] != 7.0
+	})
+	assert.True(t, sawVariation, "leaf values vary")
+
+	assert.Panics(t, func() { GenNear(schema, json.RawMessage(`{`)).Example(0) })
+}
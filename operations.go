@@ -0,0 +1,106 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// OperationInfo describes one operation of a spec and whether SpecSmash can
+// generate its request bodies
+type OperationInfo struct {
+	Path   string
+	Method string
+	// OperationID is empty if the operation has none
+	OperationID string
+	// HasBodySchema reports whether GetSchema finds a JSON request body schema
+	// to generate bodies from
+	HasBodySchema bool
+	// MediaTypes are the request body media types, sorted. Without HasBodySchema,
+	// they are the reason the operation is skipped.
+	MediaTypes []string
+}
+
+// ListOperations returns every operation of doc, ordered by path and method, so
+// the testable surface of a spec can be discovered before generating anything
+func ListOperations(doc *openapi3.T) []OperationInfo {
+	var infos []OperationInfo
+	if doc.Paths == nil {
+		return infos
+	}
+	for _, path := range sortedKeys(doc.Paths.Map()) {
+		ops := doc.Paths.Value(path).Operations()
+		for _, method := range sortedKeys(ops) {
+			op := ops[method]
+			info := OperationInfo{Path: path, Method: method, OperationID: op.OperationID}
+			if schema, ok := GetSchema(op, doc); ok && schema != nil {
+				info.HasBodySchema = true
+			}
+			if body := resolveRequestBody(op, doc); body != nil {
+				info.MediaTypes = sortedKeys(body.Content)
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// GenForOperationID returns a generator of request bodies for the operation with
+// the given operationId, see GenerationOptions.GenForOperationID
+func GenForOperationID(doc *openapi3.T, opID string) (*rapid.Generator[json.RawMessage], error) {
+	return NewGenerationOptions().GenForOperationID(doc, opID)
+}
+
+// GenForOperationID returns a generator of request bodies for the operation with
+// the given operationId, among the paths and webhooks of doc. Overrides apply at
+// the body schema's JSON pointer. It fails if no operation has the operationId or
+// the operation has no JSON request body schema (see GetSchema).
+func (opts *GenerationOptions) GenForOperationID(doc *openapi3.T, opID string) (*rapid.Generator[json.RawMessage], error) {
+	pointer, op := findOperation(doc, opID)
+	if op == nil {
+		return nil, fmt.Errorf("no operation with operationId '%s'", opID)
+	}
+	schema, ok := GetSchema(op, doc)
+	if !ok || schema == nil || schema.Value == nil {
+		return nil, fmt.Errorf("operation '%s' has no JSON request body schema", opID)
+	}
+
+	if strings.HasPrefix(schema.Ref, "#/") {
+		pointer = schema.Ref[1:]
+	} else {
+		mediaType := "application/json"
+		if _, ok := resolveRequestBody(op, doc).Content[mediaType]; !ok {
+			mediaType = mergePatchMediaType
+		}
+		pointer += "/requestBody"
+		if strings.HasPrefix(op.RequestBody.Ref, "#/") {
+			// the schema is located in the shared request body
+			pointer = op.RequestBody.Ref[1:]
+		}
+		pointer += "/content/" + escapePointer(mediaType) + "/schema"
+	}
+	return opts.GenFromSchemaAt(pointer, schema.Value), nil
+}
+
+// findOperation returns the operation with operationId opID and its JSON pointer,
+// or nil if there is none
+func findOperation(doc *openapi3.T, opID string) (string, *openapi3.Operation) {
+	sections := map[string]map[string]*openapi3.PathItem{"/webhooks/": Webhooks(doc)}
+	if doc.Paths != nil {
+		sections["/paths/"] = doc.Paths.Map()
+	}
+	for _, prefix := range sortedKeys(sections) {
+		items := sections[prefix]
+		for _, name := range sortedKeys(items) {
+			for method, op := range items[name].Operations() {
+				if op.OperationID == opID {
+					return prefix + escapePointer(name) + "/" + strings.ToLower(method), op
+				}
+			}
+		}
+	}
+	return "", nil
+}
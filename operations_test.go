@@ -0,0 +1,184 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestListOperations(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: operations
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        '200':
+          description: ok
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+          application/xml:
+            schema:
+              type: object
+      responses:
+        '201':
+          description: created
+  /pets/{id}/photo:
+    put:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+      requestBody:
+        content:
+          image/png:
+            schema:
+              type: string
+              format: binary
+      responses:
+        '204':
+          description: stored
+`)
+
+	assert.Equal(t, []OperationInfo{
+		{Path: "/pets", Method: "GET", OperationID: "listPets"},
+		{Path: "/pets", Method: "POST", OperationID: "createPet", HasBodySchema: true, MediaTypes: []string{"application/json", "application/xml"}},
+		{Path: "/pets/{id}/photo", Method: "PUT", MediaTypes: []string{"image/png"}},
+	}, ListOperations(doc))
+}
+
+func TestGenForOperationID(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: operations
+  version: 1.0.0
+paths:
+  /events:
+    get:
+      operationId: listEvents
+      responses:
+        '200':
+          description: ok
+    post:
+      operationId: createEvent
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name:
+                  type: string
+                  enum: [launch, landing]
+      responses:
+        '201':
+          description: created
+`)
+
+	gen, err := GenForOperationID(doc, "createEvent")
+	require.NoError(t, err)
+	op := doc.Paths.Value("/events").Post
+	rapid.Check(t, func(rt *rapid.T) {
+		assert.NoError(t, ValidatePayload(rt.Context(), gen.Draw(rt, "body"), "/events", op))
+	})
+
+	// overrides apply at the body schema's pointer
+	gen, err = NewGenerationOptions().
+		WithOverride("/paths/~1events/post/requestBody/content/application~1json/schema/properties/name", rapid.Just(json.RawMessage(`"landing"`))).
+		GenForOperationID(doc, "createEvent")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "landing"}`, string(gen.Example(0)))
+
+	_, err = GenForOperationID(doc, "listEvents")
+	assert.ErrorContains(t, err, "has no JSON request body schema")
+	_, err = GenForOperationID(doc, "deleteEvent")
+	assert.ErrorContains(t, err, "no operation with operationId 'deleteEvent'")
+}
+
+func TestSharedRequestBodies(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: shared bodies
+  version: 1.0.0
+paths:
+  /foos:
+    post:
+      operationId: createFoo
+      requestBody:
+        $ref: '#/components/requestBodies/CreateFoo'
+      responses:
+        '201':
+          description: created
+components:
+  requestBodies:
+    CreateFoo:
+      content:
+        application/json:
+          schema:
+            type: object
+            required: [size]
+            properties:
+              size:
+                type: integer
+                minimum: 10
+                maximum: 1
+`)
+	op := doc.Paths.Value("/foos").Post
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+	require.NotNil(t, schema.Value)
+
+	// a request body whose $ref was not loaded is looked up in the document
+	unresolved := &openapi3.Operation{RequestBody: &openapi3.RequestBodyRef{Ref: op.RequestBody.Ref}}
+	_, ok = GetSchema(unresolved)
+	assert.False(t, ok)
+	schema, ok = GetSchema(unresolved, doc)
+	require.True(t, ok)
+	assert.Equal(t, []string{"size"}, schema.Value.Required)
+
+	// payloads for it are validated against the body looked up in doc
+	assert.ErrorContains(t, ValidatePayloadInDoc(t.Context(), []byte(`{"size": 5}`), "/foos", unresolved, doc, nil), "number must be at least 10")
+	assert.ErrorContains(t, ValidatePayload(t.Context(), []byte(`{"size": 5}`), "/foos", unresolved), "operation has no request body")
+	assert.Empty(t, ValidateAllMediaTypes([]byte(`{}`), unresolved))
+	results := ValidateAllMediaTypes([]byte(`{"size": 5}`), unresolved, doc)
+	require.Contains(t, results, "application/json")
+	assert.ErrorContains(t, results["application/json"], "number must be at least 10")
+	gens := NewGenerationOptions().
+		WithOverride("/components/requestBodies/CreateFoo/content/application~1json/schema/properties/size", rapid.Just(json.RawMessage(`5`))).
+		GenAllMediaTypes(unresolved, doc)
+	require.Contains(t, gens, "application/json")
+	assert.JSONEq(t, `{"size": 5}`, string(gens["application/json"].Example(0)))
+
+	// overrides apply at the schema's pointer within the shared request body
+	gen, err := NewGenerationOptions().
+		WithOverride("/components/requestBodies/CreateFoo/content/application~1json/schema/properties/size", rapid.Just(json.RawMessage(`5`))).
+		GenForOperationID(doc, "createFoo")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"size": 5}`, string(gen.Example(0)))
+
+	assert.Equal(t, []OperationInfo{
+		{Path: "/foos", Method: "POST", OperationID: "createFoo", HasBodySchema: true, MediaTypes: []string{"application/json"}},
+	}, ListOperations(doc))
+	assert.Contains(t, AnalyzeSpec(doc), SpecIssue{
+		Pointer: "/components/requestBodies/CreateFoo/content/application~1json/schema/properties/size",
+		Message: "no number lies between minimum 10 and maximum 1",
+	})
+}
@@ -0,0 +1,86 @@
+package SpecSmash
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-openapi/jsonpointer"
+	"gopkg.in/yaml.v3"
+)
+
+// recordPropertyOrigins stores where every property of the loaded document is
+// declared in the Origin of its SchemaRef, for WithPropertyOrder. data is the
+// source of doc, YAML or JSON. Properties in external files are not recorded.
+func recordPropertyOrigins(data []byte, doc *openapi3.T) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return
+	}
+	recordNodeOrigins(root.Content[0], "", doc)
+}
+
+func recordNodeOrigins(node *yaml.Node, pointer string, doc *openapi3.T) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			recordNodeOrigins(item, pointer+"/"+strconv.Itoa(i), doc)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			switch key.Value {
+			case "example", "examples", "enum", "default", "const":
+				// values, not schemas
+				continue
+			case "properties":
+				if value.Kind == yaml.MappingNode {
+					recordProperties(value, pointer, doc)
+				}
+			}
+			recordNodeOrigins(value, pointer+"/"+escapePointer(key.Value), doc)
+		}
+	}
+}
+
+func recordProperties(properties *yaml.Node, pointer string, doc *openapi3.T) {
+	ptr, err := jsonpointer.New(pointer)
+	if err != nil {
+		return
+	}
+	found, _, err := ptr.Get(doc)
+	schema, ok := found.(*openapi3.Schema)
+	if err != nil || !ok {
+		return
+	}
+	for i := 0; i+1 < len(properties.Content); i += 2 {
+		key := properties.Content[i]
+		if ref := schema.Properties[key.Value]; ref != nil && ref.Origin == nil {
+			ref.Origin = &openapi3.Origin{Key: &openapi3.Location{Line: key.Line, Column: key.Column}}
+		}
+	}
+}
+
+// declaredOrder returns the properties of schema in the order they are declared in
+// the spec, as recorded by ReadSpec. Properties without a recorded origin come last,
+// sorted by name.
+func declaredOrder(schema *openapi3.Schema) []string {
+	location := func(name string) (openapi3.Location, bool) {
+		prop := schema.Properties[name]
+		if prop == nil || prop.Origin == nil || prop.Origin.Key == nil {
+			return openapi3.Location{}, false
+		}
+		return *prop.Origin.Key, true
+	}
+
+	names := sortedKeys(schema.Properties)
+	sort.SliceStable(names, func(i, j int) bool {
+		li, iok := location(names[i])
+		lj, jok := location(names[j])
+		if iok != jok {
+			return iok
+		}
+		return li.Line < lj.Line || li.Line == lj.Line && li.Column < lj.Column
+	})
+	return names
+}
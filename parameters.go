@@ -0,0 +1,246 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"pgregory.net/rapid"
+)
+
+// ParameterValues holds generated parameter values by location ("path", "query",
+// "header" or "cookie") and then by parameter name. An empty value is a query
+// parameter with allowEmptyValue that is present without a value.
+type ParameterValues map[string]map[string]json.RawMessage
+
+// GenParameters generates values for the parameters of op. Required parameters are
+// always present, optional ones only sometimes. Parameters given as a $ref to
+// components/parameters are resolved by the loader, so load the spec with ReadSpec.
+// Pass the path item of op to include the parameters declared for all its
+// operations; op's own parameters override them by name and location.
+func (opts *GenerationOptions) GenParameters(op *openapi3.Operation, pathItem ...*openapi3.PathItem) *rapid.Generator[ParameterValues] {
+	type parameterGen struct {
+		param *openapi3.Parameter
+		gen   *rapid.Generator[json.RawMessage]
+	}
+
+	var gens []parameterGen
+	for i, ref := range operationParameters(op, pathItem) {
+		if ref == nil || ref.Value == nil {
+			return rapid.Custom(func(t *rapid.T) ParameterValues {
+				panic(fmt.Sprintf("parameter %d is not resolved, was the spec loaded with ReadSpec?", i))
+			})
+		}
+		param := ref.Value
+		if param.Schema == nil {
+			// parameters described by content instead of schema are not generated
+			continue
+		}
+
+		located := *opts
+		if strings.HasPrefix(ref.Ref, "#/") {
+			located.pointer = ref.Ref[1:]
+		}
+		gens = append(gens, parameterGen{
+			param: param,
+			gen:   located.childAt(param.Schema, "schema").GenFromSchema(param.Schema.Value),
+		})
+	}
+
+	return rapid.Custom(func(t *rapid.T) ParameterValues {
+		values := make(ParameterValues)
+		if len(gens) == 0 {
+			// rapid requires every draw to use its data, even without parameters
+			rapid.Just(values).Draw(t, "No parameters")
+			return values
+		}
+		for _, pg := range gens {
+			label := pg.param.In + "-" + pg.param.Name
+			if !pg.param.Required && !rapid.Bool().Draw(t, label+"-present") {
+				continue
+			}
+			if values[pg.param.In] == nil {
+				values[pg.param.In] = make(map[string]json.RawMessage)
+			}
+			if pg.param.In == openapi3.ParameterInQuery && pg.param.AllowEmptyValue && rapid.Bool().Draw(t, label+"-empty") {
+				values[pg.param.In][pg.param.Name] = json.RawMessage{}
+				continue
+			}
+			values[pg.param.In][pg.param.Name] = pg.gen.Draw(t, label)
+		}
+		return values
+	})
+}
+
+// QueryString encodes the query parameters of values as the query string of a
+// request to op, following each parameter's style and explode. Pass the path item
+// of op if the values include its parameters, see GenParameters. Empty values are
+// written as name=. Values of allowReserved parameters keep the reserved characters
+// that do not delimit query parameters (:/?@!$'()*,[]) unencoded. ';' stays encoded
+// because net/url rejects it in query strings.
+func QueryString(op *openapi3.Operation, values ParameterValues, pathItem ...*openapi3.PathItem) string {
+	var pairs []string
+	add := func(param *openapi3.Parameter, key, value string) {
+		pairs = append(pairs, url.QueryEscape(key)+"="+queryEscape(value, param.AllowReserved))
+	}
+
+	query := values[openapi3.ParameterInQuery]
+	for _, ref := range operationParameters(op, pathItem) {
+		if ref == nil || ref.Value == nil || ref.Value.In != openapi3.ParameterInQuery {
+			continue
+		}
+		param := ref.Value
+		raw, ok := query[param.Name]
+		if !ok {
+			continue
+		}
+		if len(raw) == 0 {
+			pairs = append(pairs, url.QueryEscape(param.Name)+"=")
+			continue
+		}
+
+		explode := param.Explode == nil || *param.Explode
+		var items []json.RawMessage
+		var obj map[string]json.RawMessage
+		switch {
+		case json.Unmarshal(raw, &items) == nil:
+			texts := make([]string, len(items))
+			for i, item := range items {
+				texts[i] = scalarText(item)
+			}
+			if explode && param.Style != "spaceDelimited" && param.Style != "pipeDelimited" {
+				for _, text := range texts {
+					add(param, param.Name, text)
+				}
+				continue
+			}
+			separator := map[string]string{"spaceDelimited": " ", "pipeDelimited": "|"}[param.Style]
+			if separator == "" {
+				separator = ","
+			}
+			add(param, param.Name, strings.Join(texts, separator))
+		case json.Unmarshal(raw, &obj) == nil:
+			keys := sortedKeys(obj)
+			switch {
+			case param.Style == "deepObject":
+				for _, key := range keys {
+					add(param, param.Name+"["+key+"]", scalarText(obj[key]))
+				}
+			case explode:
+				for _, key := range keys {
+					add(param, key, scalarText(obj[key]))
+				}
+			default:
+				var flat []string
+				for _, key := range keys {
+					flat = append(flat, key, scalarText(obj[key]))
+				}
+				add(param, param.Name, strings.Join(flat, ","))
+			}
+		default:
+			add(param, param.Name, scalarText(raw))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// PathString fills the parameters of the path template path, e.g. /users/{id}, with
+// the path parameters of values, following each parameter's style and explode:
+// simple (/users/42), label (/users/.42) or matrix (/users/;id=42). Array items and
+// object keys and values are separated by commas, or by the exploded separator.
+// Pass the path item of op if the values include its parameters, see GenParameters.
+// Parameters without a value are left as they are.
+func PathString(path string, op *openapi3.Operation, values ParameterValues, pathItem ...*openapi3.PathItem) string {
+	texts := make(map[string]string)
+	for _, ref := range operationParameters(op, pathItem) {
+		if ref == nil || ref.Value == nil || ref.Value.In != openapi3.ParameterInPath {
+			continue
+		}
+		if raw, ok := values[openapi3.ParameterInPath][ref.Value.Name]; ok {
+			texts[ref.Value.Name] = pathText(ref.Value, raw)
+		}
+	}
+	return pathTemplateParam.ReplaceAllStringFunc(path, func(template string) string {
+		if text, ok := texts[template[1:len(template)-1]]; ok {
+			return text
+		}
+		return template
+	})
+}
+
+// pathText encodes the value of the path parameter param in its style
+func pathText(param *openapi3.Parameter, raw json.RawMessage) string {
+	explode := param.Explode != nil && *param.Explode
+	prefix, separator := "", ","
+	switch param.Style {
+	case "label":
+		prefix = "."
+		if explode {
+			separator = "."
+		}
+	case "matrix":
+		prefix = ";" + url.PathEscape(param.Name) + "="
+		if explode {
+			separator = prefix
+		}
+	}
+
+	var items []json.RawMessage
+	var obj map[string]json.RawMessage
+	var texts []string
+	switch {
+	case json.Unmarshal(raw, &items) == nil:
+		for _, item := range items {
+			texts = append(texts, url.PathEscape(scalarText(item)))
+		}
+	case json.Unmarshal(raw, &obj) == nil:
+		for _, key := range sortedKeys(obj) {
+			if explode {
+				texts = append(texts, url.PathEscape(key)+"="+url.PathEscape(scalarText(obj[key])))
+			} else {
+				texts = append(texts, url.PathEscape(key), url.PathEscape(scalarText(obj[key])))
+			}
+		}
+		if explode && param.Style == "matrix" {
+			// exploded matrix objects name each key instead of the parameter
+			prefix, separator = ";", ";"
+		}
+	default:
+		texts = []string{url.PathEscape(scalarText(raw))}
+	}
+	return prefix + strings.Join(texts, separator)
+}
+
+// operationParameters returns the parameters of op, followed by those of the path
+// items that op does not override with a parameter of the same name and location
+func operationParameters(op *openapi3.Operation, pathItems []*openapi3.PathItem) openapi3.Parameters {
+	params := op.Parameters
+	for _, item := range pathItems {
+		if item == nil {
+			continue
+		}
+		for _, ref := range item.Parameters {
+			if ref != nil && ref.Value != nil && params.GetByInAndName(ref.Value.In, ref.Value.Name) != nil {
+				continue
+			}
+			params = append(slices.Clip(params), ref)
+		}
+	}
+	return params
+}
+
+// queryEscape percent-encodes a query value, leaving the reserved characters
+// that are safe inside a value unencoded when allowReserved is set
+func queryEscape(value string, allowReserved bool) string {
+	escaped := url.QueryEscape(value)
+	if !allowReserved {
+		return escaped
+	}
+	for _, c := range ":/?@!$'()*,[]" {
+		escaped = strings.ReplaceAll(escaped, url.QueryEscape(string(c)), string(c))
+	}
+	return escaped
+}
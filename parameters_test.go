@@ -0,0 +1,397 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestGenParametersResolvesRefs(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: parameters
+  version: 1.0.0
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+            minimum: 1
+        - $ref: '#/components/parameters/PageSize'
+        - $ref: '#/components/parameters/Trace'
+      responses:
+        '200':
+          description: ok
+components:
+  parameters:
+    PageSize:
+      name: pageSize
+      in: query
+      required: true
+      schema:
+        type: integer
+        minimum: 1
+        maximum: 100
+    Trace:
+      name: X-Trace
+      in: header
+      schema:
+        type: string
+        enum: [on, off]
+`)
+	op := doc.Paths.Value("/users/{id}").Get
+	gen := NewGenerationOptions().GenParameters(op)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		values := gen.Draw(rt, "parameters")
+
+		var id, pageSize int
+		require.NoError(t, json.Unmarshal(values["path"]["id"], &id))
+		require.NoError(t, json.Unmarshal(values["query"]["pageSize"], &pageSize))
+		assert.GreaterOrEqual(t, id, 1)
+		assert.GreaterOrEqual(t, pageSize, 1)
+		assert.LessOrEqual(t, pageSize, 100)
+
+		if trace, ok := values["header"]["X-Trace"]; ok {
+			assert.Contains(t, []string{`"on"`, `"off"`}, string(trace))
+		}
+	})
+}
+
+func TestGenParametersOverrideByComponentPointer(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: parameters
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      parameters:
+        - $ref: '#/components/parameters/PageSize'
+      responses:
+        '200':
+          description: ok
+components:
+  parameters:
+    PageSize:
+      name: pageSize
+      in: query
+      required: true
+      schema:
+        type: integer
+`)
+	opts := NewGenerationOptions().WithOverride("/components/parameters/PageSize/schema", rapid.Just(json.RawMessage("7")))
+	values := opts.GenParameters(doc.Paths.Value("/users").Get).Example()
+	assert.JSONEq(t, "7", string(values["query"]["pageSize"]))
+}
+
+func TestQueryStringAllowEmptyValueAndAllowReserved(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: query
+  version: 1.0.0
+paths:
+  /search:
+    get:
+      parameters:
+        - name: q
+          in: query
+          required: true
+          allowEmptyValue: true
+          schema:
+            type: string
+            minLength: 1
+        - name: redirect
+          in: query
+          required: true
+          allowReserved: true
+          schema:
+            type: string
+            enum: ["/a/b?c=d", "https://example.com/x;y", "a b"]
+        - name: ids
+          in: query
+          schema:
+            type: array
+            items:
+              type: integer
+              minimum: 0
+        - name: filter
+          in: query
+          style: deepObject
+          schema:
+            type: object
+            additionalProperties: false
+            properties:
+              color:
+                type: string
+                enum: [red, green]
+      responses:
+        '200':
+          description: ok
+`)
+	op := doc.Paths.Value("/search").Get
+	gen := NewGenerationOptions().GenParameters(op)
+
+	var sawEmpty, sawValue bool
+	rapid.Check(t, func(rt *rapid.T) {
+		values := gen.Draw(rt, "parameters")
+		query := QueryString(op, values)
+
+		if len(values["query"]["q"]) == 0 {
+			sawEmpty = true
+			assert.Contains(t, "&"+query+"&", "&q=&")
+		} else {
+			sawValue = true
+		}
+		// reserved characters are not percent-encoded, the delimiters still are
+		for _, pair := range strings.Split(query, "&") {
+			if strings.HasPrefix(pair, "redirect=") {
+				assert.NotContains(t, pair, "%2F")
+				assert.NotContains(t, pair, "%3A")
+				assert.NotContains(t, pair, "%3F")
+			}
+		}
+
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/search", RawQuery: query}}
+		input := &openapi3filter.RequestValidationInput{Request: req}
+		for _, ref := range op.Parameters {
+			assert.NoError(t, openapi3filter.ValidateParameter(rt.Context(), input, ref.Value), query)
+		}
+		parsed, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		var redirect string
+		require.NoError(t, json.Unmarshal(values["query"]["redirect"], &redirect))
+		assert.Equal(t, redirect, parsed.Get("redirect"))
+	})
+	assert.True(t, sawEmpty, "allowEmptyValue never produced an empty value")
+	assert.True(t, sawValue)
+}
+
+func TestGenParametersMergesPathItemParameters(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: parameters
+  version: 1.0.0
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+          minimum: 1
+      - name: verbose
+        in: query
+        required: true
+        schema:
+          type: boolean
+    get:
+      parameters:
+        - name: verbose
+          in: query
+          required: true
+          schema:
+            type: string
+            enum: [full]
+      responses:
+        '200':
+          description: ok
+    delete:
+      responses:
+        '204':
+          description: deleted
+`)
+	item := doc.Paths.Value("/users/{id}")
+
+	rapid.Check(t, func(rt *rapid.T) {
+		values := NewGenerationOptions().GenParameters(item.Get, item).Draw(rt, "get")
+		var id int
+		require.NoError(t, json.Unmarshal(values["path"]["id"], &id))
+		assert.GreaterOrEqual(t, id, 1)
+		assert.Equal(t, `"full"`, string(values["query"]["verbose"]), "the operation overrides the path item")
+		assert.Equal(t, "verbose=full", QueryString(item.Get, values, item))
+
+		values = NewGenerationOptions().GenParameters(item.Delete, item).Draw(rt, "delete")
+		assert.Contains(t, values["path"], "id")
+		assert.Contains(t, []string{"true", "false"}, string(values["query"]["verbose"]))
+	})
+
+	// without the path item, only the operation's own parameters are generated
+	values := NewGenerationOptions().GenParameters(item.Delete).Example()
+	assert.Empty(t, values)
+}
+
+func TestPathString(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: paths
+  version: 1.0.0
+paths:
+  /users/{id}/files/{name}{tags}{coords}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+        - name: name
+          in: path
+          required: true
+          style: label
+          schema:
+            type: string
+        - name: tags
+          in: path
+          required: true
+          style: matrix
+          explode: true
+          schema:
+            type: array
+            items:
+              type: string
+        - name: coords
+          in: path
+          required: true
+          style: matrix
+          schema:
+            type: object
+            properties:
+              x:
+                type: integer
+              y:
+                type: integer
+      responses:
+        '200':
+          description: ok
+`)
+	op := doc.Paths.Value("/users/{id}/files/{name}{tags}{coords}").Get
+	values := ParameterValues{"path": {
+		"id":     json.RawMessage(`42`),
+		"name":   json.RawMessage(`"a b"`),
+		"tags":   json.RawMessage(`["x", "y"]`),
+		"coords": json.RawMessage(`{"y": 2, "x": 1}`),
+	}}
+	assert.Equal(t, "/users/42/files/.a%20b;tags=x;tags=y;coords=x,1,y,2",
+		PathString("/users/{id}/files/{name}{tags}{coords}", op, values))
+
+	// parameters without a value stay templated
+	delete(values["path"], "id")
+	assert.Equal(t, "/users/{id}/files/.a%20b;tags=x;tags=y;coords=x,1,y,2",
+		PathString("/users/{id}/files/{name}{tags}{coords}", op, values))
+}
+
+func TestValidatePayloadWithConcretePath(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: users
+  version: 1.0.0
+paths:
+  /users/{id}:
+    put:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+            minimum: 1
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name:
+                  type: string
+      responses:
+        '200':
+          description: ok
+`)
+	op := doc.Paths.Value("/users/{id}").Put
+	path, params := concretePath("/users/{id}", op, nil)
+	assert.Regexp(t, `^/users/[1-9][0-9]*$`, path)
+	assert.Equal(t, map[string]string{"id": strings.TrimPrefix(path, "/users/")}, params)
+
+	// the concrete path is routed to the operation
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+	route, pathParams, err := router.FindRoute(&http.Request{Method: http.MethodPut, URL: &url.URL{Path: path}})
+	require.NoError(t, err)
+	assert.Equal(t, op, route.Operation)
+	assert.Equal(t, params, pathParams)
+
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+	gen := GenFromSchema(schema.Value)
+	rapid.Check(t, func(rt *rapid.T) {
+		assert.NoError(t, ValidatePayload(rt.Context(), gen.Draw(rt, "body"), "/users/{id}", op))
+	})
+	assert.Error(t, ValidatePayload(t.Context(), []byte(`{}`), "/users/{id}", op))
+}
+
+func TestValidatePayloadWithPathItemParameters(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: users
+  version: 1.0.0
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+          minimum: 1
+    put:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name:
+                  type: string
+      responses:
+        '200':
+          description: ok
+`)
+	pathItem := doc.Paths.Value("/users/{id}")
+	op := pathItem.Put
+	path, params := concretePath("/users/{id}", op, []*openapi3.PathItem{pathItem})
+	assert.Regexp(t, `^/users/[1-9][0-9]*$`, path)
+	assert.Equal(t, map[string]string{"id": strings.TrimPrefix(path, "/users/")}, params)
+
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+	gen := GenFromSchema(schema.Value)
+	rapid.Check(t, func(rt *rapid.T) {
+		assert.NoError(t, ValidatePayload(rt.Context(), gen.Draw(rt, "body"), "/users/{id}", op, pathItem))
+	})
+	assert.Error(t, ValidatePayload(t.Context(), []byte(`{}`), "/users/{id}", op, pathItem))
+}
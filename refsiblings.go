@@ -0,0 +1,199 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-openapi/jsonpointer"
+	"gopkg.in/yaml.v3"
+)
+
+// refSiblingKeywords are the keywords next to a $ref that refine the referenced
+// schema, as OpenAPI 3.1 allows. kin-openapi drops them when loading.
+var refSiblingKeywords = []string{
+	"default", "nullable", "description", "title", "example", "deprecated",
+	"readOnly", "writeOnly", "format", "pattern", "minLength", "maxLength",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf",
+	"minItems", "maxItems", "uniqueItems", "minProperties", "maxProperties", "enum",
+}
+
+// applyRefSiblings applies the refSiblingKeywords next to every $ref in data, the
+// source of doc, to a copy of the referenced schema. The copy keeps the $ref, so
+// pointers still follow it. Refs in external files are not refined.
+func applyRefSiblings(data []byte, doc *openapi3.T) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return
+	}
+	applyNodeRefSiblings(root.Content[0], "", doc)
+}
+
+func applyNodeRefSiblings(node *yaml.Node, pointer string, doc *openapi3.T) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			applyNodeRefSiblings(item, pointer+"/"+strconv.Itoa(i), doc)
+		}
+	case yaml.MappingNode:
+		siblings := make(map[string]any)
+		hasRef := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			switch {
+			case key.Value == "$ref":
+				hasRef = true
+			case key.Value == "example" || key.Value == "examples" || key.Value == "enum" ||
+				key.Value == "default" || key.Value == "const":
+				// values, not schemas
+			default:
+				applyNodeRefSiblings(value, pointer+"/"+escapePointer(key.Value), doc)
+			}
+			if contains(refSiblingKeywords, key.Value) {
+				var v any
+				if value.Decode(&v) == nil {
+					siblings[key.Value] = v
+				}
+			}
+		}
+		if hasRef && len(siblings) > 0 {
+			refineRef(pointer, siblings, doc)
+		}
+	}
+}
+
+// refineRef replaces the value of the SchemaRef at pointer with a copy refined by
+// siblings. The referenced schema itself is left as it is.
+func refineRef(pointer string, siblings map[string]any, doc *openapi3.T) {
+	ref := schemaRefAt(doc, pointer)
+	if ref == nil || ref.Value == nil {
+		return
+	}
+	ref.Value = refined(ref.Value, siblings)
+}
+
+// schemaRefAt returns the SchemaRef at pointer. jsonpointer resolves refs on the
+// way, so the SchemaRef is looked up in the object containing it.
+func schemaRefAt(doc *openapi3.T, pointer string) *openapi3.SchemaRef {
+	tokens := strings.Split(pointer, "/")
+	if len(tokens) < 3 {
+		return nil
+	}
+	container := func(n int) any {
+		ptr, err := jsonpointer.New(strings.Join(tokens[:len(tokens)-n], "/"))
+		if err != nil {
+			return nil
+		}
+		found, _, err := ptr.Get(doc)
+		if err != nil {
+			return nil
+		}
+		return found
+	}
+	last := unescapePointer(tokens[len(tokens)-1])
+	keyword := unescapePointer(tokens[len(tokens)-2])
+
+	// members of keywords holding several schemas
+	if schema, ok := container(2).(*openapi3.Schema); ok {
+		switch keyword {
+		case "properties":
+			return schema.Properties[last]
+		case "allOf", "anyOf", "oneOf":
+			refs := map[string]openapi3.SchemaRefs{"allOf": schema.AllOf, "anyOf": schema.AnyOf, "oneOf": schema.OneOf}[keyword]
+			if i, err := strconv.Atoi(last); err == nil && i >= 0 && i < len(refs) {
+				return refs[i]
+			}
+			return nil
+		}
+	}
+	if keyword == "schemas" && len(tokens) == 4 && tokens[1] == "components" {
+		return doc.Components.Schemas[last]
+	}
+
+	switch parent := container(1).(type) {
+	case *openapi3.Schema:
+		switch last {
+		case "items":
+			return parent.Items
+		case "not":
+			return parent.Not
+		case "additionalProperties":
+			return parent.AdditionalProperties.Schema
+		}
+	case *openapi3.MediaType:
+		if last == "schema" {
+			return parent.Schema
+		}
+	case *openapi3.Parameter:
+		if last == "schema" {
+			return parent.Schema
+		}
+	}
+	return nil
+}
+
+// unescapePointer unescapes a single JSON pointer reference token (RFC 6901)
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// refined returns a copy of schema with the sibling keywords applied
+func refined(schema *openapi3.Schema, siblings map[string]any) *openapi3.Schema {
+	var sib openapi3.Schema
+	if err := json.Unmarshal(marshal(siblings), &sib); err != nil {
+		return schema
+	}
+	copied := *schema
+	for key := range siblings {
+		switch key {
+		case "default":
+			copied.Default = sib.Default
+		case "nullable":
+			copied.Nullable = sib.Nullable
+		case "description":
+			copied.Description = sib.Description
+		case "title":
+			copied.Title = sib.Title
+		case "example":
+			copied.Example = sib.Example
+		case "deprecated":
+			copied.Deprecated = sib.Deprecated
+		case "readOnly":
+			copied.ReadOnly = sib.ReadOnly
+		case "writeOnly":
+			copied.WriteOnly = sib.WriteOnly
+		case "format":
+			copied.Format = sib.Format
+		case "pattern":
+			copied.Pattern = sib.Pattern
+		case "minLength":
+			copied.MinLength = sib.MinLength
+		case "maxLength":
+			copied.MaxLength = sib.MaxLength
+		case "minimum":
+			copied.Min = sib.Min
+		case "maximum":
+			copied.Max = sib.Max
+		case "exclusiveMinimum":
+			copied.ExclusiveMin = sib.ExclusiveMin
+		case "exclusiveMaximum":
+			copied.ExclusiveMax = sib.ExclusiveMax
+		case "multipleOf":
+			copied.MultipleOf = sib.MultipleOf
+		case "minItems":
+			copied.MinItems = sib.MinItems
+		case "maxItems":
+			copied.MaxItems = sib.MaxItems
+		case "uniqueItems":
+			copied.UniqueItems = sib.UniqueItems
+		case "minProperties":
+			copied.MinProps = sib.MinProps
+		case "maxProperties":
+			copied.MaxProps = sib.MaxProps
+		case "enum":
+			copied.Enum = sib.Enum
+		}
+	}
+	return &copied
+}
@@ -0,0 +1,86 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestRefSiblings(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: ref siblings
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Count:
+      type: integer
+      minimum: 0
+      maximum: 1000
+    Status:
+      type: string
+      enum: [active, disabled]
+    Order:
+      type: object
+      required: [status, count, tags]
+      additionalProperties: false
+      properties:
+        status:
+          $ref: '#/components/schemas/Status'
+          default: active
+        count:
+          $ref: '#/components/schemas/Count'
+          maximum: 10
+          nullable: true
+        tags:
+          type: array
+          items:
+            $ref: '#/components/schemas/Count'
+            minimum: 500
+    Small:
+      $ref: '#/components/schemas/Count'
+      maximum: 5
+`)
+	schemas := doc.Components.Schemas
+	order := schemas["Order"].Value
+
+	status := order.Properties["status"]
+	assert.Equal(t, "#/components/schemas/Status", status.Ref)
+	assert.Equal(t, "active", status.Value.Default)
+	assert.True(t, order.Properties["count"].Value.Nullable)
+	// the referenced schemas are not changed
+	assert.Nil(t, schemas["Status"].Value.Default)
+	assert.False(t, schemas["Count"].Value.Nullable)
+	assert.Equal(t, 1000.0, *schemas["Count"].Value.Max)
+
+	gen := GenFromSchema(order)
+	small := GenFromSchema(schemas["Small"].Value)
+	sawNull := false
+	rapid.Check(t, func(rt *rapid.T) {
+		var o struct {
+			Status string `json:"status"`
+			Count  *int   `json:"count"`
+			Tags   []int  `json:"tags"`
+		}
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "order"), &o))
+		if o.Count == nil {
+			sawNull = true
+		} else {
+			assert.LessOrEqual(t, *o.Count, 10)
+		}
+		for _, tag := range o.Tags {
+			assert.GreaterOrEqual(t, tag, 500)
+			assert.LessOrEqual(t, tag, 1000)
+		}
+
+		var n int
+		require.NoError(t, json.Unmarshal(small.Draw(rt, "small"), &n))
+		assert.LessOrEqual(t, n, 5)
+	})
+	assert.True(t, sawNull, "the nullable sibling never produced null")
+}
@@ -0,0 +1,68 @@
+package SpecSmash
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EmitRegressionTest returns Go source for a standalone test reproducing a failing
+// payload, to paste into a _test.go file of a regression suite:
+//
+//	func TestRegression_POST_events_1f2e3d4c(t *testing.T) {
+//		doc, err := SpecSmash.ReadSpec(specPath)
+//		...
+//	}
+//
+// The test reads the spec from specPath, which the file must declare, fails if the
+// operation is no longer in it, and validates the payload with ValidatePayloadInDoc. It uses testify's assert and require. The name
+// ends in a hash of the payload, so tests of several failures of one operation do not
+// clash. JSON payloads are indented for review.
+func EmitRegressionTest(path, method string, payload []byte) string {
+	method = strings.ToUpper(method)
+	h := fnv.New32a()
+	h.Write(payload)
+	name := fmt.Sprintf("TestRegression_%s_%s_%08x", identifierPart(method), identifierPart(path), h.Sum32())
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, payload, "\t", "\t") == nil {
+		payload = indented.Bytes()
+	}
+
+	var src strings.Builder
+	fmt.Fprintf(&src, "func %s(t *testing.T) {\n", name)
+	src.WriteString("\tdoc, err := SpecSmash.ReadSpec(specPath)\n")
+	src.WriteString("\trequire.NoError(t, err)\n")
+	fmt.Fprintf(&src, "\titem := doc.Paths.Value(%s)\n", strconv.Quote(path))
+	src.WriteString("\trequire.NotNil(t, item)\n")
+	fmt.Fprintf(&src, "\top := item.GetOperation(%s)\n", strconv.Quote(method))
+	src.WriteString("\trequire.NotNil(t, op)\n\n")
+	fmt.Fprintf(&src, "\tpayload := []byte(%s)\n", goStringLiteral(payload))
+	fmt.Fprintf(&src, "\tassert.NoError(t, SpecSmash.ValidatePayloadInDoc(t.Context(), payload, %s, op, doc, nil))\n", strconv.Quote(path))
+	src.WriteString("}\n")
+	return src.String()
+}
+
+// goStringLiteral returns s as a raw string literal, or quoted if a raw string
+// cannot hold it: Go source must be valid UTF-8 without NUL or byte order marks,
+// and raw strings drop carriage returns and end at a backtick
+func goStringLiteral(s []byte) string {
+	if !utf8.Valid(s) || bytes.ContainsAny(s, "`\r\x00\ufeff") {
+		return strconv.Quote(string(s))
+	}
+	return "`" + string(s) + "`"
+}
+
+// identifierPart turns s into letters, digits and underscores for use in a Go name,
+// e.g. /users/{id} becomes users_id
+func identifierPart(s string) string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, "_")
+}
@@ -0,0 +1,66 @@
+package SpecSmash
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseRegressionTest parses a test emitted by EmitRegressionTest and returns its
+// name and payload
+func parseRegressionTest(t *testing.T, src string) (string, string) {
+	file, err := parser.ParseFile(token.NewFileSet(), "regression_test.go", "package regression\n\n"+src, 0)
+	require.NoError(t, err, src)
+	require.Len(t, file.Decls, 1)
+	fn := file.Decls[0].(*ast.FuncDecl)
+
+	var payload string
+	ast.Inspect(fn, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || assign.Lhs[0].(*ast.Ident).Name != "payload" {
+			return true
+		}
+		literal := assign.Rhs[0].(*ast.CallExpr).Args[0].(*ast.BasicLit)
+		payload, err = strconv.Unquote(literal.Value)
+		require.NoError(t, err)
+		return false
+	})
+	return fn.Name.Name, payload
+}
+
+func TestEmitRegressionTest(t *testing.T) {
+	payload := []byte(`{"amount":{"amount":0.02,"currency":"USD"},"type":"purchase"}`)
+	src := EmitRegressionTest("/events", "post", payload)
+
+	assert.Equal(t, "func TestRegression_POST_events_8bfecb6f(t *testing.T) {\n"+
+		"\tdoc, err := SpecSmash.ReadSpec(specPath)\n"+
+		"\trequire.NoError(t, err)\n"+
+		"\titem := doc.Paths.Value(\"/events\")\n"+
+		"\trequire.NotNil(t, item)\n"+
+		"\top := item.GetOperation(\"POST\")\n"+
+		"\trequire.NotNil(t, op)\n\n"+
+		"\tpayload := []byte(`{\n\t\t\"amount\": {\n\t\t\t\"amount\": 0.02,\n"+
+		"\t\t\t\"currency\": \"USD\"\n\t\t},\n\t\t\"type\": \"purchase\"\n\t}`)\n"+
+		"\tassert.NoError(t, SpecSmash.ValidatePayloadInDoc(t.Context(), payload, \"/events\", op, doc, nil))\n"+
+		"}\n", src)
+
+	name, emitted := parseRegressionTest(t, src)
+	assert.Equal(t, "TestRegression_POST_events_8bfecb6f", name)
+	assert.JSONEq(t, string(payload), emitted)
+
+	// payloads a raw string cannot hold are quoted
+	for _, payload := range [][]byte{[]byte("{\"note\":\"`code`\"}"), []byte("{\"note\":\"\ufeff\"}")} {
+		name, emitted = parseRegressionTest(t, EmitRegressionTest("/users/{id}/notes", "PUT", payload))
+		assert.Regexp(t, `^TestRegression_PUT_users_id_notes_[0-9a-f]{8}$`, name)
+		assert.JSONEq(t, string(payload), emitted)
+	}
+
+	// invalid JSON is kept as it is
+	_, emitted = parseRegressionTest(t, EmitRegressionTest("/notes", "patch", []byte(`{"note"`)))
+	assert.Equal(t, `{"note"`, emitted)
+}
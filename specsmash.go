@@ -7,16 +7,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"maps"
 	"math"
+	"math/big"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/getkin/kin-openapi/openapi3filter"
 
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/google/uuid"
+	"github.com/woodsbury/decimal128"
 	"pgregory.net/rapid"
 )
 
@@ -25,12 +36,288 @@ import (
 // Users should provide this function to handle custom patterns.
 type PatternFunc func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string
 
+// GenerationMode selects which readOnly/writeOnly properties are generated
+type GenerationMode int
+
+const (
+	// ModeAny ignores readOnly and writeOnly
+	ModeAny GenerationMode = iota
+	// ModeRequest omits readOnly properties, as a client would when sending a request body
+	ModeRequest
+	// ModeResponse omits writeOnly properties, as a server would when sending a response body
+	ModeResponse
+	// ModeMergePatch generates application/merge-patch+json bodies (RFC 7386): partial
+	// objects without readOnly properties, where every property is optional and may be
+	// null to delete it. Arrays are replaced as a whole, so their items are complete.
+	ModeMergePatch
+)
+
+// UnknownFormatPolicy selects what happens when a string schema has a format
+// that SpecSmash does not generate values for
+type UnknownFormatPolicy int
+
+const (
+	// UnknownFormatIgnore generates plain strings for unknown formats
+	UnknownFormatIgnore UnknownFormatPolicy = iota
+	// UnknownFormatWarn generates plain strings, and logs each unknown format once
+	UnknownFormatWarn
+	// UnknownFormatError fails generation for schemas with unknown formats
+	UnknownFormatError
+)
+
+// NumberFormat selects how generated numbers are written
+type NumberFormat int
+
+const (
+	// NumberFormatDefault writes numbers like encoding/json: exponents for very
+	// large and very small magnitudes, e.g. 1e+21
+	NumberFormatDefault NumberFormat = iota
+	// NumberFormatDecimal writes numbers as plain decimals, never with an exponent
+	NumberFormatDecimal
+)
+
+// LengthSemantics selects the unit minLength and maxLength are measured in
+type LengthSemantics int
+
+const (
+	// LengthCodePoints counts Unicode code points, as JSON Schema does
+	LengthCodePoints LengthSemantics = iota
+	// LengthUTF16 counts UTF-16 code units, as JavaScript's String.length does:
+	// characters outside the Basic Multilingual Plane count twice
+	LengthUTF16
+	// LengthBytes counts the bytes of the UTF-8 encoding
+	LengthBytes
+)
+
+// ByteLengthSemantics selects what minLength and maxLength of format byte strings bound
+type ByteLengthSemantics int
+
+const (
+	// ByteLengthEncoded bounds the length of the base64 text, as JSON Schema does
+	ByteLengthEncoded ByteLengthSemantics = iota
+	// ByteLengthDecoded bounds the number of bytes the base64 text decodes to
+	ByteLengthDecoded
+)
+
+// ReadOnlyRequiredPolicy selects how ModeRequest handles readOnly properties that
+// are also required
+type ReadOnlyRequiredPolicy int
+
+const (
+	// ReadOnlyRequiredOmit leaves them out like every readOnly property
+	ReadOnlyRequiredOmit ReadOnlyRequiredPolicy = iota
+	// ReadOnlyRequiredSentinel generates a placeholder for them, for validators that
+	// insist on required properties even when they are readOnly
+	ReadOnlyRequiredSentinel
+)
+
+// runeLength returns the length of r in these semantics
+func (l LengthSemantics) runeLength(r rune) int {
+	switch l {
+	case LengthUTF16:
+		return utf16.RuneLen(r)
+	case LengthBytes:
+		return utf8.RuneLen(r)
+	}
+	return 1
+}
+
+// stringLength returns the length of s in these semantics
+func (l LengthSemantics) stringLength(s string) int {
+	n := 0
+	for _, r := range s {
+		n += l.runeLength(r)
+	}
+	return n
+}
+
+// excludes reports whether a property schema must not be generated in this mode
+func (m GenerationMode) excludes(schema *openapi3.Schema) bool {
+	if schema == nil {
+		return false
+	}
+	switch m {
+	case ModeRequest, ModeMergePatch:
+		return schema.ReadOnly
+	case ModeResponse:
+		return schema.WriteOnly
+	}
+	return false
+}
+
 // GenerationOptions holds configuration for schema generation
 type GenerationOptions struct {
-	depth                   int
+	depth int
+	// pointer is the JSON pointer of the schema being generated, if known
+	pointer                 string
 	MaxDepth                int
 	AdditionalPropertiesMax int
 	PatternFunc             PatternFunc
+	// MapMinEntries is the least number of entries of map objects, which declare no
+	// properties or patternProperties but allow additional properties
+	MapMinEntries int
+	// FixedArrayLength, if set, is the length of every generated array
+	FixedArrayLength *int
+	// OnlyRequiredDepth, if set, is the depth from which objects only get their
+	// required properties
+	OnlyRequiredDepth *int
+	// ScalarAdditionalValues limits values of free-form additional properties to scalars
+	ScalarAdditionalValues bool
+	Mode                   GenerationMode
+	// Overrides replaces generation of the schemas at these document JSON pointers
+	Overrides map[string]*rapid.Generator[json.RawMessage]
+	// UUIDVersion is the version of generated uuids, 4 (random) or 7 (time-ordered)
+	UUIDVersion   int
+	UUIDUppercase bool
+	// StringAlphabet, if set, holds the only characters of strings without pattern or format
+	StringAlphabet string
+	// LengthSemantics is the unit of minLength and maxLength for strings without
+	// pattern or format
+	LengthSemantics LengthSemantics
+	// ByteLengthSemantics is what minLength and maxLength of format byte strings bound
+	ByteLengthSemantics ByteLengthSemantics
+	// ReadOnlyRequired selects whether ModeRequest generates required readOnly properties
+	ReadOnlyRequired ReadOnlyRequiredPolicy
+	// Trace, if set, receives a log of the decisions taken in every draw
+	Trace io.Writer
+	// traced is set below the root generator, which starts the log of each draw
+	traced bool
+	// BigIntegers adds integers beyond the int64 range to unbounded integer schemas
+	BigIntegers bool
+	// Integer53BitSafe keeps integers without explicit bounds beyond 2^53-1 within the
+	// range JavaScript numbers represent exactly
+	Integer53BitSafe bool
+	// PropertyOrder emits object properties in the order they are declared in the spec
+	PropertyOrder bool
+	// PatternRepeatCap, if set, bounds the unbounded quantifiers (*, +, {n,}) of patterns
+	// to that many repetitions before they are passed to the PatternFunc
+	PatternRepeatCap int
+	// FormatExampleBias mixes the example of formatted strings into their values
+	FormatExampleBias bool
+	// PrecisionStress mixes values at floating point precision boundaries into numbers
+	PrecisionStress bool
+	// NumberFormat selects how generated numbers are written
+	NumberFormat NumberFormat
+	// UnknownFormatPolicy selects how string formats without a generator are handled
+	UnknownFormatPolicy UnknownFormatPolicy
+	// warnedFormats is shared by all child options, see UnknownFormatWarn
+	warnedFormats *warnedFormats
+	// Seed, if set, seeds Check and the fixed draws of GenCoverageSuite
+	Seed uint64
+	// RetryLimit caps every loop that redraws values until they satisfy the schema.
+	// 0 keeps the default of each loop.
+	RetryLimit int
+	// enumCoverage is shared by all child options, see WithEnumCoverage
+	enumCoverage *enumCoverage
+	// meta records the decisions of a draw, see GenFromSchemaWithMeta
+	meta *metaRecorder
+	// allOfMerges is shared by all child options, see handleAllOf
+	allOfMerges *allOfMerges
+	// GenerationTimeout, if set, aborts draws of the root generator that take longer
+	GenerationTimeout time.Duration
+	// deadline is the end of the draw the generators of these options were built for,
+	// shared by all child options
+	deadline *drawDeadline
+	// minimalSchemas are the schemas generated past the depth cutoff on this branch
+	minimalSchemas *schemaChain
+}
+
+// child returns a copy of the options for generating one level deeper
+func (opts *GenerationOptions) child() *GenerationOptions {
+	childOpts := *opts
+	childOpts.depth++
+	return &childOpts
+}
+
+// childAt returns child options for the subschema ref, found under the given
+// tokens of the current schema. A local $ref moves the pointer to its target.
+func (opts *GenerationOptions) childAt(ref *openapi3.SchemaRef, tokens ...string) *GenerationOptions {
+	childOpts := opts.child()
+	if ref != nil && strings.HasPrefix(ref.Ref, "#/") {
+		childOpts.pointer = ref.Ref[1:]
+		return childOpts
+	}
+	for _, token := range tokens {
+		childOpts.pointer += "/" + escapePointer(token)
+	}
+	return childOpts
+}
+
+// retryLimit returns how often a draw is retried: RetryLimit if set, else def
+func (opts *GenerationOptions) retryLimit(def int) int {
+	if opts.RetryLimit > 0 {
+		return opts.RetryLimit
+	}
+	return def
+}
+
+// retryError is the panic message of a retry loop that gave up
+func retryError(what string, attempts int) string {
+	return fmt.Sprintf("could not satisfy %s after %d attempts", what, attempts)
+}
+
+// trace writes one decision to the Trace log, indented by depth
+func (opts *GenerationOptions) trace(label string, value any) {
+	if opts.Trace == nil {
+		return
+	}
+	fmt.Fprintf(opts.Trace, "%s%s: %v\n", strings.Repeat("  ", opts.depth+1), label, value)
+}
+
+// checkTimeout aborts the draw once it ran longer than GenerationTimeout
+func (opts *GenerationOptions) checkTimeout() {
+	if opts.deadline.passed() {
+		panic(fmt.Sprintf("generation timed out after %s at '%s'", opts.GenerationTimeout, opts.pointer))
+	}
+}
+
+// drawDeadline is the end of one draw of a generator with a GenerationTimeout.
+// Every draw builds the nested generators anew with a deadline of its own.
+type drawDeadline struct {
+	at time.Time
+}
+
+// passed reports whether the draw ran past its end
+func (d *drawDeadline) passed() bool {
+	return d != nil && time.Now().After(d.at)
+}
+
+// atMaxDepth reports whether generation reached MaxDepth. From there on, objects and
+// arrays are kept minimal: only required properties and minItems elements.
+func (opts *GenerationOptions) atMaxDepth() bool {
+	return opts.depth >= opts.MaxDepth
+}
+
+// onlyRequired reports whether objects at this depth only get their required
+// properties, and just enough others for minProperties: past MaxDepth, or from
+// OnlyRequiredDepth on
+func (opts *GenerationOptions) onlyRequired() bool {
+	return opts.atMaxDepth() || opts.OnlyRequiredDepth != nil && opts.depth >= *opts.OnlyRequiredDepth
+}
+
+// schemaChain lists the schemas generated minimally on the current branch, see
+// enterMinimal
+type schemaChain struct {
+	schema *openapi3.Schema
+	parent *schemaChain
+}
+
+// enterMinimal returns the options for generating the children of the object or
+// array schema. Past the depth cutoff (see onlyRequired) children are only generated
+// when required, so a schema that is minimal again below itself would nest without
+// end: recursive reports that case, whose value could never be finished.
+func (opts *GenerationOptions) enterMinimal(schema *openapi3.Schema) (_ *GenerationOptions, recursive bool) {
+	if !opts.onlyRequired() {
+		return opts, false
+	}
+	for c := opts.minimalSchemas; c != nil; c = c.parent {
+		if c.schema == schema {
+			return opts, true
+		}
+	}
+	entered := *opts
+	entered.minimalSchemas = &schemaChain{schema: schema, parent: opts.minimalSchemas}
+	return &entered, false
 }
 
 // ---------------- Core Utilities ----------------
@@ -39,334 +326,1758 @@ func getType(t string) *openapi3.Types {
 	return &typesSlice
 }
 
+// schemaKeywords are JSON Schema keywords outside the OpenAPI 3.0 subset that are
+// understood by the generators. kin-openapi keeps these in Schema.Extensions.
+var schemaKeywords = []string{"const", "patternProperties", "contains", "minContains", "maxContains", "prefixItems", "additionalItems", "unevaluatedProperties", "dependentSchemas", "dependentRequired",
+	"contentMediaType", "contentSchema", "contentEncoding", "propertyNames"}
+
+// keyword looks up a JSON Schema keyword that kin-openapi stored as an extension
+func keyword(schema *openapi3.Schema, name string) (any, bool) {
+	v, ok := schema.Extensions[name]
+	return v, ok
+}
+
+// keywordInt looks up a JSON Schema keyword with an integer value
+func keywordInt(schema *openapi3.Schema, name string) (int, bool) {
+	v, ok := keyword(schema, name)
+	if !ok {
+		return 0, false
+	}
+	f, ok := v.(float64)
+	return int(f), ok
+}
+
+// subSchema converts a subschema found under one of the schemaKeywords, which
+// kin-openapi leaves as decoded JSON. Boolean schemas are supported: true is the
+// empty schema and false becomes {"not": {}}.
+// References inside such subschemas are not resolved.
+func subSchema(raw any) *openapi3.Schema {
+	switch v := raw.(type) {
+	case bool:
+		if v {
+			return &openapi3.Schema{}
+		}
+		return &openapi3.Schema{Not: &openapi3.SchemaRef{Value: &openapi3.Schema{}}}
+	case *openapi3.Schema:
+		return v
+	}
+	var schema openapi3.Schema
+	if err := json.Unmarshal(marshal(raw), &schema); err != nil {
+		panic(fmt.Sprintf("invalid subschema %v: %v", raw, err))
+	}
+	return &schema
+}
+
+// isFalseSchema reports whether no value is valid against schema,
+// i.e. it is the boolean schema false, spelled {"not": {}} in OpenAPI 3.0
+func isFalseSchema(schema *openapi3.Schema) bool {
+	return schema != nil && schema.Not != nil && schema.Not.Value != nil &&
+		schema.Not.Value.IsEmpty() && len(schema.Not.Value.Extensions) == 0
+}
+
 // genNull returns a RawMessage "null"
 func genNull() *rapid.Generator[json.RawMessage] {
 	return rapid.Just(json.RawMessage("null"))
 }
 
+// isNullable reports whether null is allowed by nullable, or by the Swagger 2.0
+// x-nullable extension that converted specs carry instead
+func isNullable(schema *openapi3.Schema) bool {
+	if schema.Nullable {
+		return true
+	}
+	xNullable, _ := schema.Extensions["x-nullable"].(bool)
+	return xNullable
+}
+
 // wrapNullable wraps a generator with nullable=true semantics.
 func wrapNullable(schema *openapi3.Schema, g *rapid.Generator[json.RawMessage]) *rapid.Generator[json.RawMessage] {
+	if !isNullable(schema) {
+		return g
+	}
+	return rapid.OneOf(g, genNull())
+}
+
+// genEnum samples the enum members of a schema, or returns nil if it has no enum.
+// Members may be of mixed JSON types, with or without a type keyword: they are
+// sampled as written, before any type is considered.
+// The members are marshaled once, when the generator is built, not on every draw.
+// null is only generated as an enum member, with the frequency of any other member:
+// enums are not wrapped by wrapNullable, whether the schema is nullable or not.
+func (opts *GenerationOptions) genEnum(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	if len(schema.Enum) == 0 {
+		return nil
+	}
+	choices := make([]json.RawMessage, len(schema.Enum))
+	for i, e := range schema.Enum {
+		choices[i] = marshal(e)
+	}
+	if c, ok := keyword(schema, "const"); ok {
+		// only the const member of the enum is valid
+		constValue := marshal(c)
+		if !slices.ContainsFunc(choices, func(choice json.RawMessage) bool { return bytes.Equal(choice, constValue) }) {
+			return genFail(fmt.Sprintf("const %s is not one of the enum members", constValue))
+		}
+		choices = []json.RawMessage{constValue}
+	}
+	if opts.enumCoverage != nil {
+		return opts.enumCoverage.gen(schema, choices)
+	}
+	return rapid.SampledFrom(choices)
+}
+
+// enumCoverage remembers which enum members were generated, across draws
+type enumCoverage struct {
+	mu   sync.Mutex
+	seen map[*openapi3.Schema]map[string]bool
+}
+
+// gen samples members of schema's enum that were not generated yet,
+// and all members once every one of them has been seen
+func (c *enumCoverage) gen(schema *openapi3.Schema, choices []json.RawMessage) *rapid.Generator[json.RawMessage] {
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		c.mu.Lock()
+		seen := c.seen[schema]
+		if seen == nil {
+			seen = make(map[string]bool)
+			c.seen[schema] = seen
+		}
+		var unseen []json.RawMessage
+		for _, choice := range choices {
+			if !seen[string(choice)] {
+				unseen = append(unseen, choice)
+			}
+		}
+		c.mu.Unlock()
+
+		pool := choices
+		if len(unseen) > 0 {
+			pool = unseen
+		}
+		choice := rapid.SampledFrom(pool).Draw(t, "Enum-Coverage")
+
+		c.mu.Lock()
+		seen[string(choice)] = true
+		c.mu.Unlock()
+		return choice
+	})
+}
+
+// reset forgets all generated members
+func (c *enumCoverage) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seen = make(map[*openapi3.Schema]map[string]bool)
+}
+
+// genFail returns a generator that fails every draw with msg.
+// Used for schemas that cannot be generated, so the failure is reported through rapid.
+func genFail(msg string) *rapid.Generator[json.RawMessage] {
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		panic(msg)
+	})
+}
+
+type marshalBuffer struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var marshalPool = sync.Pool{
+	New: func() any {
+		m := &marshalBuffer{}
+		m.enc = json.NewEncoder(&m.buf)
+		// values are written as the spec has them, e.g. enum members like "R&D",
+		// not with <, > and & escaped for embedding in HTML
+		m.enc.SetEscapeHTML(false)
+		return m
+	},
+}
+
+// marshal wraps arbitrary Go into RawMessage
+func marshal(v any) json.RawMessage {
+	m := marshalPool.Get().(*marshalBuffer)
+	defer marshalPool.Put(m)
+
+	m.buf.Reset()
+	if err := m.enc.Encode(v); err != nil {
+		return nil
+	}
+	// Encode terminates every value with a newline
+	out := m.buf.Bytes()
+	return append(json.RawMessage(nil), out[:len(out)-1]...)
+}
+
+// marshalOrdered marshals obj with the keys in order first, then the remaining keys sorted
+func marshalOrdered(obj map[string]json.RawMessage, order []string) json.RawMessage {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	written := make(map[string]bool, len(obj))
+	write := func(key string) {
+		value, ok := obj[key]
+		if !ok || written[key] {
+			return
+		}
+		if len(written) > 0 {
+			buf.WriteByte(',')
+		}
+		written[key] = true
+		buf.Write(marshal(key))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	for _, key := range order {
+		write(key)
+	}
+	for _, key := range sortedKeys(obj) {
+		write(key)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes()
+}
+
+// ---------------- Primitive Generators ----------------
+
+func (opts *GenerationOptions) genString(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	validateFormat, registered := formatValidator(schema.Format)
+	if schema.Format != "" && !knownStringFormat(schema.Format) && !registered {
+		switch opts.UnknownFormatPolicy {
+		case UnknownFormatWarn:
+			opts.warnedFormats.warn(schema.Format, opts.pointer)
+		case UnknownFormatError:
+			return genFail(fmt.Sprintf("format '%s' at '%s' is unknown, values would be plain strings", schema.Format, opts.pointer))
+		}
+	}
+
+	if schema.MaxLength != nil && *schema.MaxLength < schema.MinLength {
+		return genFail(fmt.Sprintf("maxLength %d is less than minLength %d at '%s'", *schema.MaxLength, schema.MinLength, opts.pointer))
+	}
+
+	if minBytes, maxBytes := opts.byteCountRange(schema); schema.Format == "byte" && maxBytes >= 0 && maxBytes < minBytes {
+		return genFail(fmt.Sprintf("no base64 text has a length between minLength %d and maxLength %d at '%s'", schema.MinLength, *schema.MaxLength, opts.pointer))
+	}
+
+	decimal, isDecimal := decimalFormats[schema.Format]
+	if isDecimal {
+		maxLength := -1
+		if schema.MaxLength != nil {
+			maxLength = int(*schema.MaxLength)
+		}
+		if len(decimal.lengths(int(schema.MinLength), maxLength)) == 0 {
+			return genFail(fmt.Sprintf("no %s value has a length in [%d, %d] at '%s'", schema.Format, schema.MinLength, maxLength, opts.pointer))
+		}
+	}
+
+	// A formatted value is only used with a pattern if it satisfies the pattern
+	var patternRe *regexp.Regexp
+	if schema.Format != "" && schema.Pattern != "" {
+		patternRe, _ = regexp.Compile(schema.Pattern)
+	}
+
+	embedded := opts.embeddedJSON(schema)
+
+	var alphabet *rapid.Generator[rune]
+	if opts.StringAlphabet != "" {
+		alphabet = rapid.SampledFrom([]rune(opts.StringAlphabet))
+	}
+
+	// Custom string generator with early returns using draw
+	stringGen := rapid.Custom(func(t *rapid.T) string {
+		// Default string with length bounds
+		minLength := int(schema.MinLength)
+		maxLength := -1
+		if schema.MaxLength != nil {
+			maxLength = int(*schema.MaxLength)
+		}
+
+		if embedded != nil {
+			value := embedded.Draw(t, "embedded-json")
+			opts.trace("embedded-json", value)
+			return value
+		}
+
+		// Format takes precedence over pattern
+		if value, ok := opts.drawFormat(schema, t); ok {
+			if schema.Pattern == "" {
+				opts.trace(schema.Format, value)
+				return value
+			}
+			if patternRe != nil {
+				if patternRe.MatchString(value) {
+					opts.trace(schema.Format, value)
+					return value
+				}
+				// uuids are case-insensitive, the pattern may want uppercase
+				if upper := strings.ToUpper(value); schema.Format == "uuid" && patternRe.MatchString(upper) {
+					opts.trace(schema.Format, upper)
+					return upper
+				}
+			}
+		}
+
+		// registered formats without a generator of their own come from the PatternFunc
+		if registered && !knownStringFormat(schema.Format) && schema.Pattern == "" && opts.PatternFunc != nil {
+			value := opts.drawPattern("", schema.Format, minLength, maxLength, t)
+			opts.trace(schema.Format, value)
+			return value
+		}
+
+		// Handle pattern
+		if schema.Pattern != "" {
+			value := opts.drawPattern(schema.Pattern, schema.Format, minLength, maxLength, t)
+			opts.trace("pattern", value)
+			return value
+		}
+
+		var value string
+		if opts.LengthSemantics != LengthCodePoints {
+			value = opts.drawMeasuredString(alphabet, minLength, maxLength, t)
+		} else if alphabet != nil {
+			value = rapid.StringOfN(alphabet, minLength, maxLength, -1).Draw(t, "string")
+		} else {
+			value = rapid.StringN(minLength, maxLength, -1).Draw(t, "string")
+		}
+		opts.trace("string", value)
+		return value
+	})
+
+	// values of the PatternFunc, drawn when no integer matched the pattern, must still be in range
+	if isDecimal && schema.Pattern != "" {
+		stringGen = opts.retryValidFormat(stringGen, fmt.Sprintf("format '%s' with pattern '%s'", schema.Format, schema.Pattern), decimal.check)
+	}
+	if registered {
+		stringGen = opts.retryValidFormat(stringGen, fmt.Sprintf("the registered validator of format '%s'", schema.Format), validateFormat)
+	}
+
+	gen := rapid.Map(stringGen, func(s string) json.RawMessage { return marshal(s) })
+	if example, ok := schema.Example.(string); ok && opts.FormatExampleBias && schema.Format != "" && validAgainst(schema, marshal(example)) {
+		// one draw in four is the example
+		gen = rapid.OneOf(gen, gen, gen, rapid.Just(marshal(example)))
+	}
+	return wrapNullable(schema, gen)
+}
+
+// retryValidFormat redraws strings of gen until validate, e.g. the registered validator
+// of a format, accepts one. what describes validate in the error of the last attempt.
+func (opts *GenerationOptions) retryValidFormat(gen *rapid.Generator[string], what string, validate func(string) error) *rapid.Generator[string] {
+	attempts := opts.retryLimit(formatValueAttempts)
+	return rapid.Custom(func(t *rapid.T) string {
+		for attempt := 0; attempt < attempts; attempt++ {
+			if s := gen.Draw(t, "format-value"); validate(s) == nil {
+				return s
+			}
+			opts.checkTimeout()
+		}
+		panic(retryError(what, attempts))
+	})
+}
+
+// formatValueAttempts is how often a string of a format registered with
+// RegisterFormatValidator is drawn, unless RetryLimit is set
+const formatValueAttempts = 50
+
+// unboundedLengthSpread is how far past minLength strings without maxLength are
+// drawn, when lengths are not measured in code points
+const unboundedLengthSpread = 32
+
+// drawMeasuredString draws a string whose length in opts.LengthSemantics is within
+// [minLength, maxLength]. Characters that would exceed the length drawn are replaced
+// by the shortest character of the alphabet, or 'a'. It fails if no string of the
+// alphabet fits the bounds, e.g. characters of length 2 for minLength = maxLength = 3.
+func (opts *GenerationOptions) drawMeasuredString(alphabet *rapid.Generator[rune], minLength, maxLength int, t *rapid.T) string {
+	if maxLength < 0 {
+		maxLength = minLength + unboundedLengthSpread
+	}
+	runes := alphabet
+	shortest := 'a'
+	if alphabet == nil {
+		runes = rapid.Rune()
+	} else {
+		shortest = []rune(opts.StringAlphabet)[0]
+		for _, r := range opts.StringAlphabet {
+			if opts.LengthSemantics.runeLength(r) < opts.LengthSemantics.runeLength(shortest) {
+				shortest = r
+			}
+		}
+	}
+
+	target := rapid.IntRange(minLength, maxLength).Draw(t, "string-length")
+	var b strings.Builder
+	for length, i := 0, 0; length < target; i++ {
+		r := runes.Draw(t, fmt.Sprintf("string-rune-%d", i))
+		if length+opts.LengthSemantics.runeLength(r) > target {
+			r = shortest
+			if n := length + opts.LengthSemantics.runeLength(r); n > target {
+				// the alphabet has no character short enough to fill the rest, so the
+				// string stops short of target, or passes it, within the bounds
+				if length >= minLength {
+					break
+				}
+				if n > maxLength {
+					panic(fmt.Sprintf("no string of the alphabet %q has a length in [%d, %d]", opts.StringAlphabet, minLength, maxLength))
+				}
+			}
+		}
+		b.WriteRune(r)
+		length += opts.LengthSemantics.runeLength(r)
+	}
+	return b.String()
+}
+
+// knownStringFormat reports whether values of string schemas with format are
+// generated, by drawFormat or as embedded JSON, or are not constrained by it, like
+// password
+func knownStringFormat(format string) bool {
+	_, drawn := formatDrawers[format]
+	return drawn || format == "json" || format == "password"
+}
+
+// warnedFormats remembers which unknown formats were logged, across draws
+type warnedFormats struct {
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+// warn logs format the first time it is seen. Options built without
+// WithUnknownFormatPolicy log every time.
+func (w *warnedFormats) warn(format, pointer string) {
+	if w != nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.warned[format] {
+			return
+		}
+		w.warned[format] = true
+	}
+	log.Printf("specsmash: format '%s' at '%s' is unknown, generating plain strings", format, pointer)
+}
+
+// reset forgets all logged formats
+func (w *warnedFormats) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warned = make(map[string]bool)
+}
+
+// drawFormat draws a string of the schema's format, if it is a format we can generate
+func (opts *GenerationOptions) drawFormat(schema *openapi3.Schema, t *rapid.T) (string, bool) {
+	draw, ok := formatDrawers[schema.Format]
+	if !ok {
+		return "", false
+	}
+	return draw(opts, schema, t), true
+}
+
+// formatDrawers draw the string formats we generate, see drawFormat. They are also
+// the formats knownStringFormat accepts.
+var formatDrawers = map[string]func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string{
+	"uuid": (*GenerationOptions).drawUUID,
+	"date-time": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.Just(time.Now().UTC().Format(time.RFC3339)).Draw(t, "date-time")
+	},
+	"date": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.Just(time.Now().UTC().Format("2006-01-02")).Draw(t, "date")
+	},
+	"time": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return drawTime(t)
+	},
+	"duration": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return drawDuration(t)
+	},
+	"email": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.StringMatching(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`).Draw(t, "email")
+	},
+	"hostname": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.StringMatching(`[a-zA-Z0-9\-\.]{1,253}`).Draw(t, "hostname")
+	},
+	"ipv4": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.StringMatching(`\d{1,3}(\.\d{1,3}){3}`).Draw(t, "ipv4")
+	},
+	"ipv6": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		// loose IPv6 matcher
+		return rapid.StringMatching(`([0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}`).Draw(t, "ipv6")
+	},
+	"uri": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.StringMatching(`https?://[^\s]+`).Draw(t, "uri")
+	},
+	"uri-reference": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return rapid.StringMatching(`[-A-Za-z0-9._~:/?#@!$&'()*+,;=%]+`).Draw(t, "uri-reference")
+	},
+	"byte": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		minBytes, maxBytes := opts.byteCountRange(schema)
+		b := rapid.SliceOfN(rapid.Byte(), minBytes, maxBytes).Draw(t, "bytes")
+		return base64.StdEncoding.EncodeToString(b)
+	},
+	"json-pointer": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return drawJSONPointer(t)
+	},
+	"relative-json-pointer": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return drawRelativeJSONPointer(t)
+	},
+	"iban": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return drawIBAN(t)
+	},
+	"bic": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		return drawBIC(t)
+	},
+	"color":     drawHexColor,
+	"hex-color": drawHexColor,
+	"int32":     drawDecimal,
+	"int64":     drawDecimal,
+	"uint64":    drawDecimal,
+	"binary": func(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+		// any octet sequence – represent as base64 to keep valid JSON
+		b := rapid.SliceOfN(rapid.Byte(), 0, -1).Draw(t, "bytes")
+		return base64.StdEncoding.EncodeToString(b)
+	},
+}
+
+// decimalRange is the range of a string-encoded integer format, as protobuf JSON
+// encodes 64-bit integers: magnitudes up to neg for negative values, up to pos for others
+type decimalRange struct {
+	neg, pos uint64
+}
+
+// decimalFormats are the formats of string-encoded integers
+var decimalFormats = map[string]decimalRange{
+	"int32":  {neg: 1 << 31, pos: 1<<31 - 1},
+	"int64":  {neg: 1 << 63, pos: 1<<63 - 1},
+	"uint64": {neg: 0, pos: math.MaxUint64},
+}
+
+// decimalLength is a length of decimal text, counting the sign of negative values
+type decimalLength struct {
+	length   int
+	negative bool
+}
+
+// lengths returns the lengths in [minLength, maxLength] of decimal text in r, where
+// a negative maxLength is unbounded
+func (r decimalRange) lengths(minLength, maxLength int) []decimalLength {
+	var lengths []decimalLength
+	for n := max(1, minLength); n <= 21 && (maxLength < 0 || n <= maxLength); n++ {
+		if lo, _, ok := digitRange(n); ok && lo <= r.pos {
+			lengths = append(lengths, decimalLength{n, false})
+		}
+		if lo, _, ok := digitRange(n - 1); ok && max(lo, 1) <= r.neg {
+			lengths = append(lengths, decimalLength{n, true})
+		}
+	}
+	return lengths
+}
+
+// check returns an error unless s is decimal text in r
+func (r decimalRange) check(s string) error {
+	magnitude, negative := strings.CutPrefix(s, "-")
+	n, err := strconv.ParseUint(magnitude, 10, 64)
+	if err != nil {
+		return err
+	}
+	if negative && n > r.neg || !negative && n > r.pos {
+		return fmt.Errorf("%s is out of range", s)
+	}
+	return nil
+}
+
+// digitRange returns the smallest and largest number with digits decimal digits that
+// fits a uint64, or false if there is none
+func digitRange(digits int) (uint64, uint64, bool) {
+	if digits < 1 || digits > 20 {
+		return 0, 0, false
+	}
+	pow := func(n int) uint64 {
+		p := uint64(1)
+		for range n {
+			p *= 10
+		}
+		return p
+	}
+	lo, hi := uint64(0), uint64(math.MaxUint64)
+	if digits > 1 {
+		lo = pow(digits - 1)
+	}
+	if digits < 20 {
+		hi = pow(digits) - 1
+	}
+	return lo, hi, true
+}
+
+// drawDecimal draws a string-encoded integer of the schema's format, within its range
+// and with a length within minLength and maxLength. genString fails up front if no
+// value has such a length.
+func drawDecimal(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+	r := decimalFormats[schema.Format]
+	maxLength := -1
+	if schema.MaxLength != nil {
+		maxLength = int(*schema.MaxLength)
+	}
+	l := rapid.SampledFrom(r.lengths(int(schema.MinLength), maxLength)).Draw(t, "length")
+	digits, bound := l.length, r.pos
+	if l.negative {
+		digits, bound = digits-1, r.neg
+	}
+	lo, hi, _ := digitRange(digits)
+	if l.negative {
+		lo = max(lo, 1)
+	}
+	text := strconv.FormatUint(rapid.Uint64Range(lo, min(hi, bound)).Draw(t, schema.Format), 10)
+	if l.negative {
+		return "-" + text
+	}
+	return text
+}
+
+// drawHexColor draws a CSS hex color: #rgb, #rrggbb or #rrggbbaa
+func drawHexColor(opts *GenerationOptions, schema *openapi3.Schema, t *rapid.T) string {
+	return rapid.StringMatching(`#([0-9a-f]{3}|[0-9a-f]{6}|[0-9a-f]{8})`).Draw(t, "color")
+}
+
+// embeddedJSON returns a generator of JSON documents as text for strings with
+// format json or a JSON contentMediaType, drawn from their contentSchema if they
+// have one and base64 encoded for contentEncoding base64. Documents whose text is
+// outside minLength and maxLength or does not match pattern are drawn again. It
+// returns nil for other strings.
+func (opts *GenerationOptions) embeddedJSON(schema *openapi3.Schema) *rapid.Generator[string] {
+	mediaType, _ := keyword(schema, "contentMediaType")
+	if mediaType, ok := mediaType.(string); schema.Format != "json" && (!ok || !isJSONMediaType(mediaType)) {
+		return nil
+	}
+	contentSchema := &openapi3.Schema{}
+	if raw, ok := keyword(schema, "contentSchema"); ok {
+		contentSchema = subSchema(raw)
+	}
+	encoding, _ := keyword(schema, "contentEncoding")
+	// the shortest JSON document is a single digit, 4 characters in base64
+	shortest := 1
+	if encoding == "base64" {
+		shortest = 4
+	}
+	var patternRe *regexp.Regexp
+	if schema.Pattern != "" {
+		patternRe, _ = regexp.Compile(schema.Pattern)
+	}
+	minLength, maxLength := int(schema.MinLength), -1
+	if schema.MaxLength != nil {
+		maxLength = int(*schema.MaxLength)
+	}
+	fits := func(text string) bool {
+		n := opts.LengthSemantics.stringLength(text)
+		return n >= minLength && (maxLength < 0 || n <= maxLength) && (patternRe == nil || patternRe.MatchString(text))
+	}
+	if maxLength >= 0 && maxLength < shortest {
+		return rapid.Custom(func(t *rapid.T) string {
+			panic(fmt.Sprintf("no JSON document fits maxLength %d at '%s'", maxLength, opts.pointer))
+		})
+	}
+
+	documents := opts.childAt(nil, "contentSchema").GenFromSchema(contentSchema)
+	attempts := opts.retryLimit(embeddedJSONAttempts)
+	return rapid.Custom(func(t *rapid.T) string {
+		for attempt := 0; attempt < attempts; attempt++ {
+			text := string(documents.Draw(t, "document"))
+			if encoding == "base64" {
+				text = base64.StdEncoding.EncodeToString([]byte(text))
+			}
+			if fits(text) {
+				return text
+			}
+			opts.checkTimeout()
+		}
+		panic(retryError(fmt.Sprintf("a JSON document with length in [%d, %d] at '%s'", minLength, maxLength, opts.pointer), attempts))
+	})
+}
+
+// embeddedJSONAttempts is how often an embedded JSON document is drawn until its
+// text satisfies the string's length bounds and pattern, unless RetryLimit is set
+const embeddedJSONAttempts = 100
+
+// byteCountRange returns the range of decoded byte counts of format byte strings
+// that satisfy minLength and maxLength in opts.ByteLengthSemantics, -1 for no
+// maximum. Padded base64 encodes every 1 to 3 bytes as 4 characters, so with
+// ByteLengthEncoded the range is empty if no multiple of 4 is within the bounds.
+func (opts *GenerationOptions) byteCountRange(schema *openapi3.Schema) (int, int) {
+	minBytes, maxBytes := int(schema.MinLength), -1
+	if schema.MaxLength != nil {
+		maxBytes = int(*schema.MaxLength)
+	}
+	if opts.ByteLengthSemantics == ByteLengthDecoded {
+		return minBytes, maxBytes
+	}
+	// at least minLength characters need ceil(minLength/4) groups, of which the
+	// last holds at least one byte
+	minGroups := (minBytes + 3) / 4
+	minBytes = max(3*minGroups-2, 0)
+	if maxBytes >= 0 {
+		maxBytes = 3 * (maxBytes / 4)
+	}
+	return minBytes, maxBytes
+}
+
+// drawTime draws an RFC 3339 full-time: hh:mm:ss, optionally with fractional
+// seconds, and an offset that is either Z or a numeric +hh:mm/-hh:mm
+func drawTime(t *rapid.T) string {
+	s := fmt.Sprintf("%02d:%02d:%02d",
+		rapid.IntRange(0, 23).Draw(t, "time-hour"),
+		rapid.IntRange(0, 59).Draw(t, "time-minute"),
+		rapid.IntRange(0, 59).Draw(t, "time-second"))
+	if rapid.Bool().Draw(t, "time-has-fraction") {
+		s += "." + rapid.StringMatching(`[0-9]{1,9}`).Draw(t, "time-fraction")
+	}
+	if rapid.Bool().Draw(t, "time-utc") {
+		return s + "Z"
+	}
+	return s + fmt.Sprintf("%s%02d:%02d",
+		rapid.SampledFrom([]string{"+", "-"}).Draw(t, "time-offset-sign"),
+		rapid.IntRange(0, 23).Draw(t, "time-offset-hour"),
+		rapid.IntRange(0, 59).Draw(t, "time-offset-minute"))
+}
+
+// drawDuration draws an RFC 3339 (appendix A) duration: weeks alone (P2W), or a
+// run of consecutive date units (Y, M, D) and/or a run of consecutive time units
+// after T (H, M, S), e.g. P1Y2M, PT0S or P3DT4H5M6S
+func drawDuration(t *rapid.T) string {
+	if rapid.Bool().Draw(t, "duration-weeks") {
+		return fmt.Sprintf("P%dW", rapid.IntRange(0, 520).Draw(t, "duration-week-count"))
+	}
+	run := func(units []string, label string) string {
+		// an empty run is drawn as start == end
+		start := rapid.IntRange(0, len(units)).Draw(t, label+"-start")
+		end := rapid.IntRange(start, len(units)).Draw(t, label+"-end")
+		var s strings.Builder
+		for _, unit := range units[start:end] {
+			fmt.Fprintf(&s, "%d%s", rapid.IntRange(0, 1000).Draw(t, label+"-"+unit), unit)
+		}
+		return s.String()
+	}
+	date := run([]string{"Y", "M", "D"}, "duration-date")
+	clock := run([]string{"H", "M", "S"}, "duration-time")
+	if date == "" && clock == "" {
+		clock = fmt.Sprintf("%dS", rapid.IntRange(0, 1000).Draw(t, "duration-seconds"))
+	}
+	if clock != "" {
+		clock = "T" + clock
+	}
+	return "P" + date + clock
+}
+
+// drawJSONPointer draws an RFC 6901 JSON pointer: "" or a run of /-prefixed reference
+// tokens, in which ~ and / are escaped as ~0 and ~1
+func drawJSONPointer(t *rapid.T) string {
+	return rapid.StringMatching(`(/([^/~]|~[01]){0,8}){0,4}`).Draw(t, "json-pointer")
+}
+
+// drawRelativeJSONPointer draws a relative JSON pointer: the number of levels to go
+// up, followed by # (the key or index reached) or a JSON pointer, e.g. 0#, 1 or 2/foo
+func drawRelativeJSONPointer(t *rapid.T) string {
+	up := strconv.Itoa(rapid.IntRange(0, 100).Draw(t, "relative-json-pointer-up"))
+	if rapid.Bool().Draw(t, "relative-json-pointer-hash") {
+		return up + "#"
+	}
+	return up + drawJSONPointer(t)
+}
+
+// patternLengthAttempts is how often a pattern string is drawn when the
+// PatternFunc ignores the length bounds, unless RetryLimit is set
+const patternLengthAttempts = 20
+
+// drawPattern draws a string matching pattern using the PatternFunc. PatternFuncs
+// are told the length bounds but may not honor them, e.g. rapid.StringMatching,
+// so strings outside the bounds are redrawn.
+func (opts *GenerationOptions) drawPattern(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+	if opts.PatternFunc != nil {
+		if opts.PatternRepeatCap > 0 {
+			limit := opts.PatternRepeatCap
+			if maxLength >= 0 {
+				limit = min(limit, maxLength)
+			}
+			// a single repetition may have to reach minLength
+			limit = max(limit, minLength)
+			pattern = capRepetitions(pattern, limit)
+		}
+		attempts := opts.retryLimit(patternLengthAttempts)
+		for attempt := 0; attempt < attempts; attempt++ {
+			s := opts.PatternFunc(pattern, format, minLength, maxLength, t)
+			opts.checkTimeout()
+			n := utf8.RuneCountInString(s)
+			if n >= minLength && (maxLength < 0 || n <= maxLength) {
+				return s
+			}
+		}
+		panic(retryError(fmt.Sprintf("pattern '%s' with length in [%d, %d]", pattern, minLength, maxLength), attempts))
+	}
+	panic("schema has pattern '" + pattern + "' but no PatternFunc was provided. Use WithPatternFunc() to set a custom pattern generator.")
+}
+
+// capRepetitions rewrites the unbounded quantifiers of pattern to repeat at most limit
+// times: * becomes {0,limit}, + becomes {1,max(1,limit)} and {n,} becomes {n,max(n,limit)}.
+// The rest of the pattern is kept as written, so it works for any regex dialect.
+func capRepetitions(pattern string, limit int) string {
+	var out strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			out.WriteString(pattern[i : i+2])
+			i++
+			continue
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+			// a ] right after [ or [^ is a literal
+			if next := strings.TrimPrefix(pattern[i+1:], "^"); strings.HasPrefix(next, "]") {
+				n := len(pattern[i+1:]) - len(next) + 1
+				out.WriteString(pattern[i : i+1+n])
+				i += n
+				continue
+			}
+		case c == '*' && i > 0:
+			fmt.Fprintf(&out, "{0,%d}", limit)
+			continue
+		case c == '+' && i > 0:
+			fmt.Fprintf(&out, "{1,%d}", max(1, limit))
+			continue
+		case c == '{':
+			if m := openRepeat.FindStringSubmatch(pattern[i:]); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				fmt.Fprintf(&out, "{%d,%d}", n, max(n, limit))
+				i += len(m[0]) - 1
+				continue
+			}
+		}
+		out.WriteByte(c)
+	}
+	return out.String()
+}
+
+// openRepeat matches a {n,} quantifier
+var openRepeat = regexp.MustCompile(`^\{(\d+),\}`)
+
+// drawUUID draws a uuid of the configured version. The x-uuid-version extension
+// on the schema takes precedence over GenerationOptions.UUIDVersion.
+func (opts *GenerationOptions) drawUUID(schema *openapi3.Schema, t *rapid.T) string {
+	version := opts.UUIDVersion
+	if v, ok := schema.Extensions["x-uuid-version"].(float64); ok {
+		version = int(v)
+	}
+	if version == 0 {
+		version = 4
+	}
+
+	var id uuid.UUID
+	copy(id[:], rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "uuid-bytes"))
+	if version == 7 {
+		// 48 bit big-endian unix milliseconds
+		ms := rapid.Uint64Range(0, 1<<48-1).Draw(t, "uuid-unix-ms")
+		for i := 0; i < 6; i++ {
+			id[i] = byte(ms >> (40 - 8*i))
+		}
+	}
+	id[6] = id[6]&0x0f | byte(version)<<4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+
+	if opts.UUIDUppercase {
+		return strings.ToUpper(id.String())
+	}
+	return id.String()
+}
+
+func (opts *GenerationOptions) genInteger(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+
+	minLength := int64(math.MinInt64)
+	maxLength := int64(math.MaxInt64)
+	if schema.Min != nil {
+		m, ok := integerMinimum(*schema.Min, schema.ExclusiveMin)
+		if !ok {
+			return genFail(fmt.Sprintf("minimum %v leaves no int64 values", *schema.Min))
+		}
+		minLength = m
+	}
+	if schema.Max != nil {
+		m, ok := integerMaximum(*schema.Max, schema.ExclusiveMax)
+		if !ok {
+			return genFail(fmt.Sprintf("maximum %v leaves no int64 values", *schema.Max))
+		}
+		maxLength = m
+	}
+
+	// clamp by integer format if provided
+	switch schema.Format {
+	case "int32":
+		if minLength < math.MinInt32 {
+			minLength = math.MinInt32
+		}
+		if maxLength > math.MaxInt32 {
+			maxLength = math.MaxInt32
+		}
+	}
+
+	if opts.Integer53BitSafe {
+		// a bound beyond the safe range in the schema explicitly asks for such integers
+		if schema.Min == nil && maxLength >= -maxSafeInteger {
+			minLength = max(minLength, -maxSafeInteger)
+		}
+		if schema.Max == nil && minLength <= maxSafeInteger {
+			maxLength = min(maxLength, maxSafeInteger)
+		}
+	}
+
+	base := rapid.Int64Range(minLength, maxLength)
+
+	// multipleOf
+	if schema.MultipleOf != nil && *schema.MultipleOf != 0 {
+		mult, ok := integerStep(*schema.MultipleOf)
+		if !ok {
+			return genFail(fmt.Sprintf("multipleOf %v has no int64 multiples", *schema.MultipleOf))
+		}
+
+		// the first and last multiples strictly inside the bounds, which are already
+		// adjusted for exclusiveMinimum and exclusiveMaximum
+		highestMultiplePossible := floorDiv(maxLength, mult)
+		lowestMultiplePossible := ceilDiv(minLength, mult)
+		if lowestMultiplePossible > highestMultiplePossible {
+			return genFail("multipleOf is too large for the given range")
+		}
+		base = rapid.Map(rapid.Int64Range(lowestMultiplePossible, highestMultiplePossible), func(v int64) int64 {
+			return v * mult
+		})
+	}
+
+	gen := rapid.Map(base, func(v int64) json.RawMessage { return marshal(v) })
+	if opts.BigIntegers && !opts.Integer53BitSafe && schema.Min == nil && schema.Max == nil && schema.MultipleOf == nil &&
+		schema.Format != "int32" && schema.Format != "int64" {
+		gen = rapid.OneOf(gen, genBigInteger())
+	}
+	return wrapNullable(schema, gen)
+}
+
+// integerStep returns the smallest positive integer that is a multiple of the
+// multipleOf m, e.g. 5 for 2.5 and 1 for 0.1
+func integerStep(m float64) (int64, bool) {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(math.Abs(m), 'g', -1, 64))
+	if !ok || !r.Num().IsInt64() || r.Num().Int64() == 0 {
+		return 0, false
+	}
+	return r.Num().Int64(), true
+}
+
+// floorDiv divides a by the positive b, rounding toward negative infinity
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// ceilDiv divides a by the positive b, rounding toward positive infinity
+func ceilDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a > 0 {
+		q++
+	}
+	return q
+}
+
+// maxSafeInteger is the largest integer that float64, and so JavaScript, represents
+// exactly along with all smaller ones: 2^53-1
+const maxSafeInteger = 1<<53 - 1
+
+// integerMinimum returns the smallest int64 allowed by the minimum f.
+// kin-openapi parses bounds as float64, and above 2^53 several integers parse to
+// the same float64, so the result is allowed whichever of them the spec wrote.
+func integerMinimum(f float64, exclusive bool) (int64, bool) {
+	switch {
+	case f < math.MinInt64:
+		return math.MinInt64, true
+	case f >= math.MaxInt64:
+		return 0, false
+	case f != math.Trunc(f):
+		return int64(math.Ceil(f)), true
+	}
+	_, hi := parsedIntegers(f)
+	if exclusive {
+		if hi == math.MaxInt64 {
+			return 0, false
+		}
+		hi++
+	}
+	return hi, true
+}
+
+// integerMaximum returns the largest int64 allowed by the maximum f, see integerMinimum
+func integerMaximum(f float64, exclusive bool) (int64, bool) {
+	switch {
+	case f > math.MaxInt64:
+		return math.MaxInt64, true
+	case f < math.MinInt64:
+		return 0, false
+	case f != math.Trunc(f):
+		return int64(math.Floor(f)), true
+	}
+	lo, _ := parsedIntegers(f)
+	if exclusive {
+		if lo == math.MinInt64 {
+			return 0, false
+		}
+		lo--
+	}
+	return lo, true
+}
+
+// parsedIntegers returns the range of int64s that convert to the integral float64 f
+func parsedIntegers(f float64) (lo, hi int64) {
+	n := int64(math.MaxInt64)
+	if f < math.MaxInt64 {
+		n = int64(f)
+	}
+	lo, hi = n, n
+	for lo > math.MinInt64 && float64(lo-1) == f {
+		lo--
+	}
+	for hi < math.MaxInt64 && float64(hi+1) == f {
+		hi++
+	}
+	return lo, hi
+}
+
+// genBigInteger generates integers beyond the int64 range, up to 40 digits
+func genBigInteger() *rapid.Generator[json.RawMessage] {
 	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		if schema.Nullable {
-			return rapid.OneOf(g, genNull()).Draw(t, "either-null-or-gen")
+		offset := new(big.Int).SetBytes(rapid.SliceOfN(rapid.Byte(), 1, 16).Draw(t, "bigint-offset"))
+		v := offset.Add(offset, new(big.Int).SetUint64(math.MaxInt64+1))
+		if rapid.Bool().Draw(t, "bigint-negative") {
+			v.Neg(v)
+			// math.MinInt64 itself still fits in an int64
+			v.Sub(v, big.NewInt(1))
+		}
+		return json.RawMessage(v.String())
+	})
+}
+
+// genNumber draws numbers with rapid ranges, which shrink toward the valid value
+// nearest to zero: the minimum for ranges above zero, and the lowest valid multiple
+// with multipleOf. Failing payloads so shrink to the simplest value in range.
+func (opts *GenerationOptions) genNumber(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	minimum := -math.MaxFloat64
+	maximum := math.MaxFloat64
+	if schema.Min != nil {
+		m := *schema.Min
+		if schema.ExclusiveMin {
+			m = math.Nextafter(m, math.Inf(1))
+		}
+		minimum = m
+	}
+	if schema.Max != nil {
+		m := *schema.Max
+		if schema.ExclusiveMax {
+			m = math.Nextafter(m, -math.Inf(1))
+		}
+		maximum = m
+	}
+
+	var gen *rapid.Generator[json.RawMessage]
+	if schema.MultipleOf != nil && *schema.MultipleOf != 0 {
+		mult := *schema.MultipleOf
+		// kin-openapi doesn't validate multipleofs correctly
+		// TODO too much work to fix this in the broken libs
+		// not important anyways
+		lowest, highest, ok := multiplierRange(minimum, maximum, mult)
+		if !ok {
+			return genFail(fmt.Sprintf("no multiple of %v between %v and %v", mult, minimum, maximum))
+		}
+		gen = rapid.Map(rapid.Int64Range(lowest, highest), func(multiplier int64) json.RawMessage {
+			return opts.marshalMultiple(multiplier, mult, minimum, maximum)
+		})
+	} else {
+		gen = rapid.Map(rapid.Float64Range(minimum, maximum), opts.marshalNumber)
+		if stress := precisionStressValues(minimum, maximum); opts.PrecisionStress && len(stress) > 0 {
+			stressGen := rapid.Map(rapid.SampledFrom(stress), opts.marshalNumber)
+			// one draw in four is a precision boundary
+			gen = rapid.OneOf(gen, gen, gen, stressGen)
+		}
+	}
+	return wrapNullable(schema, gen)
+}
+
+// precisionStressValues returns the numbers within [minimum, maximum] that tell
+// float64, float32 and decimal implementations apart: sums that do not round trip,
+// 17 significant digits, the edges of the exactly representable integers and of
+// float32, and the closest neighbours of the bounds.
+func precisionStressValues(minimum, maximum float64) []float64 {
+	const maxSafeFloat = 1 << 53
+	candidates := []float64{
+		0.30000000000000004, // 0.1 + 0.2
+		1.0000000000000002,
+		0.12345678901234568,
+		maxSafeFloat - 1, maxSafeFloat, maxSafeFloat + 2,
+		1<<24 + 1, // the first integer float32 rounds
+		math.MaxFloat32,
+		math.SmallestNonzeroFloat64,
+	}
+	for _, c := range slices.Clone(candidates) {
+		candidates = append(candidates, -c)
+	}
+	if minimum > -math.MaxFloat64 {
+		candidates = append(candidates, minimum, math.Nextafter(minimum, math.Inf(1)))
+	}
+	if maximum < math.MaxFloat64 {
+		candidates = append(candidates, maximum, math.Nextafter(maximum, math.Inf(-1)))
+	}
+
+	var values []float64
+	for _, c := range candidates {
+		if c >= minimum && c <= maximum && !slices.Contains(values, c) {
+			values = append(values, c)
+		}
+	}
+	return values
+}
+
+// marshalNumber writes a generated number in the configured NumberFormat
+func (opts *GenerationOptions) marshalNumber(v float64) json.RawMessage {
+	if opts.NumberFormat == NumberFormatDecimal {
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if s == "-0" {
+			s = "0"
 		}
-		return g.Draw(t, "not-nullable-passthrough")
+		return json.RawMessage(s)
+	}
+	return marshal(v)
+}
+
+// marshalMultiple writes multiplier * mult, computed in decimal so that decimal
+// multipleOfs like 0.01 give exact values like 19.99 instead of 19.990000000000002.
+// Products that round outside [minimum, maximum] as float64 fall back to float math.
+func (opts *GenerationOptions) marshalMultiple(multiplier int64, mult, minimum, maximum float64) json.RawMessage {
+	step, err := decimal128.Parse(strconv.FormatFloat(mult, 'g', -1, 64))
+	if err != nil {
+		return opts.marshalNumber(float64(multiplier) * mult)
+	}
+	product := decimal128.FromInt64(multiplier).Mul(step)
+	if f := product.Float64(); product.IsNaN() || product.IsInf(0) || f < minimum || f > maximum {
+		return opts.marshalNumber(float64(multiplier) * mult)
+	}
+	if product.IsZero() {
+		return json.RawMessage("0")
+	}
+	if opts.NumberFormat == NumberFormatDecimal {
+		return json.RawMessage(decimal128.Format(product, 'f', -1))
+	}
+	encoded, err := product.MarshalJSON()
+	if err != nil {
+		return opts.marshalNumber(float64(multiplier) * mult)
+	}
+	return encoded
+}
+
+// multiplierWindow bounds how many multiples of a number's multipleOf are considered,
+// so multipliers fit an int64 and their products stay exact enough for validators
+const multiplierWindow = 1e7
+
+// multiplierRange returns the multipliers k for which k*mult lies within [minimum, maximum].
+// Ranges wider than multiplierWindow are narrowed to the multiples closest to zero,
+// or closest to the bound nearest to zero when the range does not contain zero.
+func multiplierRange(minimum, maximum, mult float64) (lowest, highest int64, ok bool) {
+	lo := math.Ceil(minimum / mult)
+	hi := math.Floor(maximum / mult)
+	if lo > hi {
+		return 0, 0, false
+	}
+
+	switch {
+	case hi < 0:
+		lo = math.Max(lo, hi-multiplierWindow)
+	case lo > 0:
+		hi = math.Min(hi, lo+multiplierWindow)
+	default:
+		lo = math.Max(lo, -multiplierWindow)
+		hi = math.Min(hi, multiplierWindow)
+	}
+	if hi < math.MinInt64/2 || lo > math.MaxInt64/2 {
+		// the only multiples in range are too large to represent as a multiplier
+		return 0, 0, false
+	}
+	// the products of the outer multipliers may round past the bounds
+	for lo <= hi && lo*mult < minimum {
+		lo++
+	}
+	for lo <= hi && hi*mult > maximum {
+		hi--
+	}
+	if lo > hi {
+		return 0, 0, false
+	}
+	return int64(lo), int64(hi), true
+}
+
+func (opts *GenerationOptions) genBoolean(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	gen := rapid.Map(rapid.Bool(), func(b bool) json.RawMessage { return marshal(b) })
+	return wrapNullable(schema, gen)
+}
+
+// ---------------- Array Generator ----------------
+
+func (opts *GenerationOptions) genArray(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	if schema.MaxItems != nil && *schema.MaxItems < schema.MinItems {
+		return genFail(fmt.Sprintf("maxItems %d is less than minItems %d at '%s'", *schema.MaxItems, schema.MinItems, opts.pointer))
+	}
+	if n := opts.FixedArrayLength; n != nil && (*n < int(schema.MinItems) || schema.MaxItems != nil && *n > int(*schema.MaxItems)) {
+		return genFail(fmt.Sprintf("fixed array length %d is outside minItems %d and maxItems %s at '%s'",
+			*n, schema.MinItems, formatLimit(schema.MaxItems), opts.pointer))
+	}
+	opts, recursive := opts.enterMinimal(schema)
+	if recursive && schema.MinItems > 0 {
+		return genFail(fmt.Sprintf("the minItems items of the array at '%s' contain the array again, so no finite value exists", opts.pointer))
+	}
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		var itemGen *rapid.Generator[json.RawMessage]
+		// Increase depth for recursive calls
+		childOpts := opts.childAt(schema.Items, "items")
+		if childOpts.Mode == ModeMergePatch {
+			// a patch replaces arrays, it does not merge into their items
+			childOpts.Mode = ModeRequest
+		}
+		if schema.Items != nil {
+			itemGen = childOpts.GenFromSchema(schema.Items.Value)
+		} else {
+			itemGen = childOpts.GenFromSchema(nil)
+		}
+
+		minLength := int(schema.MinItems)
+		maxLength := -1
+		if schema.MaxItems != nil {
+			maxLength = int(*schema.MaxItems)
+		}
+		if opts.FixedArrayLength != nil {
+			minLength, maxLength = *opts.FixedArrayLength, *opts.FixedArrayLength
+		}
+		if opts.atMaxDepth() {
+			// the depth cutoff ends recursion, even with a fixed length
+			minLength = int(schema.MinItems)
+			maxLength = minLength
+		}
+
+		var arrGen *rapid.Generator[[]json.RawMessage]
+		if raw, ok := keyword(schema, "prefixItems"); ok {
+			arrGen = opts.tupleSliceOf(schema, raw, itemGen, minLength, maxLength)
+		} else if raw, ok := keyword(schema, "contains"); ok {
+			containsGen := opts.childAt(nil, "contains").GenFromSchema(subSchema(raw))
+			arrGen = opts.containsSliceOf(schema, subSchema(raw), containsGen, itemGen, minLength, maxLength)
+		} else if schema.UniqueItems {
+			arrGen = opts.distinctSliceOf(itemGen, minLength, maxLength)
+		} else if childOpts.isPolymorphic(schema.Items) {
+			arrGen = childOpts.polymorphicSliceOf(schema.Items.Value, minLength, maxLength)
+		} else {
+			arrGen = rapid.SliceOfN(opts.indexedItems(itemGen, 0), minLength, maxLength)
+		}
+
+		g := rapid.Map(arrGen, func(arr []json.RawMessage) json.RawMessage {
+			opts.dropItemsFrom(len(arr))
+			return marshal(arr)
+		})
+
+		return wrapNullable(schema, g).Draw(t, "Array-Value")
 	})
 }
 
-// marshal wraps arbitrary Go into RawMessage
-func marshal(v any) json.RawMessage {
-	b, _ := json.Marshal(v)
-	return b
+// formatLimit formats an optional maxLength, maxItems or maxProperties
+func formatLimit(limit *uint64) string {
+	if limit == nil {
+		return "unset"
+	}
+	return strconv.FormatUint(*limit, 10)
 }
 
-// ---------------- Primitive Generators ----------------
+// tupleSliceOf draws arrays for the prefixItems keyword: the prefix items in order,
+// followed by items for the rest of the array. When items (or additionalItems) is
+// the false schema, no items follow the prefix and arrays are exactly as long as
+// the prefix, or maxItems if that is shorter.
+func (opts *GenerationOptions) tupleSliceOf(
+	schema *openapi3.Schema,
+	raw any,
+	itemGen *rapid.Generator[json.RawMessage],
+	minLength, maxLength int,
+) *rapid.Generator[[]json.RawMessage] {
+	rawPrefix, _ := raw.([]any)
+	prefixGens := make([]*rapid.Generator[json.RawMessage], len(rawPrefix))
+	for i, rawItem := range rawPrefix {
+		prefixGens[i] = opts.childAt(nil, "prefixItems", strconv.Itoa(i)).GenFromSchema(subSchema(rawItem))
+	}
 
-func (opts *GenerationOptions) genString(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	// Custom string generator with early returns using draw
-	stringGen := rapid.Custom(func(t *rapid.T) string {
-		// Default string with length bounds
-		minLength := int(schema.MinLength)
-		maxLength := -1
-		if schema.MaxLength != nil {
-			maxLength = int(*schema.MaxLength)
+	prefixLength := len(prefixGens)
+	if maxLength >= 0 {
+		prefixLength = min(prefixLength, maxLength)
+	}
+	closed := schema.Items != nil && isFalseSchema(schema.Items.Value)
+	if additional, ok := keyword(schema, "additionalItems"); ok && isFalseSchema(subSchema(additional)) {
+		closed = true
+	}
+
+	minExtras := max(0, minLength-prefixLength)
+	maxExtras := -1
+	if maxLength >= 0 {
+		maxExtras = maxLength - prefixLength
+	}
+	if closed {
+		if minExtras > 0 {
+			return rapid.Custom(func(t *rapid.T) []json.RawMessage {
+				panic(fmt.Sprintf("minItems is %d, but items is false after %d prefixItems", minLength, len(prefixGens)))
+			})
 		}
+		maxExtras = 0
+	}
 
-		// Handle pattern
-		if schema.Pattern != "" {
-			if opts.PatternFunc != nil {
-				return opts.PatternFunc(schema.Pattern, schema.Format, minLength, maxLength, t)
-			}
-			panic("schema has pattern '" + schema.Pattern + "' but no PatternFunc was provided. Use WithPatternFunc() to set a custom pattern generator.")
-		}
-
-		// Special formats with early returns
-		switch schema.Format {
-		case "uuid":
-			return rapid.Just(uuid.NewString()).Draw(t, "uuid")
-		case "date-time":
-			return rapid.Just(time.Now().UTC().Format(time.RFC3339)).Draw(t, "date-time")
-		case "date":
-			return rapid.Just(time.Now().UTC().Format("2006-01-02")).Draw(t, "date")
-		case "email":
-			return rapid.StringMatching(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`).Draw(t, "email")
-		case "hostname":
-			return rapid.StringMatching(`[a-zA-Z0-9\-\.]{1,253}`).Draw(t, "hostname")
-		case "ipv4":
-			return rapid.StringMatching(`\d{1,3}(\.\d{1,3}){3}`).Draw(t, "ipv4")
-		case "ipv6":
-			// loose IPv6 matcher
-			return rapid.StringMatching(`([0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}`).Draw(t, "ipv6")
-		case "uri":
-			return rapid.StringMatching(`https?://[^\s]+`).Draw(t, "uri")
-		case "uri-reference":
-			return rapid.StringMatching(`[-A-Za-z0-9._~:/?#@!$&'()*+,;=%]+`).Draw(t, "uri-reference")
-		case "byte":
-			// base64-encoded bytes
-			b := rapid.SliceOfN(rapid.Byte(), 0, -1).Draw(t, "bytes")
-			return base64.StdEncoding.EncodeToString(b)
-		case "binary":
-			// any octet sequence – represent as base64 to keep valid JSON
-			b := rapid.SliceOfN(rapid.Byte(), 0, -1).Draw(t, "bytes")
-			return base64.StdEncoding.EncodeToString(b)
-		}
-
-
-		return rapid.StringN(minLength, maxLength, -1).Draw(t, "string")
+	return rapid.Custom(func(t *rapid.T) []json.RawMessage {
+		arr := make([]json.RawMessage, prefixLength)
+		for i := range arr {
+			arr[i] = opts.drawAt(prefixGens[i], t, fmt.Sprintf("prefixItem-%d", i), strconv.Itoa(i))
+		}
+		extrasGen := rapid.SliceOfN(opts.indexedItems(itemGen, prefixLength), minExtras, maxExtras)
+		return append(arr, extrasGen.Draw(t, "extra-items")...)
 	})
+}
 
-	// Second custom generator that draws from stringGen and returns json.RawMessage
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		if len(schema.Enum) > 0 {
-			choices := make([]json.RawMessage, len(schema.Enum))
-			for i, e := range schema.Enum {
-				choices[i] = marshal(e)
+// isPolymorphic reports whether items are generated as one of several oneOf branches
+func (opts *GenerationOptions) isPolymorphic(items *openapi3.SchemaRef) bool {
+	if _, ok := opts.Overrides[opts.pointer]; ok || items == nil || items.Value == nil {
+		return false
+	}
+	s := items.Value
+	// enum, const, allOf and anyOf take precedence over oneOf, see GenFromSchema
+	return len(s.OneOf) > 1 && len(s.Enum) == 0 && !hasKeyword(s, "const") && len(s.AllOf) == 0 && len(s.AnyOf) == 0
+}
+
+// polymorphicSliceOf draws arrays of items with a oneOf, choosing the branch of
+// every element. Half of the arrays cycle through the branches, so they mix
+// element shapes even when shrunk, the others choose each element's branch freely.
+func (opts *GenerationOptions) polymorphicSliceOf(items *openapi3.Schema, minLength, maxLength int) *rapid.Generator[[]json.RawMessage] {
+	branches := rapid.SliceOfN(rapid.IntRange(0, len(items.OneOf)-1), minLength, maxLength)
+	return rapid.Custom(func(t *rapid.T) []json.RawMessage {
+		indices := branches.Draw(t, "item-branches")
+		if rapid.Bool().Draw(t, "item-branches-cycle") {
+			start := rapid.IntRange(0, len(items.OneOf)-1).Draw(t, "item-branches-start")
+			for i := range indices {
+				indices[i] = (start + i) % len(items.OneOf)
 			}
-			return rapid.SampledFrom(choices).Draw(t, "String-Enum")
 		}
-
-		str := stringGen.Draw(t, "string-value")
-		gen := rapid.Just(marshal(str))
-		return wrapNullable(schema, gen).Draw(t, "String-Value")
+		arr := make([]json.RawMessage, len(indices))
+		for i, idx := range indices {
+			arr[i] = opts.drawAt(opts.oneOfBranch(items, idx), t, fmt.Sprintf("item-%d", i), strconv.Itoa(i))
+		}
+		return arr
 	})
 }
 
-func (opts *GenerationOptions) genInteger(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		minLength := int64(math.MinInt64)
-		maxLength := int64(math.MaxInt64)
-		if schema.Min != nil {
-			m := int64(*schema.Min)
-			if schema.ExclusiveMin {
-				m++
-			}
-			minLength = m
+// containsSliceOf draws arrays for the contains keyword: between minContains (default 1)
+// and maxContains items matching both contains and items, and the rest drawn from items.
+// When maxContains is set, the other items must not match contains, so they are
+// redrawn until they do not. With uniqueItems, items already in the array are
+// redrawn as well.
+func (opts *GenerationOptions) containsSliceOf(
+	schema *openapi3.Schema,
+	contains *openapi3.Schema,
+	containsGen, itemGen *rapid.Generator[json.RawMessage],
+	minLength, maxLength int,
+) *rapid.Generator[[]json.RawMessage] {
+	minContains, ok := keywordInt(schema, "minContains")
+	if !ok {
+		minContains = 1
+	}
+	maxContains, limited := keywordInt(schema, "maxContains")
+	if !limited {
+		maxContains = minContains + uniqueItemsSpread
+	}
+	if maxLength >= 0 {
+		maxContains = min(maxContains, maxLength)
+	}
+	if maxContains < minContains {
+		return rapid.Custom(func(t *rapid.T) []json.RawMessage {
+			panic(fmt.Sprintf("contains needs %d matching items, but at most %d fit", minContains, maxContains))
+		})
+	}
+
+	attempts := opts.retryLimit(patternValueAttempts)
+	return rapid.Custom(func(t *rapid.T) []json.RawMessage {
+		matching := rapid.IntRange(minContains, maxContains).Draw(t, "contains-count")
+		upper := maxLength
+		if upper < 0 {
+			upper = max(minLength, matching) + uniqueItemsSpread
 		}
-		if schema.Max != nil {
-			m := int64(*schema.Max)
-			if schema.ExclusiveMax {
-				m--
+		n := rapid.IntRange(max(minLength, matching), upper).Draw(t, "contains-length")
+
+		// with uniqueItems, items are compared as canonical JSON, like distinctSliceOf
+		seen := make(map[string]bool, n)
+		draw := func(gens []*rapid.Generator[json.RawMessage], accept func(json.RawMessage) bool, what, token string) (json.RawMessage, bool) {
+			for attempt := 0; attempt < attempts; attempt++ {
+				v := opts.drawAt(gens[attempt%len(gens)], t, what, token)
+				opts.checkTimeout()
+				if schema.UniqueItems && seen[canonicalJSON(v)] || !accept(v) {
+					continue
+				}
+				seen[canonicalJSON(v)] = true
+				return v, true
 			}
-			maxLength = m
+			return nil, false
 		}
 
-		// clamp by integer format if provided
-		switch schema.Format {
-		case "int32":
-			if minLength < math.MinInt32 {
-				minLength = math.MinInt32
+		items := make([]json.RawMessage, 0, n)
+		for range matching {
+			// either side may be the narrower one, so draws alternate between them
+			what := "an item matching both items and contains"
+			if schema.UniqueItems {
+				what = "a distinct item matching both items and contains"
 			}
-			if maxLength > math.MaxInt32 {
-				maxLength = math.MaxInt32
+			item, ok := draw([]*rapid.Generator[json.RawMessage]{containsGen, itemGen}, func(v json.RawMessage) bool {
+				return (schema.Items == nil || satisfies(schema.Items.Value, v)) && satisfies(contains, v)
+			}, what, strconv.Itoa(len(items)))
+			if !ok {
+				if schema.UniqueItems && len(items) >= minContains {
+					// settle for fewer distinct matches, but no fewer than minContains
+					break
+				}
+				panic(retryError(what, attempts))
 			}
+			items = append(items, item)
 		}
-
-		base := rapid.Int64Range(minLength, maxLength)
-
-		// multipleOf
-		if schema.MultipleOf != nil && *schema.MultipleOf != 0 {
-			mult := int64(*schema.MultipleOf)
-
-			highestMultiplePossible := maxLength / mult
-			lowestMultiplePossible := minLength / mult
-			if lowestMultiplePossible > highestMultiplePossible {
-				panic("multipleOf is too large for the given range")
+		for range n - matching {
+			what := "an item not matching contains"
+			if schema.UniqueItems {
+				what = "a distinct item"
 			}
-			base = rapid.Map(rapid.Int64Range(lowestMultiplePossible, highestMultiplePossible), func(v int64) int64 {
-				return v * mult
-			})
-		}
-
-		gen := rapid.Map(base, func(v int64) json.RawMessage { return marshal(v) })
-
-		if len(schema.Enum) > 0 {
-			opts := make([]json.RawMessage, len(schema.Enum))
-			for i, e := range schema.Enum {
-				opts[i] = marshal(e)
+			// fillers are drawn at the end, /-, until their position is known
+			filler, ok := draw([]*rapid.Generator[json.RawMessage]{itemGen}, func(v json.RawMessage) bool {
+				return !limited || !satisfies(contains, v)
+			}, what, "-")
+			if !ok {
+				if schema.UniqueItems && len(items) >= minLength {
+					// settle for fewer items than drawn, but no fewer than minItems
+					break
+				}
+				panic(retryError(what, attempts))
 			}
-			return rapid.SampledFrom(opts).Draw(t, "Integer-Enum")
+			at := rapid.IntRange(0, len(items)).Draw(t, "filler-position")
+			items = slices.Insert(items, at, filler)
+			opts.insertItem(at)
 		}
-
-		return wrapNullable(schema, gen).Draw(t, "Integer-Value")
+		return items
 	})
 }
 
-func (opts *GenerationOptions) genNumber(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		minimum := -math.MaxFloat64
-		maximum := math.MaxFloat64
-		if schema.Min != nil {
-			m := *schema.Min
-			if schema.ExclusiveMin {
-				m = math.Nextafter(m, math.Inf(1))
-			}
-			minimum = m
+const (
+	// uniqueItemsSpread is how many items above minItems unbounded unique arrays may have
+	uniqueItemsSpread = 10
+	// uniqueItemsAttempts is how many candidates are drawn per item of a unique array
+	uniqueItemsAttempts = 4
+	// uniqueItemsMinAttempts is how many candidates may be drawn to reach minItems,
+	// unless RetryLimit is set
+	uniqueItemsMinAttempts = 200
+)
+
+// distinctSliceOf draws a target length, then candidates until that many distinct
+// items are found or the attempts run out, drawing longer to reach minLength.
+// Unlike rapid.SliceOfNDistinct it does not fail when items have few distinct
+// values, as long as minLength is reached. Items are compared as canonical JSON,
+// so objects differing in key order are equal.
+func (opts *GenerationOptions) distinctSliceOf(itemGen *rapid.Generator[json.RawMessage], minLength, maxLength int) *rapid.Generator[[]json.RawMessage] {
+	minAttempts := opts.retryLimit(uniqueItemsMinAttempts)
+	return rapid.Custom(func(t *rapid.T) []json.RawMessage {
+		if maxLength < 0 {
+			maxLength = minLength + uniqueItemsSpread
 		}
-		if schema.Max != nil {
-			m := *schema.Max
-			if schema.ExclusiveMax {
-				m = math.Nextafter(m, -math.Inf(1))
+		n := rapid.IntRange(minLength, maxLength).Draw(t, "uniqueItems-length")
+
+		items := make([]json.RawMessage, 0, n)
+		seen := make(map[string]bool, n)
+		for attempt := 0; len(items) < n; attempt++ {
+			if len(items) >= minLength && attempt >= n*uniqueItemsAttempts {
+				// settle for fewer items than drawn, but no fewer than minItems
+				break
 			}
-			maximum = m
-		}
-
-		if schema.MultipleOf != nil && *schema.MultipleOf != 0 {
-			mult := *schema.MultipleOf
-			// kin-openapi doesn't validate multipleofs correctly
-			// TODO too much work to fix this in the broken libs
-			// not important anyways
-			// this is safe probably
-			minimum = math.Max(minimum, -2000000)
-			maximum = math.Min(maximum, 20000000)
-
-			if math.Abs(mult) > 1.0 {
-				minimum /= mult
-				maximum /= mult
+			if attempt >= max(n*uniqueItemsAttempts, minAttempts) {
+				break
 			}
-
-			multiplier := rapid.IntRange(int(minimum), int(maximum)).Draw(t, "Number-Multiplier")
-			multiplication := float64(multiplier) * mult
-			//
-			//valuaString := fmt.Sprintf("%.10f", multiplication)
-			//valuaStringG := fmt.Sprintf("%g", multiplication)
-			//fmt.Println(valuaString)
-			//fmt.Println(valuaStringG)
-			//
-			return marshal(multiplication)
-
-			//base = rapid.Map(rapid.IntRange(int(minimum), int(maximum)), func(v int) float64 {
-			//	return float64(v) * mult
-			//})
-		}
-
-		base := rapid.Float64Range(minimum, maximum)
-		gen := rapid.Map(base, func(v float64) json.RawMessage { return marshal(v) })
-
-		if len(schema.Enum) > 0 {
-			opts := make([]json.RawMessage, len(schema.Enum))
-			for i, e := range schema.Enum {
-				opts[i] = marshal(e)
+			item := opts.drawAt(itemGen, t, fmt.Sprintf("item-%d", attempt), strconv.Itoa(len(items)))
+			key := canonicalJSON(item)
+			if seen[key] {
+				continue
 			}
-			return rapid.SampledFrom(opts).Draw(t, "Number-Enum")
+			seen[key] = true
+			items = append(items, item)
 		}
-
-		return wrapNullable(schema, gen).Draw(t, "Number-Value")
+		if len(items) < minLength {
+			panic(retryError(fmt.Sprintf("uniqueItems with minItems %d (found %d distinct items)", minLength, len(items)), max(n*uniqueItemsAttempts, minAttempts)))
+		}
+		return items
 	})
 }
 
-func (opts *GenerationOptions) genBoolean(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		gen := rapid.Map(rapid.Bool(), func(b bool) json.RawMessage { return marshal(b) })
-		if len(schema.Enum) > 0 {
-			choices := make([]json.RawMessage, len(schema.Enum))
-			for i, e := range schema.Enum {
-				choices[i] = marshal(e)
-			}
-			return rapid.SampledFrom(choices).Draw(t, "Boolean-Enum")
-		}
-		return wrapNullable(schema, gen).Draw(t, "Boolean-Value")
-	})
+// canonicalJSON re-encodes a JSON value with sorted object keys and normalized numbers
+func canonicalJSON(value json.RawMessage) string {
+	var decoded any
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return string(value)
+	}
+	return string(marshal(decoded))
 }
 
-// ---------------- Array Generator ----------------
+// ---------------- Object Generator ----------------
 
-func (opts *GenerationOptions) genArray(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		var itemGen *rapid.Generator[json.RawMessage]
-		if schema.Items != nil {
-			// Increase depth for recursive calls
-			childOpts := &GenerationOptions{
-				depth:                   opts.depth + 1,
-				MaxDepth:                opts.MaxDepth,
-				AdditionalPropertiesMax: opts.AdditionalPropertiesMax,
-				PatternFunc:             opts.PatternFunc,
-			}
-			itemGen = childOpts.GenFromSchema(schema.Items.Value)
-		} else {
-			childOpts := &GenerationOptions{
-				depth:                   opts.depth + 1,
-				MaxDepth:                opts.MaxDepth,
-				AdditionalPropertiesMax: opts.AdditionalPropertiesMax,
-				PatternFunc:             opts.PatternFunc,
+func (opts *GenerationOptions) genObject(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	// Build lists of required and optional properties
+	var requiredPropsStrings []string
+	var optionalPropStrings []string
+	// in a merge patch, absent properties are left unchanged
+	mergePatch := opts.Mode == ModeMergePatch
+
+	// required readOnly properties that get a placeholder, see ReadOnlyRequiredSentinel
+	sentinels := make(map[string]bool)
+
+	// sorted, so draws with the same seed generate the same objects
+	for _, propName := range sortedKeys(schema.Properties) {
+		prop := schema.Properties[propName]
+		if prop != nil && opts.Mode.excludes(prop.Value) {
+			if opts.Mode != ModeRequest || opts.ReadOnlyRequired != ReadOnlyRequiredSentinel || !contains(schema.Required, propName) {
+				continue
 			}
-			itemGen = childOpts.GenFromSchema(nil)
+			sentinels[propName] = true
 		}
-
-		minLength := int(schema.MinItems)
-		maxLength := -1
-		if schema.MaxItems != nil {
-			maxLength = int(*schema.MaxItems)
+		if contains(schema.Required, propName) && !mergePatch {
+			requiredPropsStrings = append(requiredPropsStrings, propName)
+		} else if prop == nil || !isFalseSchema(prop.Value) {
+			// optional properties with a false schema must be absent
+			optionalPropStrings = append(optionalPropStrings, propName)
 		}
+	}
 
-		var arrGen *rapid.Generator[[]json.RawMessage]
-		if schema.UniqueItems {
-			arrGen = rapid.SliceOfNDistinct(itemGen, minLength, maxLength, func(e json.RawMessage) string { return string(e) })
+	// Add additional properties
+	// additionalProperties: false → NOT allowed
+	// additionalProperties: true → allowed (any type)
+	// additionalProperties: { schema } → allowed (with schema)
+	// not specified + no properties → allowed (free-form object)
+	// not specified + has properties → NOT allowed
+	isAllowedAdditionalProperties := false
+	if schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has {
+		isAllowedAdditionalProperties = true
+	} else if schema.AdditionalProperties.Has != nil && !*schema.AdditionalProperties.Has {
+		isAllowedAdditionalProperties = false
+	} else if schema.AdditionalProperties.Schema != nil {
+		isAllowedAdditionalProperties = true
+	} else if unevaluatedPropertiesFalse(schema) {
+		// no subschema evaluates other properties, see allOfMerges.get for allOf
+		isAllowedAdditionalProperties = false
+	} else if len(schema.Properties) == 0 {
+		isAllowedAdditionalProperties = true
+	}
+
+	patternProps := patternProperties(schema)
+
+	// required properties that are not declared get their schema from the
+	// patternProperties their name matches, or else additionalProperties
+	requiredPatternKeys := make(map[string][]patternProperty)
+	requiredExtras := make(map[string]*openapi3.SchemaRef)
+	for _, name := range schema.Required {
+		if _, declared := schema.Properties[name]; declared || mergePatch {
+			continue
+		}
+		if matched := matchingPatterns(patternProps, name); matched != nil {
+			requiredPatternKeys[name] = matched
+		} else if isAllowedAdditionalProperties || len(schema.Properties) > 0 && schema.AdditionalProperties.Has == nil && !unevaluatedPropertiesFalse(schema) {
+			// with properties declared, an unset additionalProperties only stops
+			// extras from being generated, it still allows them
+			requiredExtras[name] = schema.AdditionalProperties.Schema
 		} else {
-			arrGen = rapid.SliceOfN(itemGen, minLength, maxLength)
+			return genFail(fmt.Sprintf("required property '%s' is not declared, and neither patternProperties nor additionalProperties allow it", name))
 		}
+	}
+	numRequired := len(requiredPropsStrings) + len(requiredPatternKeys) + len(requiredExtras)
 
-		g := rapid.Map(arrGen, func(arr []json.RawMessage) json.RawMessage { return marshal(arr) })
+	declaredPatternKeys := make(map[string][]patternProperty)
+	for name := range schema.Properties {
+		if matched := matchingPatterns(patternProps, name); matched != nil {
+			declaredPatternKeys[name] = matched
+		}
+	}
+	dependents := dependentSchemas(schema)
+
+	// minProperties beyond the required properties is reached with optional
+	// properties first, and additional properties for the rest
+	minOptional := max(0, int(schema.MinProps)-numRequired)
+	minExtras := max(0, minOptional-len(optionalPropStrings))
+	if minExtras > 0 && !isAllowedAdditionalProperties && len(patternProps) == 0 {
+		return genFail(fmt.Sprintf("minProperties is %d, but the object only declares %d properties that can be generated",
+			schema.MinProps, numRequired+len(optionalPropStrings)))
+	}
+	minOptional = min(minOptional, len(optionalPropStrings))
+	if len(schema.Properties) == 0 && len(patternProps) == 0 && isAllowedAdditionalProperties && !opts.onlyRequired() {
+		minExtras = max(minExtras, opts.MapMinEntries)
+	}
 
-		return wrapNullable(schema, g).Draw(t, "Array-Value")
-	})
-}
+	opts, recursive := opts.enterMinimal(schema)
+	if recursive {
+		return genFail(fmt.Sprintf("the required properties of the object at '%s' contain the object again, so no finite value exists", opts.pointer))
+	}
 
-// ---------------- Object Generator ----------------
+	maxOptional := len(optionalPropStrings)
+	maxExtras := max(minExtras, opts.AdditionalPropertiesMax)
+	if opts.onlyRequired() {
+		// past the depth cutoff only required properties are generated, and just
+		// enough others for minProperties, so recursion through optional and
+		// additional properties ends here
+		maxOptional = minOptional
+		maxExtras = minExtras
+	}
 
-func (opts *GenerationOptions) genObject(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	// Build lists of required and optional properties
-	var requiredPropsStrings []string
-	var optionalPropStrings []string
+	// budget is how many properties maxProperties allows besides the required ones,
+	// shared by additional, pattern and optional properties; -1 if unbounded
+	budget := -1
+	if schema.MaxProps != nil {
+		if *schema.MaxProps < schema.MinProps {
+			return genFail(fmt.Sprintf("maxProperties %d is less than minProperties %d at '%s'", *schema.MaxProps, schema.MinProps, opts.pointer))
+		}
+		budget = int(*schema.MaxProps) - numRequired
+		if budget < 0 {
+			return genFail(fmt.Sprintf("maxProperties is %d, but the object requires %d properties", *schema.MaxProps, numRequired))
+		}
+		// minOptional and minExtras fit, as they only reach minProperties
+		maxOptional = min(maxOptional, budget)
+		maxExtras = min(maxExtras, budget-minOptional)
+		minExtras = min(minExtras, maxExtras)
+	}
 
-	for propName := range schema.Properties {
-		if contains(schema.Required, propName) {
-			requiredPropsStrings = append(requiredPropsStrings, propName)
+	// propertyNames constrains the keys of additional properties. With an enum, keys
+	// are sampled from its names that are neither declared nor matched by a pattern.
+	var propertyNames *openapi3.Schema
+	var extraKeyGen *rapid.Generator[string]
+	var extraKeyPool []string
+	if raw, ok := keyword(schema, "propertyNames"); ok {
+		propertyNames = subSchema(raw)
+		if len(propertyNames.Enum) > 0 {
+			for _, member := range propertyNames.Enum {
+				name, ok := member.(string)
+				_, declared := schema.Properties[name]
+				_, required := requiredExtras[name]
+				if ok && !declared && !required && matchingPatterns(patternProps, name) == nil && !contains(extraKeyPool, name) {
+					extraKeyPool = append(extraKeyPool, name)
+				}
+			}
+			slices.Sort(extraKeyPool)
+			if isAllowedAdditionalProperties && minExtras > len(extraKeyPool) && len(patternProps) == 0 {
+				return genFail(fmt.Sprintf("minProperties is %d, but propertyNames only allows %d undeclared names",
+					schema.MinProps, len(extraKeyPool)))
+			}
+			maxExtras = min(maxExtras, len(extraKeyPool))
+			minExtras = min(minExtras, maxExtras)
 		} else {
-			optionalPropStrings = append(optionalPropStrings, propName)
+			names := *propertyNames
+			if names.Type == nil {
+				names.Type = getType("string")
+			}
+			extraKeyGen = rapid.Map(opts.childAt(nil, "propertyNames").GenFromSchema(&names), func(name json.RawMessage) string {
+				var key string
+				if err := json.Unmarshal(name, &key); err != nil {
+					panic(fmt.Sprintf("propertyNames generated a non-string key %s", name))
+				}
+				return key
+			})
 		}
 	}
 
+	var order []string
+	if opts.PropertyOrder {
+		order = declaredOrder(schema)
+	}
+
 	return rapid.Custom(func(t *rapid.T) json.RawMessage {
 		obj := make(map[string]json.RawMessage)
 		allProps := make(map[string]*openapi3.SchemaRef)
+		// keys generated for patternProperties, with every pattern they match
+		patternKeys := make(map[string][]patternProperty)
+
+		numExtras, numPatternKeys := 0, 0
+		if isAllowedAdditionalProperties && maxExtras > 0 {
+			numExtras = rapid.IntRange(minExtras, maxExtras).Draw(t, "numExtras")
+			var pooledKeys []string
+			if extraKeyPool != nil {
+				pooledKeys = rapid.SliceOfNDistinct(rapid.SampledFrom(extraKeyPool), numExtras, numExtras,
+					func(s string) string { return s }).Draw(t, "addKeys")
+			}
+			// keys drawn twice, named like declared or required properties, or governed
+			// by patternProperties are drawn again, so minProperties is reached
+			redraws := opts.retryLimit(patternValueAttempts)
+			extras := 0
+			for i := 0; extras < numExtras && i < numExtras+redraws; i++ {
+				var extraKey string
+				switch {
+				case pooledKeys != nil:
+					// distinct, and neither declared nor governed by patternProperties
+					extraKey = pooledKeys[i]
+				case extraKeyGen != nil:
+					extraKey = extraKeyGen.Draw(t, fmt.Sprintf("addKey-%d", i))
+				default:
+					extraKey = rapid.StringN(20, 30, -1).Draw(t, fmt.Sprintf("addKey-%d", i))
+				}
+				_, drawn := allProps[extraKey]
+				_, declared := schema.Properties[extraKey]
+				_, required := requiredExtras[extraKey]
+				if drawn || declared || required || matchingPatterns(patternProps, extraKey) != nil {
+					continue
+				}
+				allProps[extraKey] = schema.AdditionalProperties.Schema
+				extras++
+			}
+			if extras < minExtras {
+				panic(retryError(fmt.Sprintf("%d distinct undeclared property names for minProperties %d", minExtras, schema.MinProps), numExtras+redraws))
+			}
+			numExtras = extras
+		}
+
+		maxPatternKeys := maxExtras
+		if budget >= 0 {
+			maxPatternKeys = min(maxPatternKeys, budget-minOptional-numExtras)
+		}
+		if len(patternProps) > 0 && maxPatternKeys > 0 {
+			minPatternKeys := 0
+			if !isAllowedAdditionalProperties {
+				minPatternKeys = minExtras
+			}
+			numPatternKeys = rapid.IntRange(minPatternKeys, maxPatternKeys).Draw(t, "numPatternKeys")
+			// keys drawn twice, or named like declared properties, are drawn again,
+			// so minProperties is reached
+			redraws := opts.retryLimit(patternValueAttempts)
+			for i := 0; len(patternKeys) < numPatternKeys && i < numPatternKeys+redraws; i++ {
+				pp := rapid.SampledFrom(patternProps).Draw(t, fmt.Sprintf("patternProperty-%d", i))
+				key := opts.drawPattern(pp.pattern, "", 0, -1, t)
+				_, drawn := patternKeys[key]
+				_, declared := schema.Properties[key]
+				if drawn || declared || propertyNames != nil && propertyNames.VisitJSON(key) != nil {
+					continue
+				}
+				matched := []patternProperty{pp}
+				for _, other := range matchingPatterns(patternProps, key) {
+					if other.pattern != pp.pattern {
+						matched = append(matched, other)
+					}
+				}
+				delete(allProps, key)
+				patternKeys[key] = matched
+			}
+		}
 
-		// Add additional properties
-		// additionalProperties: false → NOT allowed
-		// additionalProperties: true → allowed (any type)
-		// additionalProperties: { schema } → allowed (with schema)
-		// not specified + no properties → allowed (free-form object)
-		// not specified + has properties → NOT allowed
-		
-		isAllowedAdditionalProperties := false
-		
-		if schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has {
-			isAllowedAdditionalProperties = true
-		} else if schema.AdditionalProperties.Has != nil && !*schema.AdditionalProperties.Has {
-			isAllowedAdditionalProperties = false
-		} else if schema.AdditionalProperties.Schema != nil {
-			isAllowedAdditionalProperties = true
-		} else if len(schema.Properties) == 0 {
-			isAllowedAdditionalProperties = true
-		}
-
-		if isAllowedAdditionalProperties {
-			numExtras := rapid.IntRange(0, opts.AdditionalPropertiesMax).Draw(t, "numExtras") // limit to 5 for performance
-			for i := 0; i < numExtras; i++ {
-				// even though the later code will replace if the key is already in the map, do note that the extraKey could be an allowed property
-				extraKey := rapid.StringN(20, 30, -1).Draw(t, fmt.Sprintf("addKey-%d", i))
-				extraSchema := schema.AdditionalProperties.Schema
-				allProps[extraKey] = extraSchema
-			}
-		}
-
-		// Add or override optional properties
-		if len(optionalPropStrings) > 0 {
+		// Add or override optional properties, within what maxProperties leaves
+		maxOptional := maxOptional
+		if budget >= 0 {
+			maxOptional = min(maxOptional, budget-numExtras-numPatternKeys)
+		}
+		if maxOptional > 0 {
 			optionalPropsGen := rapid.SliceOfNDistinct(
 				rapid.SampledFrom(optionalPropStrings),
-				0, len(optionalPropStrings),
+				minOptional, maxOptional,
 				func(s string) string { return s },
 			)
 			optionalSampledKeys := optionalPropsGen.Draw(t, "optionalSampledKeys")
+			opts.trace("optionalSampledKeys", optionalSampledKeys)
 
 			for _, propName := range optionalSampledKeys {
 				prop := schema.Properties[propName]
@@ -380,31 +2091,342 @@ func (opts *GenerationOptions) genObject(schema *openapi3.Schema) *rapid.Generat
 			prop := schema.Properties[propName]
 			allProps[propName] = prop
 		}
+		for propName, prop := range requiredExtras {
+			allProps[propName] = prop
+		}
+		for propName, matched := range requiredPatternKeys {
+			delete(allProps, propName)
+			patternKeys[propName] = matched
+		}
+
+		for propName := range allProps {
+			// declared properties take precedence over generated pattern keys
+			delete(patternKeys, propName)
+		}
+
+		present := func(name string) bool {
+			_, declared := allProps[name]
+			_, isPatternKey := patternKeys[name]
+			return declared || isPatternKey
+		}
+
+		// present properties apply their dependentSchemas, whose required properties
+		// may in turn apply theirs
+		applied := make(map[string]bool)
+		for changed := true; changed && !mergePatch; {
+			changed = false
+			for _, trigger := range sortedKeys(dependents) {
+				if applied[trigger] || !present(trigger) {
+					continue
+				}
+				applied[trigger], changed = true, true
+				dependent := dependents[trigger]
+				for _, name := range dependent.Required {
+					if present(name) {
+						continue
+					}
+					if prop, ok := schema.Properties[name]; ok {
+						allProps[name] = prop
+					} else if matched := matchingPatterns(patternProps, name); matched != nil {
+						patternKeys[name] = matched
+					} else if prop, ok := dependent.Properties[name]; ok {
+						allProps[name] = prop
+					} else {
+						allProps[name] = schema.AdditionalProperties.Schema
+					}
+				}
+			}
+		}
+
+		// the properties dependentSchemas require are not part of the budget, so
+		// properties that nothing requires are left out again for maxProperties
+		if schema.MaxProps != nil {
+			for len(allProps)+len(patternKeys) > int(*schema.MaxProps) {
+				needed := make(map[string]bool)
+				for _, name := range requiredPropsStrings {
+					needed[name] = true
+				}
+				for name := range requiredExtras {
+					needed[name] = true
+				}
+				for name := range requiredPatternKeys {
+					needed[name] = true
+				}
+				for _, trigger := range sortedKeys(applied) {
+					if present(trigger) {
+						for _, name := range dependents[trigger].Required {
+							needed[name] = true
+						}
+					}
+				}
+				var unneeded []string
+				for _, name := range sortedKeys(allProps) {
+					if !needed[name] {
+						unneeded = append(unneeded, name)
+					}
+				}
+				for _, name := range sortedKeys(patternKeys) {
+					if !needed[name] {
+						unneeded = append(unneeded, name)
+					}
+				}
+				if len(unneeded) == 0 {
+					panic(fmt.Sprintf("the properties required with dependentSchemas exceed maxProperties %d at '%s'", *schema.MaxProps, opts.pointer))
+				}
+				leftOut := rapid.SampledFrom(unneeded).Draw(t, "leftOut")
+				delete(allProps, leftOut)
+				delete(patternKeys, leftOut)
+			}
+		}
+
+		// Properties of the dependent schemas of present properties refine the
+		// schema of the property they name
+		refinements := make(map[string][]*openapi3.Schema)
+		for _, trigger := range sortedKeys(applied) {
+			if !present(trigger) {
+				continue
+			}
+			dependent := dependents[trigger]
+			for _, name := range sortedKeys(dependent.Properties) {
+				if prop := dependent.Properties[name]; prop != nil && prop.Value != nil && prop != allProps[name] {
+					refinements[name] = append(refinements[name], prop.Value)
+				}
+			}
+		}
+
+		for _, key := range sortedKeys(patternKeys) {
+			obj[key] = opts.drawAt(opts.genPatternValue(patternKeys[key], refinements[key]...), t, "patternProp-"+key, key)
+		}
 
-		if len(allProps) == 0 {
+		if len(allProps) == 0 && len(obj) == 0 {
 			// When there are no properties, we still have to tell rapid that that is so
 			return rapid.Just([]byte("{}")).Draw(t, "No props")
 		}
 
-		for propName, prop := range allProps {
-			childOpts := &GenerationOptions{
-				depth:                   opts.depth + 1,
-				MaxDepth:                opts.MaxDepth,
-				AdditionalPropertiesMax: opts.AdditionalPropertiesMax,
-				PatternFunc:             opts.PatternFunc,
+		for _, propName := range sortedKeys(allProps) {
+			prop := allProps[propName]
+			childOpts := opts.childAt(prop, "additionalProperties")
+			if _, declared := schema.Properties[propName]; declared {
+				childOpts = opts.childAt(prop, "properties", propName)
+			}
+			opts.trace("prop-"+propName, "")
+			if mergePatch && rapid.Bool().Draw(t, "delete-"+propName) {
+				obj[propName] = json.RawMessage("null")
+				continue
+			}
+			if prop == nil && opts.ScalarAdditionalValues {
+				// free-form additional property
+				obj[propName] = opts.drawAt(childOpts.genScalar(), t, "prop-"+propName, propName)
+				continue
 			}
 			var propSchema *openapi3.Schema
 			if prop != nil {
 				propSchema = prop.Value
 			}
-			generatedValue := childOpts.GenFromSchema(propSchema).Draw(t, "prop-"+propName)
+			propGen := childOpts.GenFromSchema(propSchema)
+			if sentinels[propName] {
+				propGen = childOpts.genSentinel(propSchema)
+			}
+			// a declared property must satisfy the patternProperties its name matches,
+			// and any property the dependentSchemas that refine it
+			if also := append(patternSchemas(declaredPatternKeys[propName]), refinements[propName]...); len(also) > 0 {
+				if merged, ok := mergeObjectSchemas(propSchema, also); ok && !sentinels[propName] {
+					propGen = childOpts.GenFromSchema(merged)
+				}
+				propGen = opts.retryValidAgainst(propGen, also, fmt.Sprintf("the schemas of property '%s', the patternProperties it matches and its dependentSchemas", propName))
+			}
+			generatedValue := opts.drawAt(propGen, t, "prop-"+propName, propName)
 			obj[propName] = generatedValue
 		}
 
+		if order != nil {
+			return marshalOrdered(obj, order)
+		}
 		return marshal(obj)
 	})
 }
 
+// genSentinel generates the placeholder of a required readOnly property: the
+// example or default of schema if it is valid, else a generated value
+func (opts *GenerationOptions) genSentinel(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	if schema != nil {
+		for _, candidate := range []any{schema.Example, schema.Default} {
+			if candidate != nil && satisfies(schema, marshal(candidate)) {
+				return rapid.Just(marshal(candidate))
+			}
+		}
+	}
+	return opts.GenFromSchema(schema)
+}
+
+// patternProperty is one entry of the patternProperties keyword
+type patternProperty struct {
+	pattern string
+	// re is nil when the pattern is not valid RE2, keys are then only
+	// known to match the pattern they were generated from
+	re     *regexp.Regexp
+	schema *openapi3.Schema
+}
+
+func (pp patternProperty) matches(key string) bool {
+	return pp.re != nil && pp.re.MatchString(key)
+}
+
+// patternProperties returns the patternProperties of schema, ordered by pattern
+func patternProperties(schema *openapi3.Schema) []patternProperty {
+	raw, ok := keyword(schema, "patternProperties")
+	if !ok {
+		return nil
+	}
+	byPattern, ok := raw.(map[string]any)
+	if !ok {
+		panic(fmt.Sprintf("patternProperties must be an object, got %v", raw))
+	}
+
+	var props []patternProperty
+	for _, pattern := range sortedKeys(byPattern) {
+		re, _ := regexp.Compile(pattern)
+		props = append(props, patternProperty{pattern: pattern, re: re, schema: subSchema(byPattern[pattern])})
+	}
+	return props
+}
+
+// genPatternValue generates a value for a key matching several patternProperties,
+// refined by dependentSchemas. It is generated from the first schema, merged with the
+// others if they are object schemas, and must also be valid against the others.
+func (opts *GenerationOptions) genPatternValue(matched []patternProperty, refined ...*openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	childOpts := opts.childAt(nil, "patternProperties", matched[0].pattern)
+	also := append(patternSchemas(matched[1:]), refined...)
+	if len(also) == 0 {
+		return childOpts.GenFromSchema(matched[0].schema)
+	}
+	schema := matched[0].schema
+	if merged, ok := mergeObjectSchemas(schema, also); ok {
+		schema = merged
+	}
+	gen := childOpts.GenFromSchema(schema)
+	return opts.retryValidAgainst(gen, also, fmt.Sprintf("the schemas of patternProperties %q and the dependentSchemas of the key", matched[0].pattern))
+}
+
+// mergeObjectSchemas merges schema with the object schemas also using mergeSchema,
+// so values are generated from the merge rather than redrawn until they satisfy
+// every schema. It reports false if a schema is not an object schema or a property
+// is declared twice; the merge leaves out other keywords of also.
+func mergeObjectSchemas(schema *openapi3.Schema, also []*openapi3.Schema) (merged *openapi3.Schema, ok bool) {
+	for _, s := range append([]*openapi3.Schema{schema}, also...) {
+		if s == nil || s.Type == nil || !s.Type.Is("object") {
+			return nil, false
+		}
+	}
+	defer func() {
+		if recover() != nil {
+			merged, ok = nil, false
+		}
+	}()
+	result := *schema
+	// mergeSchema adds the properties of also to the map
+	result.Properties = maps.Clone(schema.Properties)
+	for _, s := range also {
+		result = mergeSchema(result, &openapi3.SchemaRef{Value: s})
+	}
+	// mergeSchema collects required from a map
+	slices.Sort(result.Required)
+	return &result, true
+}
+
+// retryValidAgainst redraws values of gen until one is valid against schemas as
+// well. what names the schemas in the error of failed draws.
+func (opts *GenerationOptions) retryValidAgainst(gen *rapid.Generator[json.RawMessage], schemas []*openapi3.Schema, what string) *rapid.Generator[json.RawMessage] {
+	attempts := opts.retryLimit(patternValueAttempts)
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		for attempt := 0; attempt < attempts; attempt++ {
+			opts.resetMeta()
+			v := gen.Draw(t, "patternValue")
+			if validAgainstAll(schemas, v) {
+				return v
+			}
+			opts.checkTimeout()
+		}
+		panic(retryError(what, attempts))
+	})
+}
+
+// patternValueAttempts is how often a property value that must satisfy several
+// schemas is drawn, unless RetryLimit is set: for keys matching several
+// patternProperties, declared properties matching patternProperties, and
+// properties refined by dependentSchemas
+const patternValueAttempts = 50
+
+func validAgainstAll(schemas []*openapi3.Schema, v json.RawMessage) bool {
+	var value any
+	if err := json.Unmarshal(v, &value); err != nil {
+		return false
+	}
+	for _, schema := range schemas {
+		if schema.VisitJSON(value) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// patternSchemas returns the schemas of patternProps
+func patternSchemas(patternProps []patternProperty) []*openapi3.Schema {
+	schemas := make([]*openapi3.Schema, len(patternProps))
+	for i, pp := range patternProps {
+		schemas[i] = pp.schema
+	}
+	return schemas
+}
+
+// dependentSchemas returns the subschemas of schema's dependentSchemas by the
+// property that triggers them, with dependentRequired added as subschemas that
+// only require properties
+func dependentSchemas(schema *openapi3.Schema) map[string]*openapi3.Schema {
+	dependents := make(map[string]*openapi3.Schema)
+	if raw, ok := keyword(schema, "dependentSchemas"); ok {
+		byProperty, ok := raw.(map[string]any)
+		if !ok {
+			panic(fmt.Sprintf("dependentSchemas must be an object, got %v", raw))
+		}
+		for name, sub := range byProperty {
+			dependents[name] = subSchema(sub)
+		}
+	}
+	if raw, ok := keyword(schema, "dependentRequired"); ok {
+		byProperty, ok := raw.(map[string]any)
+		if !ok {
+			panic(fmt.Sprintf("dependentRequired must be an object, got %v", raw))
+		}
+		for name, rawNames := range byProperty {
+			dependent := &openapi3.Schema{}
+			if existing := dependents[name]; existing != nil {
+				copied := *existing
+				dependent = &copied
+			}
+			names, _ := rawNames.([]any)
+			for _, required := range names {
+				if s, ok := required.(string); ok {
+					dependent.Required = append(slices.Clip(dependent.Required), s)
+				}
+			}
+			dependents[name] = dependent
+		}
+	}
+	return dependents
+}
+
+// matchingPatterns returns the patternProperties whose pattern matches key
+func matchingPatterns(patternProps []patternProperty, key string) []patternProperty {
+	var matched []patternProperty
+	for _, pp := range patternProps {
+		if pp.matches(key) {
+			matched = append(matched, pp)
+		}
+	}
+	return matched
+}
+
 func contains(list []string, s string) bool {
 	for _, v := range list {
 		if v == s {
@@ -419,9 +2441,9 @@ func contains(list []string, s string) bool {
 func (opts *GenerationOptions) genAny() *rapid.Generator[json.RawMessage] {
 	return rapid.Custom(func(t *rapid.T) json.RawMessage {
 
-		// Check depth limit to prevent infinite recursion
+		// Check depth limit to prevent infinite recursion: scalars do not nest
 		if opts.depth >= opts.MaxDepth {
-			return opts.genString(&openapi3.Schema{Type: getType("string")}).Draw(t, "Any-MaxDepth-string")
+			return opts.genScalar().Draw(t, "Any-MaxDepth-scalar")
 		}
 
 		return rapid.OneOf(
@@ -436,18 +2458,118 @@ func (opts *GenerationOptions) genAny() *rapid.Generator[json.RawMessage] {
 	})
 }
 
-// ---------------- Compositions ----------------
+// genScalar generates any non-container value
+func (opts *GenerationOptions) genScalar() *rapid.Generator[json.RawMessage] {
+	return rapid.OneOf(
+		opts.genString(&openapi3.Schema{Type: getType("string")}),
+		opts.genInteger(&openapi3.Schema{Type: getType("integer")}),
+		opts.genNumber(&openapi3.Schema{Type: getType("number")}),
+		opts.genBoolean(&openapi3.Schema{Type: getType("boolean")}),
+		genNull(),
+	)
+}
+
+// ---------------- Compositions ----------------
+
+func (opts *GenerationOptions) handleAllOf(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	return rapid.Custom(func(t *rapid.T) json.RawMessage {
+		merged := opts.allOfMerges.get(schema)
+		mergedSchema := merged.schema
+		choiceIndices := merged.choiceIndices
+
+		if len(choiceIndices) == 0 {
+			return opts.genObject(mergedSchema).Draw(t, "Object-Value")
+		}
+
+		obj := make(map[string]json.RawMessage)
+		if merged.hasObjectParts {
+			if err := json.Unmarshal(opts.genObject(mergedSchema).Draw(t, "Object-Value"), &obj); err != nil {
+				panic(err)
+			}
+		}
+		for _, i := range choiceIndices {
+			sub := schema.AllOf[i]
+			val := opts.childAt(sub, "allOf", strconv.Itoa(i)).GenFromSchema(sub.Value).Draw(t, fmt.Sprintf("allOf-%d", i))
+			var branch map[string]json.RawMessage
+			if err := json.Unmarshal(val, &branch); err != nil || branch == nil {
+				panic(fmt.Sprintf("allOf combines objects with a oneOf/anyOf that generated a non-object: %s", val))
+			}
+			// the branch is more specific than the base, so its values win
+			for k, v := range branch {
+				obj[k] = v
+			}
+		}
+		if opts.PropertyOrder {
+			return marshalOrdered(obj, declaredOrder(mergedSchema))
+		}
+		return marshal(obj)
+	})
+}
+
+// unevaluatedPropertiesFalse reports whether schema has unevaluatedProperties: false,
+// which closes the object to the properties its subschemas declare. An
+// additionalProperties evaluates every property, so it leaves nothing unevaluated.
+func unevaluatedPropertiesFalse(schema *openapi3.Schema) bool {
+	raw, ok := keyword(schema, "unevaluatedProperties")
+	return ok && isFalseSchema(subSchema(raw)) &&
+		schema.AdditionalProperties.Has == nil && schema.AdditionalProperties.Schema == nil
+}
+
+// mergedAllOf is the merge of the subschemas of an allOf
+type mergedAllOf struct {
+	schema         *openapi3.Schema
+	hasObjectParts bool
+	// choiceIndices are the oneOf/anyOf subschemas, which cannot be merged. They are
+	// generated on their own and their properties added to the merged object.
+	choiceIndices []int
+}
+
+// allOfMerges caches the merged allOf schemas, so large allOf chains are merged
+// once rather than on every draw
+type allOfMerges struct {
+	mu     sync.Mutex
+	merged map[*openapi3.Schema]*mergedAllOf
+}
 
-func (opts *GenerationOptions) handleAllOf(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		var mergedSchema openapi3.Schema
+// get returns the merge of schema's allOf. Options built without
+// NewGenerationOptions have no cache and merge every time.
+func (m *allOfMerges) get(schema *openapi3.Schema) *mergedAllOf {
+	if m != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if merged, ok := m.merged[schema]; ok {
+			return merged
+		}
+	}
 
-		for _, sub := range schema.AllOf {
-			mergedSchema = mergeSchema(mergedSchema, sub)
+	var mergedSchema openapi3.Schema
+	merged := &mergedAllOf{}
+	for i, sub := range schema.AllOf {
+		if sub != nil && sub.Value != nil && (len(sub.Value.OneOf) > 0 || len(sub.Value.AnyOf) > 0) {
+			merged.choiceIndices = append(merged.choiceIndices, i)
+			continue
 		}
+		mergedSchema = mergeSchema(mergedSchema, sub)
+		merged.hasObjectParts = true
+	}
+	if unevaluatedPropertiesFalse(schema) && mergedSchema.AdditionalProperties.Has == nil && mergedSchema.AdditionalProperties.Schema == nil {
+		// the allOf subschemas evaluate their properties, and nothing else
+		closed := false
+		mergedSchema.AdditionalProperties.Has = &closed
+	}
+	merged.schema = &mergedSchema
 
-		return opts.genObject(&mergedSchema).Draw(t, "Object-Value")
-	})
+	if m != nil {
+		m.merged[schema] = merged
+	}
+	return merged
+}
+
+// reset forgets all merged schemas
+func (m *allOfMerges) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.merged = make(map[*openapi3.Schema]*mergedAllOf)
 }
 
 func mergeSchema(schema openapi3.Schema, sub *openapi3.SchemaRef) openapi3.Schema {
@@ -545,129 +2667,393 @@ func mergeSchema(schema openapi3.Schema, sub *openapi3.SchemaRef) openapi3.Schem
 	return schema
 }
 
+// anyOfMergeAttempts is how often merging several anyOf branches is tried before
+// generating a single branch instead, unless RetryLimit is set
+const anyOfMergeAttempts = 5
+
 func (opts *GenerationOptions) handleAnyOf(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
 	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		// anyOf means the data must be valid against AT LEAST ONE schema (can be more than one)
-		// We'll pick a random non-empty subset of schemas and try to merge them
-
-		numSchemas := len(schema.AnyOf)
-		// Pick how many schemas to satisfy (at least 1)
-		numToSatisfy := rapid.IntRange(1, numSchemas).Draw(t, "anyOf-count")
-
-		// Pick which schemas to satisfy
-		indices := make([]int, numSchemas)
-		for i := range indices {
-			indices[i] = i
-		}
-		selectedIndices := rapid.SliceOfNDistinct(
-			rapid.SampledFrom(indices),
-			numToSatisfy,
-			numToSatisfy,
-			func(i int) int { return i },
-		).Draw(t, "anyOf-indices")
-
-		// If only one schema selected, just generate from it
-		if len(selectedIndices) == 1 {
-			childOpts := &GenerationOptions{
-				depth:                   opts.depth + 1,
-				MaxDepth:                opts.MaxDepth,
-				AdditionalPropertiesMax: opts.AdditionalPropertiesMax,
-				PatternFunc:             opts.PatternFunc,
-			}
-			return childOpts.GenFromSchema(schema.AnyOf[selectedIndices[0]].Value).Draw(t, "anyOf-single")
-		}
-
-		// Multiple schemas selected - try to merge them like allOf
-		merged := make(map[string]json.RawMessage)
-		for _, idx := range selectedIndices {
-			childOpts := &GenerationOptions{
-				depth:                   opts.depth + 1,
-				MaxDepth:                opts.MaxDepth,
-				AdditionalPropertiesMax: opts.AdditionalPropertiesMax,
-				PatternFunc:             opts.PatternFunc,
-			}
-			val := childOpts.GenFromSchema(schema.AnyOf[idx].Value).Draw(t, fmt.Sprintf("anyOf-%d", idx))
-			var submap map[string]json.RawMessage
-			if err := json.Unmarshal(val, &submap); err == nil {
-				// It's an object, merge it
-				for k, v := range submap {
-					merged[k] = v
-				}
-			} else {
-				// Not an object (primitive type), just return this value
-				// (can't easily merge primitives)
+		// Merged objects of overlapping branches can satisfy none of them, e.g. when
+		// one branch overwrites a shared required property, so merges are validated
+		for attempt := 0; attempt < opts.retryLimit(anyOfMergeAttempts); attempt++ {
+			opts.resetMeta()
+			val, merged := opts.drawAnyOf(schema, t)
+			if !merged || validAgainst(schema, val) {
 				return val
 			}
 		}
-		return marshal(merged)
+		idx := rapid.IntRange(0, len(schema.AnyOf)-1).Draw(t, "anyOf-fallback")
+		opts.trace("anyOf-fallback", idx)
+		opts.resetMeta()
+		return opts.anyOfSingle(schema, idx).Draw(t, "anyOf-single")
 	})
 }
 
+// anyOfSingle generates values of branch idx of schema's anyOf on its own
+func (opts *GenerationOptions) anyOfSingle(schema *openapi3.Schema, idx int) *rapid.Generator[json.RawMessage] {
+	sub := schema.AnyOf[idx]
+	gen := opts.childAt(sub, "anyOf", strconv.Itoa(idx)).GenFromSchema(sub.Value)
+	return opts.recordingAfter(gen, func() { opts.recordAnyOf([]int{idx}) })
+}
+
+// drawAnyOf draws a value for a random non-empty selection of anyOf branches,
+// and reports whether it merged several branches
+func (opts *GenerationOptions) drawAnyOf(schema *openapi3.Schema, t *rapid.T) (json.RawMessage, bool) {
+	// anyOf means the data must be valid against AT LEAST ONE schema (can be more than one)
+	// We'll pick a random non-empty subset of schemas and try to merge them
+
+	numSchemas := len(schema.AnyOf)
+
+	// Pick how many schemas to satisfy (at least 1)
+	numToSatisfy := rapid.IntRange(1, numSchemas).Draw(t, "anyOf-count")
+
+	// Pick which schemas to satisfy
+	indices := make([]int, numSchemas)
+	for i := range indices {
+		indices[i] = i
+	}
+	selectedIndices := rapid.SliceOfNDistinct(
+		rapid.SampledFrom(indices),
+		numToSatisfy,
+		numToSatisfy,
+		func(i int) int { return i },
+	).Draw(t, "anyOf-indices")
+
+	// Only objects can be merged, so with any other branch in the selection, e.g.
+	// in unions of scalars, we satisfy a single branch instead
+	mergeable := true
+	for _, idx := range selectedIndices {
+		if !isObjectSchema(schema.AnyOf[idx].Value) {
+			mergeable = false
+			break
+		}
+	}
+
+	// If only one schema selected, just generate from it
+	if len(selectedIndices) == 1 || !mergeable {
+		opts.trace("anyOf-indices", selectedIndices[:1])
+		return opts.anyOfSingle(schema, selectedIndices[0]).Draw(t, "anyOf-single"), false
+	}
+	opts.trace("anyOf-indices", selectedIndices)
+
+	// Multiple schemas selected - try to merge them like allOf
+	merged := make(map[string]json.RawMessage)
+	for _, idx := range selectedIndices {
+		childOpts := opts.childAt(schema.AnyOf[idx], "anyOf", strconv.Itoa(idx))
+		val := childOpts.GenFromSchema(schema.AnyOf[idx].Value).Draw(t, fmt.Sprintf("anyOf-%d", idx))
+		var submap map[string]json.RawMessage
+		if err := json.Unmarshal(val, &submap); err == nil {
+			// It's an object, merge it
+			for k, v := range submap {
+				merged[k] = v
+			}
+		} else {
+			// Not an object (primitive type), just return this value
+			// (can't easily merge primitives)
+			return val, false
+		}
+	}
+	opts.recordAnyOf(selectedIndices)
+	return marshal(merged), true
+}
+
+// validAgainst reports whether value is valid against schema, ignoring keywords
+// the kin-openapi validator does not know about
+func validAgainst(schema *openapi3.Schema, value json.RawMessage) bool {
+	var decoded any
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return false
+	}
+	return schema.VisitJSON(decoded) == nil
+}
+
+// satisfies reports whether value is valid against schema, like validAgainst,
+// but also checks the const keyword, which the kin-openapi validator does not know
+func satisfies(schema *openapi3.Schema, value json.RawMessage) bool {
+	return constsHold(schema, value) && validAgainst(schema, value)
+}
+
+// constsHold reports whether value matches the const keywords of schema, and of
+// the properties, items and allOf subschemas nested in it
+func constsHold(schema *openapi3.Schema, value json.RawMessage) bool {
+	if schema == nil {
+		return true
+	}
+	if c, ok := keyword(schema, "const"); ok && canonicalJSON(marshal(c)) != canonicalJSON(value) {
+		return false
+	}
+	for _, sub := range schema.AllOf {
+		if sub != nil && !constsHold(sub.Value, value) {
+			return false
+		}
+	}
+	var obj map[string]json.RawMessage
+	if len(schema.Properties) > 0 && json.Unmarshal(value, &obj) == nil {
+		for name, prop := range schema.Properties {
+			if v, ok := obj[name]; ok && prop != nil && !constsHold(prop.Value, v) {
+				return false
+			}
+		}
+	}
+	var items []json.RawMessage
+	if schema.Items != nil && json.Unmarshal(value, &items) == nil {
+		for _, item := range items {
+			if !constsHold(schema.Items.Value, item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AssertOneOfExclusive checks that payload is valid against exactly one of the
+// oneOf branches of schema, each validated on its own, including const. It returns
+// an error naming the matching branches otherwise.
+func AssertOneOfExclusive(schema *openapi3.Schema, payload json.RawMessage) error {
+	if schema == nil || len(schema.OneOf) == 0 {
+		return fmt.Errorf("schema has no oneOf")
+	}
+	var matching []int
+	for i, sub := range schema.OneOf {
+		if sub != nil && sub.Value != nil && satisfies(sub.Value, payload) {
+			matching = append(matching, i)
+		}
+	}
+	if len(matching) != 1 {
+		return fmt.Errorf("payload must match exactly one oneOf branch, matches %d: %v", len(matching), matching)
+	}
+	return nil
+}
+
+// isObjectSchema reports whether every value generated from schema is a JSON object
+func isObjectSchema(schema *openapi3.Schema) bool {
+	if schema == nil {
+		return false
+	}
+	if len(schema.AllOf) > 0 {
+		// allOf is always generated as a merged object
+		return true
+	}
+	return schema.Type != nil && schema.Type.Is("object") && !isNullable(schema)
+}
+
 func (opts *GenerationOptions) handleOneOf(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
 	return rapid.Custom(func(t *rapid.T) json.RawMessage {
 		// choose exactly one branch
-		var gens []*rapid.Generator[json.RawMessage]
-		for _, sub := range schema.OneOf {
-			// Increase depth for recursive calls
-			childOpts := &GenerationOptions{
-				depth:                   opts.depth + 1,
-				MaxDepth:                opts.MaxDepth,
-				AdditionalPropertiesMax: opts.AdditionalPropertiesMax,
-				PatternFunc:             opts.PatternFunc,
-			}
-			gens = append(gens, childOpts.GenFromSchema(sub.Value))
-		}
-		return rapid.OneOf(gens...).Draw(t, "OneOf-Choice")
+		idx := rapid.IntRange(0, len(schema.OneOf)-1).Draw(t, "OneOf-Choice")
+		return opts.oneOfBranch(schema, idx).Draw(t, "OneOf-Value")
 	})
 }
 
-// ---------------- Main Dispatcher ----------------
+// oneOfBranch generates values of branch idx of schema's oneOf. If schema has a
+// discriminator, object values get the branch's discriminator value.
+func (opts *GenerationOptions) oneOfBranch(schema *openapi3.Schema, idx int) *rapid.Generator[json.RawMessage] {
+	opts.trace("OneOf-Choice", idx)
+	sub := schema.OneOf[idx]
+	// Increase depth for recursive calls
+	childOpts := opts.childAt(sub, "oneOf", strconv.Itoa(idx))
+	hasObjectSiblings := len(schema.Properties) > 0 || schema.Type != nil && schema.Type.Is("object")
+	var gen *rapid.Generator[json.RawMessage]
+	if hasObjectSiblings && sub != nil && sub.Value != nil {
+		branch := oneOfBranchObject(schema, idx)
+		gen = childOpts.GenFromSchema(&branch)
+	} else {
+		gen = childOpts.GenFromSchema(sub.Value)
+	}
 
-func (opts *GenerationOptions) GenFromSchema(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
-	return rapid.Custom(func(t *rapid.T) json.RawMessage {
-		//fmt.Printf("Generating schema for %v\n", opts.depth)
-		if schema == nil {
-			return opts.genAny().Draw(t, "any")
+	record := func() { opts.recordOneOf(idx) }
+	value, ok := discriminatorValue(schema, idx)
+	if !ok {
+		return opts.recordingAfter(gen, record)
+	}
+	name := schema.Discriminator.PropertyName
+	return opts.recordingAfter(rapid.Map(gen, func(v json.RawMessage) json.RawMessage {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(v, &obj); err != nil || obj == nil {
+			return v
 		}
-
-		// Compositions first
-		if len(schema.AllOf) > 0 {
-			return opts.handleAllOf(schema).Draw(t, "AllOf")
+		obj[name] = marshal(value)
+		if opts.PropertyOrder {
+			return marshalOrdered(obj, declaredOrder(sub.Value))
 		}
-		if len(schema.AnyOf) > 0 {
-			return opts.handleAnyOf(schema).Draw(t, "AnyOf")
+		return marshal(obj)
+	}), record)
+}
+
+// discriminatorValue returns the value of the discriminator property for branch idx
+// of schema's oneOf: its key in the discriminator mapping, or else the name of the
+// schema the branch references. Without a mapping, a branch that pins the property
+// with const or enum keeps its own value.
+func discriminatorValue(schema *openapi3.Schema, idx int) (string, bool) {
+	d := schema.Discriminator
+	sub := schema.OneOf[idx]
+	if d == nil || d.PropertyName == "" || sub == nil || sub.Ref == "" {
+		return "", false
+	}
+	for _, key := range sortedKeys(d.Mapping) {
+		target := d.Mapping[key]
+		if target == sub.Ref || "#/components/schemas/"+target == sub.Ref {
+			return key, true
 		}
-		if len(schema.OneOf) > 0 {
-			return opts.handleOneOf(schema).Draw(t, "OneOf")
+	}
+	if sub.Value != nil {
+		if prop := sub.Value.Properties[d.PropertyName]; prop != nil && prop.Value != nil &&
+			(len(prop.Value.Enum) > 0 || hasKeyword(prop.Value, "const")) {
+			return "", false
 		}
+	}
+	return sub.Ref[strings.LastIndex(sub.Ref, "/")+1:], true
+}
 
-		if schema.Type == nil {
-			return opts.genAny().Draw(t, "Any")
-		}
+// oneOfBranchObject combines an object schema with its oneOf branch idx, for branches
+// that only add constraints to the object, as in the "exactly one of a or b" idiom
+// oneOf: [{required: [a]}, {required: [b]}]. Properties required by other branches
+// but not by this one are left out, so the value matches no other branch.
+func oneOfBranchObject(schema *openapi3.Schema, idx int) openapi3.Schema {
+	branch := schema.OneOf[idx].Value
+	combined := *schema
+	combined.OneOf = nil
+	if combined.Type == nil {
+		combined.Type = getType("object")
+	}
+	combined.Required = append(slices.Clone(schema.Required), branch.Required...)
+
+	combined.Properties = make(openapi3.Schemas, len(schema.Properties)+len(branch.Properties))
+	for name, prop := range schema.Properties {
+		combined.Properties[name] = prop
+	}
+	// the branch is more specific than the object, so its properties win
+	for name, prop := range branch.Properties {
+		combined.Properties[name] = prop
+	}
 
-		if len(*schema.Type) > 1 {
-			panic("multiple types not supported in this implementation")
+	for i, other := range schema.OneOf {
+		if i == idx || other == nil || other.Value == nil {
+			continue
 		}
+		for _, name := range other.Value.Required {
+			if !contains(combined.Required, name) {
+				// optional properties with a false schema are never generated
+				combined.Properties[name] = &openapi3.SchemaRef{Value: subSchema(false)}
+			}
+		}
+	}
+	return combined
+}
+
+// ---------------- Main Dispatcher ----------------
+
+func (opts *GenerationOptions) GenFromSchema(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	// Dispatch happens once, when the generator is built. Generators for
+	// nested schemas are built while drawing, which keeps recursive schemas finite.
+	if gen, ok := opts.Overrides[opts.pointer]; ok {
+		return gen
+	}
+
+	if opts.Trace != nil && !opts.traced {
+		inner := *opts
+		inner.traced = true
+		gen := inner.GenFromSchema(schema)
+		return rapid.Custom(func(t *rapid.T) json.RawMessage {
+			fmt.Fprintln(opts.Trace, "draw:")
+			return gen.Draw(t, "traced")
+		})
+	}
+
+	if opts.GenerationTimeout > 0 && opts.deadline == nil {
+		// the nested generators are built for every draw, so draws running
+		// concurrently, e.g. in parallel tests, each have their own deadline
+		return rapid.Custom(func(t *rapid.T) json.RawMessage {
+			inner := *opts
+			inner.deadline = &drawDeadline{at: time.Now().Add(opts.GenerationTimeout)}
+			return inner.GenFromSchema(schema).Draw(t, "timed")
+		})
+	}
+	if opts.deadline != nil {
+		gen := opts.dispatch(schema)
+		return rapid.Custom(func(t *rapid.T) json.RawMessage {
+			opts.checkTimeout()
+			value := gen.Draw(t, "value")
+			// a single slow draw, e.g. of a PatternFunc, fails once it returns
+			opts.checkTimeout()
+			return value
+		})
+	}
+	return opts.dispatch(schema)
+}
+
+// dispatch builds the generator of schema by its value sources, compositions and type
+func (opts *GenerationOptions) dispatch(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	if schema == nil {
+		return opts.genAny()
+	}
+
+	if isFalseSchema(schema) {
+		return genFail("schema is false (not: {}), no value is valid against it")
+	}
+
+	// Value sources, in order of precedence: enum > const > format > pattern > plain
+	if enumGen := opts.genEnum(schema); enumGen != nil {
+		return enumGen
+	}
+
+	// const pins the value, wherever the schema appears
+	if c, ok := keyword(schema, "const"); ok {
+		return rapid.Just(marshal(c))
+	}
+
+	// Compositions first
+	if len(schema.AllOf) > 0 {
+		return opts.handleAllOf(schema)
+	}
+	if len(schema.AnyOf) > 0 {
+		return opts.handleAnyOf(schema)
+	}
+	if len(schema.OneOf) > 0 {
+		return opts.handleOneOf(schema)
+	}
 
-		// Direct type
-		typesSlice := []string(*schema.Type)
-		switch typesSlice[0] {
-		case "string":
-			return opts.genString(schema).Draw(t, "String")
-		case "integer":
-			return opts.genInteger(schema).Draw(t, "Integer")
-		case "number":
-			return opts.genNumber(schema).Draw(t, "Number")
-		case "boolean":
-			return opts.genBoolean(schema).Draw(t, "Boolean")
-		case "array":
-			return opts.genArray(schema).Draw(t, "Array")
-		case "object":
-			return opts.genObject(schema).Draw(t, "Object")
-		default:
-			return opts.genAny().Draw(t, "Any")
+	if schema.Type == nil {
+		// a format only strings may have, such as date-time, implies type string
+		if allowed := formatTypes[schema.Format]; len(allowed) == 1 && allowed[0] == "string" {
+			return opts.genString(schema)
 		}
-	})
+		return opts.genAny()
+	}
+
+	if len(*schema.Type) > 1 {
+		return genFail("multiple types not supported in this implementation")
+	}
+
+	// Direct type
+	typesSlice := []string(*schema.Type)
+	switch typesSlice[0] {
+	case "string":
+		return opts.genString(schema)
+	case "integer":
+		return opts.genInteger(schema)
+	case "number":
+		return opts.genNumber(schema)
+	case "boolean":
+		return opts.genBoolean(schema)
+	case "array":
+		return opts.genArray(schema)
+	case "object":
+		return opts.genObject(schema)
+	case "null":
+		// not an OpenAPI 3.0 type, but used in oneOf/anyOf for nullable values
+		return genNull()
+	default:
+		return opts.genAny()
+	}
+}
+
+// GenFromSchemaAt generates from a schema located at the given document JSON pointer,
+// so overrides for it and for the schemas nested in it apply
+func (opts *GenerationOptions) GenFromSchemaAt(pointer string, schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
+	located := *opts
+	located.pointer = pointer
+	return located.GenFromSchema(schema)
 }
 
 // NewGenerationOptions creates a new GenerationOptions instance with default values
@@ -677,9 +3063,27 @@ func NewGenerationOptions() *GenerationOptions {
 		MaxDepth:                10,
 		AdditionalPropertiesMax: 10,
 		PatternFunc:             nil,
+		UUIDVersion:             4,
+		allOfMerges:             &allOfMerges{merged: make(map[*openapi3.Schema]*mergedAllOf)},
 	}
 }
 
+// WithSeedFromString sets Seed to the hash of a memorable string such as
+// "bug-1234", so a failure found with Check is reproduced by sharing the string
+func (opts *GenerationOptions) WithSeedFromString(s string) *GenerationOptions {
+	opts.Seed = SeedFromString(s)
+	return opts
+}
+
+// WithPatternRepeatCap bounds the unbounded quantifiers of patterns, such as .* and
+// a+, to at most n repetitions, or maxLength if that is smaller, so that pattern
+// strings stay small. The cap is raised to minLength, so that long enough strings
+// still match. The PatternFunc receives the rewritten pattern.
+func (opts *GenerationOptions) WithPatternRepeatCap(n int) *GenerationOptions {
+	opts.PatternRepeatCap = n
+	return opts
+}
+
 // WithPatternFunc sets a custom pattern generator function.
 // The pattern function will be called for any schema that has a pattern constraint.
 func (opts *GenerationOptions) WithPatternFunc(f PatternFunc) *GenerationOptions {
@@ -687,59 +3091,458 @@ func (opts *GenerationOptions) WithPatternFunc(f PatternFunc) *GenerationOptions
 	return opts
 }
 
-func ReadSpec(specPath string) (*openapi3.T, error) {
+// WithMode sets whether readOnly (ModeRequest) or writeOnly (ModeResponse) properties are left out.
+// The mode applies at every nesting level.
+func (opts *GenerationOptions) WithMode(mode GenerationMode) *GenerationOptions {
+	opts.Mode = mode
+	return opts
+}
+
+// WithOnlyRequiredDepth makes objects from depth d on only get their required
+// properties, and just enough others for minProperties, keeping deeply nested
+// payloads compact. The root is at depth 0, its properties and items at depth 1.
+func (opts *GenerationOptions) WithOnlyRequiredDepth(d int) *GenerationOptions {
+	opts.OnlyRequiredDepth = &d
+	return opts
+}
+
+// WithReadOnlyRequiredPolicy sets whether ModeRequest leaves out readOnly properties
+// that are also required (ReadOnlyRequiredOmit), or generates a placeholder for them
+// (ReadOnlyRequiredSentinel): their example or default, else a generated value.
+func (opts *GenerationOptions) WithReadOnlyRequiredPolicy(policy ReadOnlyRequiredPolicy) *GenerationOptions {
+	opts.ReadOnlyRequired = policy
+	return opts
+}
+
+// WithOverride makes gen generate the schema found at the document JSON pointer,
+// e.g. /components/schemas/User/properties/email. Pointers are followed through local
+// $refs; use GenFromSchemaAt for schemas that are not reached through a $ref.
+func (opts *GenerationOptions) WithOverride(pointer string, gen *rapid.Generator[json.RawMessage]) *GenerationOptions {
+	if opts.Overrides == nil {
+		opts.Overrides = make(map[string]*rapid.Generator[json.RawMessage])
+	}
+	opts.Overrides[pointer] = gen
+	return opts
+}
+
+// WithEnumCoverage makes enum generation prefer members that were not generated yet,
+// so every member of every enum shows up after a few draws instead of eventually.
+// Which members were seen is remembered across draws, so draws depend on earlier
+// draws and rapid may not reproduce or shrink failures exactly.
+func (opts *GenerationOptions) WithEnumCoverage(enabled bool) *GenerationOptions {
+	opts.enumCoverage = nil
+	if enabled {
+		opts.enumCoverage = &enumCoverage{seen: make(map[*openapi3.Schema]map[string]bool)}
+	}
+	return opts
+}
+
+// WithUUIDVersion sets the version of generated uuids: 4 (random) or 7 (time-ordered).
+// A schema can override it with the x-uuid-version extension.
+func (opts *GenerationOptions) WithUUIDVersion(version int) *GenerationOptions {
+	opts.UUIDVersion = version
+	return opts
+}
+
+// WithUppercaseUUIDs generates uuids with uppercase hex digits
+func (opts *GenerationOptions) WithUppercaseUUIDs(enabled bool) *GenerationOptions {
+	opts.UUIDUppercase = enabled
+	return opts
+}
+
+// WithMapMinEntries makes map objects, with additionalProperties but no declared
+// properties, get at least n entries, so the values of maps are exercised too.
+// Past MaxDepth maps are left empty again, unless minProperties asks otherwise.
+func (opts *GenerationOptions) WithMapMinEntries(n int) *GenerationOptions {
+	opts.MapMinEntries = n
+	return opts
+}
+
+// WithFixedArrayLength makes every generated array exactly n items long, e.g. for
+// deterministic fixtures. Arrays whose minItems and maxItems do not allow n fail to
+// generate. Past MaxDepth arrays get minItems items, as without a fixed length.
+func (opts *GenerationOptions) WithFixedArrayLength(n int) *GenerationOptions {
+	opts.FixedArrayLength = &n
+	return opts
+}
+
+// WithScalarAdditionalValues restricts values of free-form additional properties
+// (additionalProperties true or unset) to strings, numbers, booleans and null.
+// This keeps untyped extras shallow and fast to generate.
+func (opts *GenerationOptions) WithScalarAdditionalValues(enabled bool) *GenerationOptions {
+	opts.ScalarAdditionalValues = enabled
+	return opts
+}
+
+// WithLengthSemantics sets the unit minLength and maxLength of plain strings are
+// measured in. LengthUTF16 keeps strings within limits as JavaScript's String.length
+// measures them, e.g. for Node servers; LengthBytes as the size of their UTF-8
+// encoding. Lengths of pattern and format values are left to the PatternFunc and
+// the format.
+func (opts *GenerationOptions) WithLengthSemantics(semantics LengthSemantics) *GenerationOptions {
+	opts.LengthSemantics = semantics
+	return opts
+}
+
+// WithByteLengthSemantics sets what minLength and maxLength of format byte strings
+// bound: the length of the base64 text (ByteLengthEncoded, the default) or the
+// number of bytes it decodes to (ByteLengthDecoded), as validators disagree on it
+func (opts *GenerationOptions) WithByteLengthSemantics(semantics ByteLengthSemantics) *GenerationOptions {
+	opts.ByteLengthSemantics = semantics
+	return opts
+}
+
+// WithStringAlphabet limits strings without a pattern or a generated format to the
+// characters in runes, e.g. printable ASCII. An empty alphabet allows any character.
+func (opts *GenerationOptions) WithStringAlphabet(runes string) *GenerationOptions {
+	opts.StringAlphabet = runes
+	return opts
+}
+
+// WithTrace logs the decisions taken in every draw to w: which oneOf/anyOf branches,
+// which optional properties and how strings were generated, indented by depth.
+// Labels match the rapid draw labels.
+func (opts *GenerationOptions) WithTrace(w io.Writer) *GenerationOptions {
+	opts.Trace = w
+	return opts
+}
+
+// WithGenerationTimeout aborts every draw that takes longer than d, e.g. on huge
+// patterns or deeply recursive schemas, so that it fails with an error instead of
+// stalling the run. The deadline is checked around each nested schema and in the
+// retry loops; a single slow PatternFunc call is not interrupted, but its draw fails
+// once it returns. Every draw has a deadline of its own, also when the generator is
+// drawn from parallel tests.
+func (opts *GenerationOptions) WithGenerationTimeout(d time.Duration) *GenerationOptions {
+	opts.GenerationTimeout = d
+	return opts
+}
+
+// WithBigIntegers makes integer schemas without bounds, multipleOf or an int32/int64
+// format sometimes generate integers beyond the int64 range, as plain JSON numbers.
+func (opts *GenerationOptions) WithBigIntegers(enabled bool) *GenerationOptions {
+	opts.BigIntegers = enabled
+	return opts
+}
+
+// Reset clears the state the options keep across draws, such as the enum members
+// already generated with WithEnumCoverage and the merged allOf schemas. Call it before reusing the options for
+// another spec, so nothing from the previous spec carries over. Generators built
+// before Reset share the cleared state.
+func (opts *GenerationOptions) Reset() {
+	if opts.enumCoverage != nil {
+		opts.enumCoverage.reset()
+	}
+	if opts.warnedFormats != nil {
+		opts.warnedFormats.reset()
+	}
+	if opts.allOfMerges != nil {
+		opts.allOfMerges.reset()
+	}
+}
+
+// WithFormatExampleBias makes string schemas with a format and an example, such
+// as a sentinel uuid the server recognizes, sometimes generate that exact example.
+// Examples that are not valid against the schema are never generated.
+func (opts *GenerationOptions) WithFormatExampleBias(enabled bool) *GenerationOptions {
+	opts.FormatExampleBias = enabled
+	return opts
+}
+
+// WithPrecisionStress makes number schemas without multipleOf sometimes generate
+// values where float64, float32 and decimal parsers disagree, such as
+// 0.30000000000000004, 17 significant digits and integers around 2^53, as long
+// as they lie within the schema's bounds.
+func (opts *GenerationOptions) WithPrecisionStress(enabled bool) *GenerationOptions {
+	opts.PrecisionStress = enabled
+	return opts
+}
+
+// WithNumberFormat sets how generated numbers are written. NumberFormatDecimal avoids
+// the exponent notation some servers reject, at the cost of long numbers for very
+// large and very small magnitudes.
+func (opts *GenerationOptions) WithNumberFormat(format NumberFormat) *GenerationOptions {
+	opts.NumberFormat = format
+	return opts
+}
+
+// WithUnknownFormatPolicy sets how string formats SpecSmash has no generator for
+// are handled: ignored (the default), logged once per format, or a generation error
+func (opts *GenerationOptions) WithUnknownFormatPolicy(policy UnknownFormatPolicy) *GenerationOptions {
+	opts.UnknownFormatPolicy = policy
+	if policy == UnknownFormatWarn && opts.warnedFormats == nil {
+		opts.warnedFormats = &warnedFormats{warned: make(map[string]bool)}
+	}
+	return opts
+}
+
+// WithInteger53BitSafe keeps generated integers within ±(2^53-1), the integers
+// JavaScript clients can represent, unless the schema's bounds ask for larger ones.
+// It takes precedence over WithBigIntegers.
+func (opts *GenerationOptions) WithInteger53BitSafe(enabled bool) *GenerationOptions {
+	opts.Integer53BitSafe = enabled
+	return opts
+}
+
+// WithPropertyOrder emits the properties of generated objects in the order they are
+// declared in the spec instead of sorted by name. Other keys, such as additional
+// properties, follow sorted by name. The order is only known for specs loaded
+// with ReadSpec or ReadSpecFromReader.
+func (opts *GenerationOptions) WithPropertyOrder(enabled bool) *GenerationOptions {
+	opts.PropertyOrder = enabled
+	return opts
+}
+
+// WithRetryLimit caps how often generation redraws a value that does not satisfy the
+// schema, e.g. pattern strings outside the length bounds or values for keys matching
+// several patternProperties. Draws that run out of attempts fail with
+// "could not satisfy ... after n attempts" instead of looping for impossible schemas.
+func (opts *GenerationOptions) WithRetryLimit(n int) *GenerationOptions {
+	opts.RetryLimit = n
+	return opts
+}
+
+// ReadOption configures how ReadSpec and ReadSpecFromReader load a spec
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	lenient bool
+}
+
+// WithLenientValidation loads specs that use keywords kin-openapi does not know, such
+// as custom vendor keywords without the x- prefix. Such keywords are logged as
+// warnings instead of failing validation; all other validation errors still fail.
+func WithLenientValidation() ReadOption {
+	return func(o *readOptions) {
+		o.lenient = true
+	}
+}
+
+// commentKeywords carry no meaning for validation or generation, and are always allowed
+var commentKeywords = []string{"$comment"}
+
+func ReadSpec(specPath string, options ...ReadOption) (*openapi3.T, error) {
 	// load spec
 	b, err := os.Open(specPath)
 	if err != nil {
 		return nil, err
 	}
 
-	return ReadSpecFromReader(b)
+	return ReadSpecFromReader(b, options...)
+}
+
+func ReadSpecFromReader(b io.Reader, options ...ReadOption) (*openapi3.T, error) {
+	var readOpts readOptions
+	for _, option := range options {
+		option(&readOpts)
+	}
+
+	data, err := io.ReadAll(b)
+	if err != nil {
+		return nil, err
+	}
+
+	kinDoc, err := loadSpec(data, readOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadWebhooks(data, kinDoc, readOpts); err != nil {
+		return nil, err
+	}
+	return kinDoc, nil
 }
 
-func ReadSpecFromReader(b io.Reader) (*openapi3.T, error) {
+// loadSpec loads and validates the spec in data
+func loadSpec(data []byte, readOpts readOptions) (*openapi3.T, error) {
 	// kin-openapi to reuse our schema generator
 	loader := &openapi3.Loader{IsExternalRefsAllowed: true}
-	kinDoc, err := loader.LoadFromIoReader(b)
+	kinDoc, err := loader.LoadFromData(data)
 	if err != nil {
 		return nil, err
 	}
-	if err := kinDoc.Validate(loader.Context); err != nil {
-		return nil, fmt.Errorf("kin-openapi validate errors: %v", err)
+	// kin-openapi keeps properties in a map, the declaration order comes from the source
+	recordPropertyOrigins(data, kinDoc)
+	// and drops keywords next to a $ref, which refine the referenced schema in 3.1
+	applyRefSiblings(data, kinDoc)
+	// stale examples should not keep a spec from being generated for, they are
+	// reported by ValidateExamples instead
+	siblings := slices.Concat(schemaKeywords, refSiblingKeywords, commentKeywords, documentKeywords)
+	for {
+		err := kinDoc.Validate(loader.Context, openapi3.AllowExtraSiblingFields(siblings...), openapi3.DisableExamplesValidation())
+		if err == nil {
+			break
+		}
+		// kin-openapi reports the unknown keywords of one object at a time
+		unknown := unknownKeywords(err)
+		if !readOpts.lenient || len(unknown) == 0 || slices.ContainsFunc(unknown, func(k string) bool { return slices.Contains(siblings, k) }) {
+			return nil, fmt.Errorf("kin-openapi validate errors: %v", err)
+		}
+		log.Printf("specsmash: ignoring unknown keywords %v: %v", unknown, err)
+		siblings = append(siblings, unknown...)
 	}
 
 	return kinDoc, nil
 
 }
 
+// extraSiblingFields matches the kin-openapi validation error for unknown keywords
+var extraSiblingFields = regexp.MustCompile(`extra sibling fields: \[([^\]]*)\]`)
+
+// unknownKeywords returns the unknown keywords a validation error is about, if any
+func unknownKeywords(err error) []string {
+	m := extraSiblingFields.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	return strings.Fields(m[1])
+}
+
 // GenFromSchema is a public wrapper that creates default options and generates from schema
 func GenFromSchema(schema *openapi3.Schema) *rapid.Generator[json.RawMessage] {
 	opts := NewGenerationOptions()
 	return opts.GenFromSchema(schema)
 }
 
-func ValidatePayload(ctx context.Context, payload []byte, p string, op *openapi3.Operation) error {
+func ValidatePayload(ctx context.Context, payload []byte, p string, op *openapi3.Operation, pathItem ...*openapi3.PathItem) error {
+	return ValidatePayloadWithOptions(ctx, payload, p, op, nil, pathItem...)
+}
+
+// ValidatePayloadWithOptions is ValidatePayload with openapi3filter options, e.g. to skip
+// readOnly validation. See RequestValidationOptions for options matching a GenerationMode.
+// The payload is sent as the media type GetSchema takes the schema from, so bodies of
+// operations that only accept application/merge-patch+json validate as merge patches.
+// The path template p, e.g. /users/{id}, is filled with values generated for the
+// path parameters of op, so the request has a concrete path like /users/42. Pass the
+// path item of op if path parameters are declared on it, see GenParameters.
+func ValidatePayloadWithOptions(ctx context.Context, payload []byte, p string, op *openapi3.Operation, options *openapi3filter.Options, pathItem ...*openapi3.PathItem) error {
+	return validatePayload(ctx, payload, p, op, options, nil, pathItem)
+}
+
+// ValidatePayloadInDoc is ValidatePayloadWithOptions for an operation of doc. A request
+// body given as a $ref to components/requestBodies is looked up in doc if its value
+// was not loaded, see GetSchema, and path parameters declared on the path item of p
+// in doc are filled in as well. options may be nil.
+func ValidatePayloadInDoc(ctx context.Context, payload []byte, p string, op *openapi3.Operation, doc *openapi3.T, options *openapi3filter.Options) error {
+	var pathItems []*openapi3.PathItem
+	if doc != nil && doc.Paths != nil {
+		if item := doc.Paths.Value(p); item != nil {
+			pathItems = append(pathItems, item)
+		}
+	}
+	return validatePayload(ctx, payload, p, op, options, doc, pathItems)
+}
+
+// validatePayload validates payload as the request body of op, resolved in doc if it is not nil
+func validatePayload(ctx context.Context, payload []byte, p string, op *openapi3.Operation, options *openapi3filter.Options, doc *openapi3.T, pathItems []*openapi3.PathItem) error {
+	body := resolveRequestBody(op, doc)
+	if body == nil {
+		return fmt.Errorf("operation has no request body")
+	}
+	mediaType, ok := schemaMediaType(body)
+	if !ok {
+		mediaType = "application/json"
+	}
+	path, pathParams := concretePath(p, op, pathItems)
 	requestValidationInput := &openapi3filter.RequestValidationInput{
 		Request: &http.Request{
 			Method: "POST",
-			URL:    &url.URL{Path: p},
+			URL:    &url.URL{Path: path},
 			Body:   io.NopCloser(bytes.NewBuffer(payload)),
-			Header: http.Header{"Content-Type": []string{"application/json"}},
+			Header: http.Header{"Content-Type": []string{mediaType}},
 		},
+		PathParams: pathParams,
+		Options:    options,
 	}
-	err := openapi3filter.ValidateRequestBody(ctx, requestValidationInput, op.RequestBody.Value)
+	err := openapi3filter.ValidateRequestBody(ctx, requestValidationInput, body)
 	return err
 }
 
-func GetSchema(op *openapi3.Operation) (*openapi3.SchemaRef, bool) {
-	if op == nil || op.RequestBody == nil {
+// concretePath fills the path template p with the example values of the path
+// parameters of op and its path items, see PathString, and returns them by name as
+// they appear in the path. Parameters whose values cannot be generated are left as they are.
+func concretePath(p string, op *openapi3.Operation, pathItems []*openapi3.PathItem) (string, map[string]string) {
+	if op == nil || !strings.Contains(p, "{") {
+		return p, nil
+	}
+	values := examplePathParameters(op, pathItems)
+	params := make(map[string]string)
+	for _, ref := range operationParameters(op, pathItems) {
+		if ref == nil || ref.Value == nil || ref.Value.In != openapi3.ParameterInPath {
+			continue
+		}
+		if raw, ok := values[openapi3.ParameterInPath][ref.Value.Name]; ok {
+			params[ref.Value.Name] = pathText(ref.Value, raw)
+		}
+	}
+	return PathString(p, op, values, pathItems...), params
+}
+
+// examplePathParameters generates one deterministic set of parameter values for op,
+// or none if generation fails, e.g. for patterns without a PatternFunc
+func examplePathParameters(op *openapi3.Operation, pathItems []*openapi3.PathItem) (values ParameterValues) {
+	defer func() {
+		if recover() != nil {
+			values = nil
+		}
+	}()
+	return NewGenerationOptions().GenParameters(op, pathItems...).Example(0)
+}
+
+// RequestValidationOptions returns the options under which request bodies generated in mode validate.
+// Only ModeRequest and ModeMergePatch leave out readOnly properties, the other modes need
+// readOnly validation skipped.
+func RequestValidationOptions(mode GenerationMode) *openapi3filter.Options {
+	return &openapi3filter.Options{
+		ExcludeReadOnlyValidations: mode != ModeRequest && mode != ModeMergePatch,
+	}
+}
+
+// GetSchema returns the JSON (or merge patch) request body schema of op. A request
+// body given as a $ref to components/requestBodies is resolved by the loader, or,
+// when its value was not loaded, looked up in doc if it is passed.
+func GetSchema(op *openapi3.Operation, doc ...*openapi3.T) (*openapi3.SchemaRef, bool) {
+	body := resolveRequestBody(op, doc...)
+	if body == nil {
 		return nil, false
 	}
-	media, ok := op.RequestBody.Value.Content["application/json"]
+	mediaType, ok := schemaMediaType(body)
 	if !ok {
 		return nil, false
 	}
-	schema := media.Schema
+	schema := body.Content[mediaType].Schema
 
 	return schema, true
 }
+
+// schemaMediaType returns the media type GetSchema takes the schema of body from:
+// application/json, else application/merge-patch+json
+func schemaMediaType(body *openapi3.RequestBody) (string, bool) {
+	for _, mediaType := range []string{"application/json", mergePatchMediaType} {
+		if _, ok := body.Content[mediaType]; ok {
+			return mediaType, true
+		}
+	}
+	return "", false
+}
+
+// resolveRequestBody returns the request body of op, or nil if it has none. Request
+// bodies that are a $ref to #/components/requestBodies without a loaded value are
+// looked up in the components of doc, following refs between request bodies.
+func resolveRequestBody(op *openapi3.Operation, doc ...*openapi3.T) *openapi3.RequestBody {
+	if op == nil || op.RequestBody == nil {
+		return nil
+	}
+	ref := op.RequestBody
+	for hops := 0; ref.Value == nil; hops++ {
+		name, ok := strings.CutPrefix(ref.Ref, "#/components/requestBodies/")
+		if !ok || len(doc) == 0 || doc[0] == nil || doc[0].Components == nil || hops > len(doc[0].Components.RequestBodies) {
+			return nil
+		}
+		next := doc[0].Components.RequestBodies[unescapePointer(name)]
+		if next == nil {
+			return nil
+		}
+		ref = next
+	}
+	return ref.Value
+}
@@ -1,10 +1,26 @@
 package SpecSmash
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
+	"maps"
+	"math/big"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"pgregory.net/rapid"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -17,11 +33,18 @@ func GenerateAndValidate(t *testing.T, specPath string) error {
 	kinDoc, err := ReadSpec(specPath)
 	assert.NoError(t, err)
 
-	generationOpts := NewGenerationOptions()
+	generationOpts := NewGenerationOptions().WithMode(ModeRequest).WithPatternFunc(
+		func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+			return rapid.StringMatching(pattern).Draw(t, "pattern")
+		},
+	)
 
-	// iterate paths, focus on POST and application/json requestBody only
-	for p, item := range kinDoc.Paths.Map() {
-		op := item.Post
+	// iterate paths, callbacks and webhooks, focus on POST and application/json requestBody only
+	ops := postOperations(kinDoc.Paths.Map())
+	for name, op := range postOperations(Webhooks(kinDoc)) {
+		ops[name] = op
+	}
+	for p, op := range ops {
 		schema, ok := GetSchema(op)
 		if !ok {
 			continue
@@ -45,6 +68,28 @@ func GenerateAndValidate(t *testing.T, specPath string) error {
 	return nil
 }
 
+// postOperations returns the POST operations of the path items by path, including
+// those of callbacks nested in any operation, keyed by their callback expression
+func postOperations(items map[string]*openapi3.PathItem) map[string]*openapi3.Operation {
+	ops := make(map[string]*openapi3.Operation)
+	for p, item := range items {
+		if item.Post != nil {
+			ops[p] = item.Post
+		}
+		for _, op := range item.Operations() {
+			for _, callback := range op.Callbacks {
+				if callback == nil || callback.Value == nil {
+					continue
+				}
+				for cp, cop := range postOperations(callback.Value.Map()) {
+					ops[cp] = cop
+				}
+			}
+		}
+	}
+	return ops
+}
+
 func TestGenerateAndValidateSimple(t *testing.T) {
 	err := GenerateAndValidate(t, "testdata/openapi_simple.yaml")
 	if err != nil {
@@ -59,6 +104,41 @@ func TestGenerateAndValidateComprehensive(t *testing.T) {
 	}
 }
 
+func TestGenerateAndValidateWebhooks(t *testing.T) {
+	err := GenerateAndValidate(t, "testdata/openapi_webhooks.yaml")
+	if err != nil {
+		t.Fatalf("GenerateAndValidate failed: %v", err)
+	}
+}
+
+func TestGenerateAndValidateArrayBodies(t *testing.T) {
+	err := GenerateAndValidate(t, "testdata/openapi_array_bodies.yaml")
+	if err != nil {
+		t.Fatalf("GenerateAndValidate failed: %v", err)
+	}
+
+	doc, err := ReadSpec("testdata/openapi_array_bodies.yaml")
+	require.NoError(t, err)
+	schema, ok := GetSchema(doc.Paths.Find("/pets/batch").Post)
+	require.True(t, ok)
+	var pets []map[string]any
+	require.NoError(t, json.Unmarshal(GenFromSchema(schema.Value).Example(0), &pets))
+	assert.NotEmpty(t, pets)
+}
+
+func TestGenerateAndValidateCallbacks(t *testing.T) {
+	kinDoc, err := ReadSpec("testdata/openapi_callbacks.yaml")
+	require.NoError(t, err)
+	ops := postOperations(kinDoc.Paths.Map())
+	require.Contains(t, ops, "{$request.body#/callbackUrl}")
+	require.Contains(t, ops, "{$request.body#/statusUrl}", "callbacks nested in callbacks")
+
+	err = GenerateAndValidate(t, "testdata/openapi_callbacks.yaml")
+	if err != nil {
+		t.Fatalf("GenerateAndValidate failed: %v", err)
+	}
+}
+
 func TestCheck(t *testing.T) {
 	specPath := "testdata/openapi_comprehensive.yaml"
 
@@ -149,9 +229,2851 @@ func TestCheck(t *testing.T) {
 
 }
 
+// defineUUIDFormat makes kin-openapi validate the uuid format until t ends. The
+// format validators are global, so the previous one is restored afterwards.
+func defineUUIDFormat(t *testing.T) {
+	previous, defined := openapi3.SchemaStringFormats["uuid"]
+	openapi3.DefineStringFormatValidator("uuid", openapi3.NewRegexpFormatValidator(openapi3.FormatOfStringForUUIDOfRFC4122))
+	t.Cleanup(func() {
+		if defined {
+			openapi3.SchemaStringFormats["uuid"] = previous
+		} else {
+			delete(openapi3.SchemaStringFormats, "uuid")
+		}
+	})
+}
+
 func TestGenerateAndValidateUltraComprehensive(t *testing.T) {
+	// the spec discriminates oneOf branches by uuid format, which kin-openapi does
+	// not validate unless the format is defined
+	defineUUIDFormat(t)
 	err := GenerateAndValidate(t, "testdata/openapi_ultra_comprehensive.yaml")
 	if err != nil {
 		t.Fatalf("GenerateAndValidate failed: %v", err)
 	}
 }
+
+func mustSchema(t *testing.T, js string) *openapi3.Schema {
+	var schema openapi3.Schema
+	require.NoError(t, json.Unmarshal([]byte(js), &schema))
+	return &schema
+}
+
+func TestConstInArraysAndObjects(t *testing.T) {
+	arraySchema := mustSchema(t, `{"type": "array", "minItems": 1, "items": {"const": "x"}}`)
+	objectSchema := mustSchema(t, `{
+		"type": "object",
+		"required": ["kind"],
+		"properties": {"kind": {"type": "string", "const": "cat"}}
+	}`)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var arr []string
+		require.NoError(t, json.Unmarshal(GenFromSchema(arraySchema).Draw(rt, "array"), &arr))
+		assert.NotEmpty(t, arr)
+		for _, item := range arr {
+			assert.Equal(t, "x", item)
+		}
+
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(GenFromSchema(objectSchema).Draw(rt, "object"), &obj))
+		assert.Equal(t, "cat", obj["kind"])
+	})
+}
+
+func TestConstLoadsFromSpec(t *testing.T) {
+	_, err := ReadSpecFromReader(strings.NewReader(`
+openapi: 3.0.3
+info:
+  title: const
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Cat:
+      type: object
+      properties:
+        kind:
+          const: cat
+`))
+	assert.NoError(t, err)
+}
+
+func TestScalarAdditionalValues(t *testing.T) {
+	schema := mustSchema(t, `{"type": "object", "additionalProperties": true}`)
+	gen := NewGenerationOptions().WithScalarAdditionalValues(true).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		for key, value := range obj {
+			assert.NotContains(t, "[{", string(value[0]), "additional property %q is not a scalar: %s", key, value)
+		}
+	})
+}
+
+func TestWriteOnlyByMode(t *testing.T) {
+	account := `{
+		"type": "object",
+		"required": ["user"],
+		"properties": {
+			"user": {
+				"type": "object",
+				"required": ["name", "password"],
+				"properties": {
+					"name": {"type": "string"},
+					"password": {"type": "string", "writeOnly": true}
+				}
+			}
+		}
+	}`
+	schemas := map[string]*openapi3.Schema{
+		"nested": mustSchema(t, account),
+		"allOf":  mustSchema(t, `{"allOf": [`+account+`, {"type": "object", "properties": {"id": {"type": "integer"}}}]}`),
+	}
+
+	for name, schema := range schemas {
+		t.Run(name, func(t *testing.T) {
+			requestGen := NewGenerationOptions().WithMode(ModeRequest).GenFromSchema(schema)
+			responseGen := NewGenerationOptions().WithMode(ModeResponse).GenFromSchema(schema)
+
+			rapid.Check(t, func(rt *rapid.T) {
+				var request, response struct {
+					User map[string]any `json:"user"`
+				}
+				require.NoError(t, json.Unmarshal(requestGen.Draw(rt, "request"), &request))
+				require.NoError(t, json.Unmarshal(responseGen.Draw(rt, "response"), &response))
+
+				assert.Contains(t, request.User, "password")
+				assert.Contains(t, response.User, "name")
+				assert.NotContains(t, response.User, "password")
+			})
+		})
+	}
+}
+
+func TestReadOnlyRequiredPolicy(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["id", "createdAt", "name"],
+		"properties": {
+			"id": {"type": "integer", "readOnly": true, "example": 42},
+			"createdAt": {"type": "string", "format": "date-time", "readOnly": true},
+			"etag": {"type": "string", "readOnly": true},
+			"name": {"type": "string"}
+		}
+	}`)
+	omitGen := NewGenerationOptions().WithMode(ModeRequest).GenFromSchema(schema)
+	sentinelGen := NewGenerationOptions().WithMode(ModeRequest).
+		WithReadOnlyRequiredPolicy(ReadOnlyRequiredSentinel).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var omitted, sentinel map[string]any
+		require.NoError(t, json.Unmarshal(omitGen.Draw(rt, "omit"), &omitted))
+		require.NoError(t, json.Unmarshal(sentinelGen.Draw(rt, "sentinel"), &sentinel))
+
+		assert.NotContains(t, omitted, "id")
+		assert.NotContains(t, omitted, "createdAt")
+
+		assert.Equal(t, float64(42), sentinel["id"])
+		assert.Contains(t, sentinel, "createdAt")
+		assert.NoError(t, schema.VisitJSON(sentinel))
+		// optional readOnly properties are still left out
+		assert.NotContains(t, sentinel, "etag")
+	})
+}
+
+func benchmarkGen(b *testing.B, schema *openapi3.Schema) {
+	gen := GenFromSchema(schema)
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		gen.Example(i)
+	}
+}
+
+func BenchmarkGenStringEnum(b *testing.B) {
+	benchmarkGen(b, &openapi3.Schema{
+		Type: getType("string"),
+		Enum: []any{"purchase", "refund", "chargeback", "payout", "adjustment"},
+	})
+}
+
+func BenchmarkGenIntegerEnum(b *testing.B) {
+	benchmarkGen(b, &openapi3.Schema{
+		Type: getType("integer"),
+		Enum: []any{1, 2, 3, 5, 8, 13, 21},
+	})
+}
+
+func BenchmarkGenRequestBodies(b *testing.B) {
+	kinDoc, err := ReadSpec("testdata/openapi_simple.yaml")
+	require.NoError(b, err)
+	for _, p := range kinDoc.Paths.InMatchingOrder() {
+		schema, ok := GetSchema(kinDoc.Paths.Value(p).Post)
+		if !ok {
+			continue
+		}
+		b.Run(p, func(b *testing.B) {
+			benchmarkGen(b, schema.Value)
+		})
+	}
+}
+
+func TestAnyOfMixedPrimitiveAndObject(t *testing.T) {
+	schema := mustSchema(t, `{"anyOf": [
+		{"type": "string"},
+		{"type": "object", "required": ["a"], "properties": {"a": {"type": "integer"}}, "additionalProperties": false},
+		{"type": "object", "required": ["b"], "properties": {"b": {"type": "boolean"}}, "additionalProperties": false}
+	]}`)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var value any
+		require.NoError(t, json.Unmarshal(GenFromSchema(schema).Draw(rt, "value"), &value))
+
+		switch v := value.(type) {
+		case string:
+		case map[string]any:
+			_, hasA := v["a"]
+			_, hasB := v["b"]
+			assert.True(t, hasA || hasB, "object satisfies no branch: %v", v)
+		default:
+			t.Fatalf("unexpected value %v", v)
+		}
+	})
+}
+
+func TestAnyOfScalarUnion(t *testing.T) {
+	schema := mustSchema(t, `{"anyOf": [{"type": "string"}, {"type": "integer"}, {"type": "boolean"}]}`)
+	gen := GenFromSchema(schema)
+
+	kinds := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		payload := gen.Example(i)
+		require.True(t, validAgainst(schema, payload), string(payload))
+		var value any
+		require.NoError(t, json.Unmarshal(payload, &value))
+		kinds[fmt.Sprintf("%T", value)] = true
+	}
+	assert.Equal(t, map[string]bool{"string": true, "float64": true, "bool": true}, kinds)
+
+	// the meta names the one branch that was generated, not every branch drawn
+	types := []string{"string", "float64", "bool"}
+	rapid.Check(t, func(rt *rapid.T) {
+		result := GenFromSchemaWithMeta(schema).Draw(rt, "result")
+		require.Len(t, result.Meta.AnyOf[""], 1)
+		var value any
+		require.NoError(t, json.Unmarshal(result.Payload, &value))
+		assert.Equal(t, types[result.Meta.AnyOf[""][0]], fmt.Sprintf("%T", value))
+	})
+}
+
+func TestBooleanSchemas(t *testing.T) {
+	assert.True(t, subSchema(true).IsEmpty())
+	assert.True(t, isFalseSchema(subSchema(false)))
+	assert.False(t, isFalseSchema(subSchema(map[string]any{"type": "string"})))
+
+	objectSchema := mustSchema(t, `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {"id": {"type": "integer"}, "legacy": {"not": {}}}
+	}`)
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(GenFromSchema(objectSchema).Draw(rt, "object"), &obj))
+		assert.NotContains(t, obj, "legacy")
+	})
+
+	assert.Panics(t, func() { GenFromSchema(subSchema(false)).Example() })
+}
+
+func TestValidatePayloadMatchesMode(t *testing.T) {
+	kinDoc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: readOnly
+  version: 1.0.0
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [id, name]
+              properties:
+                id:
+                  type: integer
+                  readOnly: true
+                name:
+                  type: string
+      responses:
+        '200':
+          description: ok
+`)
+	op := kinDoc.Paths.Value("/users").Post
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+
+	for _, mode := range []GenerationMode{ModeAny, ModeRequest, ModeResponse} {
+		gen := NewGenerationOptions().WithMode(mode).GenFromSchema(schema.Value)
+		rapid.Check(t, func(rt *rapid.T) {
+			payload := gen.Draw(rt, "payload")
+			err := ValidatePayloadWithOptions(rt.Context(), payload, "/users", op, RequestValidationOptions(mode))
+			assert.NoError(t, err, "mode %d payload %s", mode, payload)
+		})
+	}
+
+	payload := NewGenerationOptions().GenFromSchema(schema.Value).Example()
+	assert.Error(t, ValidatePayload(t.Context(), payload, "/users", op), "readOnly id must be rejected by default")
+}
+
+// jsonDepth returns the nesting depth of objects and arrays in a JSON value
+func jsonDepth(v any) int {
+	depth := 0
+	switch v := v.(type) {
+	case map[string]any:
+		for _, child := range v {
+			depth = max(depth, jsonDepth(child))
+		}
+		return depth + 1
+	case []any:
+		for _, child := range v {
+			depth = max(depth, jsonDepth(child))
+		}
+		return depth + 1
+	}
+	return depth
+}
+
+func TestMaxDepthTermination(t *testing.T) {
+	// node is recursive through optional properties only
+	node := mustSchema(t, `{
+		"type": "object",
+		"required": ["value"],
+		"properties": {"value": {"type": "integer"}, "tags": {"type": "array", "items": {"type": "string"}}}
+	}`)
+	node.Properties["child"] = &openapi3.SchemaRef{Value: node}
+	node.Properties["children"] = &openapi3.SchemaRef{Value: &openapi3.Schema{
+		Type:  getType("array"),
+		Items: &openapi3.SchemaRef{Value: node},
+	}}
+
+	// chain is a finite chain of required objects, deeper than MaxDepth
+	chain := mustSchema(t, `{"type": "object", "required": ["a"], "properties": {"a":
+		{"type": "object", "required": ["b"], "properties": {"b":
+			{"type": "object", "required": ["c"], "properties": {"c":
+				{"type": "object", "required": ["leaf"], "properties": {"leaf": {"type": "string"}}}}}}}}}`)
+
+	opts := NewGenerationOptions()
+	opts.MaxDepth = 2
+	nodeGen := opts.GenFromSchema(node)
+	chainGen := opts.GenFromSchema(chain)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var value any
+		require.NoError(t, json.Unmarshal(nodeGen.Draw(rt, "node"), &value))
+		// every level past MaxDepth only contains the required scalar
+		assert.LessOrEqual(t, jsonDepth(value), 2*opts.MaxDepth+1)
+
+		var leaf struct {
+			A struct {
+				B struct{ C struct{ Leaf *string } }
+			}
+		}
+		require.NoError(t, json.Unmarshal(chainGen.Draw(rt, "chain"), &leaf))
+		assert.NotNil(t, leaf.A.B.C.Leaf)
+	})
+}
+
+func TestItemlessArrays(t *testing.T) {
+	schema := mustSchema(t, `{"type": "array"}`)
+	for _, maxDepth := range []int{1, 3} {
+		opts := NewGenerationOptions()
+		opts.MaxDepth = maxDepth
+		gen := opts.GenFromSchema(schema)
+
+		kinds := make(map[string]bool)
+		for i := range 300 {
+			payload := gen.Example(i)
+			require.True(t, validAgainst(schema, payload), string(payload))
+			var items []any
+			require.NoError(t, json.Unmarshal(payload, &items))
+			assert.LessOrEqual(t, jsonDepth(items), maxDepth, string(payload))
+			for _, item := range items {
+				kinds[fmt.Sprintf("%T", item)] = true
+			}
+		}
+		assert.Subset(t, slices.Collect(maps.Keys(kinds)), []string{"string", "float64", "bool", "<nil>"}, maxDepth)
+		if maxDepth > 1 {
+			assert.Subset(t, slices.Collect(maps.Keys(kinds)), []string{"[]interface {}", "map[string]interface {}"})
+		}
+	}
+}
+
+func TestOnlyRequiredDepth(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["id", "owner"],
+		"properties": {
+			"id": {"type": "integer"},
+			"note": {"type": "string"},
+			"owner": {
+				"type": "object",
+				"required": ["name", "address"],
+				"minProperties": 3,
+				"properties": {
+					"name": {"type": "string"},
+					"email": {"type": "string"},
+					"address": {
+						"type": "object",
+						"required": ["city"],
+						"properties": {"city": {"type": "string"}, "zip": {"type": "string"}},
+						"additionalProperties": true
+					}
+				}
+			}
+		}
+	}`)
+	gen := NewGenerationOptions().WithOnlyRequiredDepth(1).GenFromSchema(schema)
+
+	notes := 0
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		require.True(t, validAgainst(schema, payload), string(payload))
+		var value struct {
+			Note  *string
+			Owner map[string]json.RawMessage
+		}
+		require.NoError(t, json.Unmarshal(payload, &value))
+		if value.Note != nil {
+			notes++
+		}
+		// minProperties still asks for one optional property
+		assert.Len(t, value.Owner, 3)
+		assert.Contains(t, value.Owner, "email")
+
+		var address map[string]any
+		require.NoError(t, json.Unmarshal(value.Owner["address"], &address))
+		assert.Equal(t, []string{"city"}, slices.Collect(maps.Keys(address)))
+	})
+	// the root is above the configured depth and keeps its optional properties
+	assert.Positive(t, notes)
+}
+
+func TestUUIDVersions(t *testing.T) {
+	v7Pattern := `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`
+	upperPattern := `^[0-9A-F]{8}-[0-9A-F]{4}-4[0-9A-F]{3}-[89AB][0-9A-F]{3}-[0-9A-F]{12}$`
+
+	v7 := NewGenerationOptions().WithUUIDVersion(7).GenFromSchema(
+		mustSchema(t, `{"type": "string", "format": "uuid", "pattern": "`+v7Pattern+`"}`))
+	v7Extension := GenFromSchema(mustSchema(t, `{"type": "string", "format": "uuid", "x-uuid-version": 7}`))
+	upper := GenFromSchema(mustSchema(t, `{"type": "string", "format": "uuid", "pattern": "`+upperPattern+`"}`))
+	v4 := GenFromSchema(mustSchema(t, `{"type": "string", "format": "uuid"}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var id string
+		require.NoError(t, json.Unmarshal(v7.Draw(rt, "v7"), &id))
+		assert.Regexp(t, v7Pattern, id)
+
+		require.NoError(t, json.Unmarshal(v7Extension.Draw(rt, "v7-extension"), &id))
+		assert.Regexp(t, v7Pattern, id)
+
+		require.NoError(t, json.Unmarshal(upper.Draw(rt, "upper"), &id))
+		assert.Regexp(t, upperPattern, id)
+
+		require.NoError(t, json.Unmarshal(v4.Draw(rt, "v4"), &id))
+		assert.Regexp(t, openapi3.FormatOfStringForUUIDOfRFC4122, id)
+	})
+}
+
+func TestOverrideByPointer(t *testing.T) {
+	kinDoc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: override
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    User:
+      type: object
+      required: [email]
+      properties:
+        email:
+          type: string
+          format: email
+    Order:
+      type: object
+      required: [buyer, sellers]
+      properties:
+        buyer:
+          $ref: '#/components/schemas/User'
+        sellers:
+          type: array
+          minItems: 1
+          items:
+            $ref: '#/components/schemas/User'
+`)
+	opts := NewGenerationOptions().WithOverride(
+		"/components/schemas/User/properties/email",
+		rapid.Just(json.RawMessage(`"fixed@example.com"`)),
+	)
+	userGen := opts.GenFromSchemaAt("/components/schemas/User", kinDoc.Components.Schemas["User"].Value)
+	orderGen := opts.GenFromSchema(kinDoc.Components.Schemas["Order"].Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var user struct{ Email string }
+		require.NoError(t, json.Unmarshal(userGen.Draw(rt, "user"), &user))
+		assert.Equal(t, "fixed@example.com", user.Email)
+
+		var order struct {
+			Buyer   struct{ Email string }
+			Sellers []struct{ Email string }
+		}
+		require.NoError(t, json.Unmarshal(orderGen.Draw(rt, "order"), &order))
+		assert.Equal(t, "fixed@example.com", order.Buyer.Email)
+		for _, seller := range order.Sellers {
+			assert.Equal(t, "fixed@example.com", seller.Email)
+		}
+	})
+}
+
+func TestAllOfBaseWithOneOf(t *testing.T) {
+	kinDoc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: allOf oneOf
+  version: 1.0.0
+paths:
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              allOf:
+                - type: object
+                  required: [id]
+                  properties:
+                    id:
+                      type: integer
+                - oneOf:
+                    - type: object
+                      required: [meow]
+                      properties:
+                        meow:
+                          type: boolean
+                    - type: object
+                      required: [bark]
+                      properties:
+                        bark:
+                          type: string
+      responses:
+        '200':
+          description: ok
+`)
+	op := kinDoc.Paths.Value("/pets").Post
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+	gen := GenFromSchema(schema.Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		assert.NoError(t, ValidatePayload(rt.Context(), payload, "/pets", op))
+
+		var pet map[string]any
+		require.NoError(t, json.Unmarshal(payload, &pet))
+		assert.Contains(t, pet, "id")
+		_, meows := pet["meow"]
+		_, barks := pet["bark"]
+		assert.True(t, meows != barks, "exactly one branch expected: %s", payload)
+	})
+}
+
+func TestAllOfMergedOnce(t *testing.T) {
+	schema := mustSchema(t, `{
+		"allOf": [
+			{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}},
+			{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+		]
+	}`)
+	opts := NewGenerationOptions()
+	gen := opts.GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		assert.Contains(t, obj, "id")
+		assert.Contains(t, obj, "name")
+	})
+
+	// every draw reused the one merge
+	require.Len(t, opts.allOfMerges.merged, 1)
+	merged := opts.allOfMerges.merged[schema]
+	assert.Same(t, merged, opts.allOfMerges.get(schema))
+	assert.ElementsMatch(t, []string{"id", "name"}, merged.schema.Required)
+
+	opts.Reset()
+	assert.Empty(t, opts.allOfMerges.merged)
+}
+
+func TestUnevaluatedPropertiesFalse(t *testing.T) {
+	schemas := map[string][]string{
+		`{"type": "object", "unevaluatedProperties": false}`: nil,
+		`{
+			"allOf": [
+				{"type": "object", "properties": {"a": {"type": "string"}}},
+				{"type": "object", "properties": {"b": {"type": "integer"}}}
+			],
+			"unevaluatedProperties": false
+		}`: {"a", "b"},
+		`{
+			"type": "object",
+			"properties": {"kind": {"type": "string"}},
+			"oneOf": [{"required": ["kind"]}],
+			"unevaluatedProperties": false
+		}`: {"kind"},
+	}
+	for source, allowed := range schemas {
+		gen := NewGenerationOptions().WithMapMinEntries(3).GenFromSchema(mustSchema(t, source))
+		rapid.Check(t, func(rt *rapid.T) {
+			var obj map[string]any
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+			for key := range obj {
+				assert.Contains(t, allowed, key, source)
+			}
+		})
+	}
+
+	// additionalProperties evaluates every property, so extras are still generated
+	gen := NewGenerationOptions().WithMapMinEntries(1).GenFromSchema(mustSchema(t,
+		`{"type": "object", "additionalProperties": {"type": "string"}, "unevaluatedProperties": false}`))
+	var obj map[string]any
+	require.NoError(t, json.Unmarshal(gen.Example(0), &obj))
+	assert.NotEmpty(t, obj)
+}
+
+func TestEnumCoverage(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["status", "level"],
+		"properties": {
+			"status": {"type": "string", "enum": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"]},
+			"level": {"type": "integer", "enum": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12]}
+		}
+	}`)
+	gen := NewGenerationOptions().WithEnumCoverage(true).GenFromSchema(schema)
+
+	statuses := make(map[string]bool)
+	levels := make(map[int]bool)
+	for i := 0; i < 12; i++ {
+		var obj struct {
+			Status string
+			Level  int
+		}
+		require.NoError(t, json.Unmarshal(gen.Example(i), &obj))
+		statuses[obj.Status] = true
+		levels[obj.Level] = true
+	}
+
+	assert.Len(t, statuses, 12)
+	assert.Len(t, levels, 12)
+}
+
+func TestNumberMultipleOfLargeNegative(t *testing.T) {
+	kinDoc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: negative
+  version: 1.0.0
+paths:
+  /debts:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [balance, deep, cents]
+              properties:
+                balance:
+                  type: number
+                  maximum: -1000000
+                  multipleOf: 1000
+                deep:
+                  type: number
+                  minimum: -900000000000
+                  maximum: -800000000000
+                  multipleOf: 1000
+                cents:
+                  type: number
+                  minimum: 0
+                  maximum: 100
+                  multipleOf: 0.25
+      responses:
+        '200':
+          description: ok
+`)
+	op := kinDoc.Paths.Value("/debts").Post
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+	gen := GenFromSchema(schema.Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		assert.NoError(t, ValidatePayload(rt.Context(), payload, "/debts", op), string(payload))
+	})
+}
+
+func TestMultipleOfExclusiveBounds(t *testing.T) {
+	for _, typ := range []string{"integer", "number"} {
+		schema := mustSchema(t, `{"type": "`+typ+`", "minimum": 0, "exclusiveMinimum": true, "maximum": 20, "multipleOf": 5}`)
+		gen := GenFromSchema(schema)
+
+		seen := make(map[float64]bool)
+		for i := 0; i < 200; i++ {
+			var v float64
+			require.NoError(t, json.Unmarshal(gen.Example(i), &v))
+			seen[v] = true
+		}
+		assert.Equal(t, map[float64]bool{5: true, 10: true, 15: true, 20: true}, seen, typ)
+	}
+
+	for schema, want := range map[string][]float64{
+		`{"type": "integer", "minimum": -20, "exclusiveMinimum": true, "maximum": 0, "exclusiveMaximum": true, "multipleOf": 5}`: {-15, -10, -5},
+		`{"type": "integer", "minimum": 1, "maximum": 12, "multipleOf": 2.5}`:                                                    {5, 10},
+	} {
+		gen := GenFromSchema(mustSchema(t, schema))
+		seen := make(map[float64]bool)
+		for i := 0; i < 200; i++ {
+			var v float64
+			require.NoError(t, json.Unmarshal(gen.Example(i), &v))
+			seen[v] = true
+		}
+		assert.ElementsMatch(t, want, sortedFloatKeys(seen), schema)
+	}
+}
+
+func sortedFloatKeys(m map[float64]bool) []float64 {
+	keys := make([]float64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// rapidPatternFunc generates pattern strings with rapid, for patterns that are valid RE2
+func rapidPatternFunc(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+	return rapid.StringMatching(pattern).Draw(t, "pattern")
+}
+
+func TestPatternPropertiesValues(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"additionalProperties": false,
+		"patternProperties": {
+			"^x-[a-z]{1,8}$": {"type": "integer", "minimum": 0},
+			"^x-n[a-z]{0,7}$": {"type": "integer", "maximum": 10},
+			"^s-[a-z]{1,8}$": {"type": "string", "maxLength": 3}
+		}
+	}`)
+	gen := NewGenerationOptions().WithPatternFunc(rapidPatternFunc).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		for key, value := range obj {
+			switch {
+			case strings.HasPrefix(key, "x-n"):
+				assert.GreaterOrEqual(t, value, 0.0, key)
+				assert.LessOrEqual(t, value, 10.0, key)
+			case strings.HasPrefix(key, "x-"):
+				assert.GreaterOrEqual(t, value, 0.0, key)
+			case strings.HasPrefix(key, "s-"):
+				assert.IsType(t, "", value, key)
+				assert.LessOrEqual(t, len([]rune(value.(string))), 3, key)
+			default:
+				t.Errorf("unexpected key %q", key)
+			}
+		}
+	})
+}
+
+func TestDeclaredPropertiesMatchingPatternProperties(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["x-rate", "name"],
+		"properties": {
+			"x-rate": {"type": "integer", "minimum": 0, "maximum": 20},
+			"name": {"type": "string"}
+		},
+		"patternProperties": {"^x-": {"multipleOf": 2}}
+	}`)
+	gen := NewGenerationOptions().WithPatternFunc(rapidPatternFunc).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		rate, ok := obj["x-rate"].(float64)
+		require.True(t, ok, "%v", obj["x-rate"])
+		assert.True(t, rate >= 0 && rate <= 20, rate)
+		assert.Zero(t, int(rate)%2, "x-rate follows its patternProperties schema too: %v", rate)
+		assert.IsType(t, "", obj["name"])
+	})
+}
+
+func TestDeclaredObjectMergedWithPatternProperties(t *testing.T) {
+	// the declared schema never generates "id", so redrawing alone could not
+	// satisfy the patternProperties schema; the merge generates it
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["x-owner"],
+		"properties": {
+			"x-owner": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+		},
+		"patternProperties": {
+			"^x-": {"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}
+		}
+	}`)
+	gen := NewGenerationOptions().WithPatternFunc(rapidPatternFunc).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj struct {
+			Owner map[string]any `json:"x-owner"`
+		}
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		assert.IsType(t, "", obj.Owner["name"])
+		assert.IsType(t, 0.0, obj.Owner["id"])
+	})
+
+	// the declared schema is left as it was
+	assert.NotContains(t, schema.Properties["x-owner"].Value.Properties, "id")
+}
+
+func TestStringValuePrecedence(t *testing.T) {
+	opts := NewGenerationOptions().WithPatternFunc(func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+		return rapid.SampledFrom([]string{"from-pattern"}).Draw(t, "pattern")
+	})
+	uuidRe := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name   string
+		schema string
+		check  func(t *testing.T, value string)
+	}{
+		{
+			name:   "enum over const",
+			schema: `{"type": "string", "enum": ["a", "b"], "const": "b"}`,
+			check:  func(t *testing.T, value string) { assert.Equal(t, "b", value) },
+		},
+		{
+			name:   "enum over format and pattern",
+			schema: `{"type": "string", "enum": ["a", "b"], "format": "uuid", "pattern": "^z$"}`,
+			check:  func(t *testing.T, value string) { assert.Contains(t, []string{"a", "b"}, value) },
+		},
+		{
+			name:   "const over format and pattern",
+			schema: `{"type": "string", "const": "fixed", "format": "email", "pattern": "^z$"}`,
+			check:  func(t *testing.T, value string) { assert.Equal(t, "fixed", value) },
+		},
+		{
+			name:   "format over compatible pattern",
+			schema: `{"type": "string", "format": "uuid", "pattern": "^[0-9a-f-]{36}$"}`,
+			check:  func(t *testing.T, value string) { assert.Regexp(t, uuidRe, value) },
+		},
+		{
+			name:   "pattern when format does not match it",
+			schema: `{"type": "string", "format": "email", "pattern": "^[0-9]+$"}`,
+			check:  func(t *testing.T, value string) { assert.Equal(t, "from-pattern", value) },
+		},
+		{
+			name:   "pattern with unknown format",
+			schema: `{"type": "string", "format": "custom", "pattern": "^x$"}`,
+			check:  func(t *testing.T, value string) { assert.Equal(t, "from-pattern", value) },
+		},
+		{
+			name:   "plain",
+			schema: `{"type": "string", "minLength": 2, "maxLength": 4}`,
+			check: func(t *testing.T, value string) {
+				assert.GreaterOrEqual(t, len([]rune(value)), 2)
+				assert.LessOrEqual(t, len([]rune(value)), 4)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := opts.GenFromSchema(mustSchema(t, tt.schema))
+			rapid.Check(t, func(rt *rapid.T) {
+				var value string
+				require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+				tt.check(t, value)
+			})
+		})
+	}
+
+	conflicting := opts.GenFromSchema(mustSchema(t, `{"type": "string", "enum": ["a"], "const": "b"}`))
+	assert.Panics(t, func() { conflicting.Example() })
+}
+
+func TestStringAlphabet(t *testing.T) {
+	var printable strings.Builder
+	for r := ' '; r <= '~'; r++ {
+		printable.WriteRune(r)
+	}
+	opts := NewGenerationOptions().WithStringAlphabet(printable.String())
+	gen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "minLength": 3, "maxLength": 12}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var value string
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+		assert.Regexp(t, `^[ -~]{3,12}$`, value)
+	})
+}
+
+func TestLengthSemantics(t *testing.T) {
+	schema := mustSchema(t, `{"type": "string", "minLength": 2, "maxLength": 5}`)
+	measures := map[LengthSemantics]func(string) int{
+		LengthUTF16: func(s string) int { return len(utf16.Encode([]rune(s))) },
+		LengthBytes: func(s string) int { return len(s) },
+	}
+	for semantics, measure := range measures {
+		gen := NewGenerationOptions().WithLengthSemantics(semantics).GenFromSchema(schema)
+		rapid.Check(t, func(rt *rapid.T) {
+			var value string
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+			assert.GreaterOrEqual(t, measure(value), 2, value)
+			assert.LessOrEqual(t, measure(value), 5, value)
+		})
+	}
+
+	// astral characters count twice in UTF-16, so at most two fit into 5 units
+	gen := NewGenerationOptions().WithLengthSemantics(LengthUTF16).WithStringAlphabet("😀").
+		GenFromSchema(mustSchema(t, `{"type": "string", "maxLength": 5}`))
+	rapid.Check(t, func(rt *rapid.T) {
+		var value string
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+		assert.LessOrEqual(t, utf8.RuneCountInString(value), 2, value)
+	})
+
+	// an odd minLength is passed by one unit rather than missed
+	odd := mustSchema(t, `{"type": "string", "minLength": 3, "maxLength": 6}`)
+	oddGen := NewGenerationOptions().WithLengthSemantics(LengthUTF16).WithStringAlphabet("😀").GenFromSchema(odd)
+	rapid.Check(t, func(rt *rapid.T) {
+		var value string
+		require.NoError(t, json.Unmarshal(oddGen.Draw(rt, "value"), &value))
+		assert.Contains(t, []int{2, 3}, utf8.RuneCountInString(value), value)
+	})
+
+	// no string of astral characters has 3 units
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), `no string of the alphabet "😀" has a length in [3, 3]`)
+	}()
+	NewGenerationOptions().WithLengthSemantics(LengthUTF16).WithStringAlphabet("😀").
+		GenFromSchema(mustSchema(t, `{"type": "string", "minLength": 3, "maxLength": 3}`)).Example(0)
+}
+
+func TestNumericStringPattern(t *testing.T) {
+	opts := NewGenerationOptions().WithPatternFunc(rapidPatternFunc)
+
+	t.Run("pattern bounds digits", func(t *testing.T) {
+		gen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^[0-9]{1,18}$"}`))
+		rapid.Check(t, func(rt *rapid.T) {
+			var value string
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+			assert.Regexp(t, `^[0-9]{1,18}$`, value)
+		})
+	})
+
+	t.Run("maxLength narrows the pattern", func(t *testing.T) {
+		gen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^[0-9]{1,18}$", "minLength": 4, "maxLength": 10}`))
+		rapid.Check(t, func(rt *rapid.T) {
+			var value string
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+			assert.Regexp(t, `^[0-9]{4,10}$`, value)
+		})
+	})
+}
+
+func TestAnyOfOverlappingRefs(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: anyOf
+  version: 1.0.0
+paths:
+  /contacts:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              anyOf:
+                - $ref: '#/components/schemas/Person'
+                - $ref: '#/components/schemas/Company'
+      responses:
+        '200':
+          description: ok
+components:
+  schemas:
+    Person:
+      type: object
+      required: [id, name]
+      additionalProperties: false
+      properties:
+        id:
+          type: string
+          maxLength: 8
+        name:
+          type: string
+    Company:
+      type: object
+      required: [id, vat]
+      properties:
+        id:
+          type: integer
+        vat:
+          type: string
+`)
+	op := doc.Paths.Value("/contacts").Post
+	schema, ok := GetSchema(op)
+	require.True(t, ok)
+	gen := NewGenerationOptions().GenFromSchema(schema.Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		assert.NoError(t, ValidatePayload(rt.Context(), payload, "/contacts", op), string(payload))
+	})
+}
+
+func TestTrace(t *testing.T) {
+	var log strings.Builder
+	opts := NewGenerationOptions().WithTrace(&log)
+	gen := opts.GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"required": ["shape"],
+		"properties": {
+			"shape": {
+				"oneOf": [
+					{"type": "string", "format": "email"},
+					{"type": "string", "maxLength": 3}
+				]
+			}
+		}
+	}`))
+
+	gen.Example(0)
+	gen.Example(1)
+
+	assert.Equal(t, 2, strings.Count(log.String(), "draw:\n"), log.String())
+	assert.Regexp(t, `(?m)^  prop-shape: $`, log.String())
+	assert.Regexp(t, `(?m)^    OneOf-Choice: [01]$`, log.String())
+	assert.Regexp(t, `(?m)^      (email|string): `, log.String())
+}
+
+func TestGenerationTimeout(t *testing.T) {
+	slowPattern := func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+		time.Sleep(time.Millisecond)
+		return rapid.Just("slow").Draw(t, "slow")
+	}
+	schema := mustSchema(t, `{
+		"type": "array",
+		"minItems": 1000,
+		"items": {"type": "string", "pattern": "^slow$"}
+	}`)
+	opts := NewGenerationOptions().WithPatternFunc(slowPattern).WithGenerationTimeout(10 * time.Millisecond)
+	gen := opts.GenFromSchema(schema)
+
+	start := time.Now()
+	rapid.Check(t, func(rt *rapid.T) {
+		defer func() {
+			assert.Contains(t, fmt.Sprint(recover()), "generation timed out after 10ms at '/items'")
+		}()
+		gen.Draw(rt, "slow")
+	})
+	// without the timeout, every draw takes a second
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	// a single draw that ends past its deadline fails as well
+	sleepy := NewGenerationOptions().WithGenerationTimeout(10 * time.Millisecond).
+		WithPatternFunc(func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+			time.Sleep(300 * time.Millisecond)
+			return rapid.Just("slow").Draw(t, "slow")
+		}).
+		GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^slow$"}`))
+	rapid.Custom(func(rt *rapid.T) any {
+		defer func() {
+			assert.Contains(t, fmt.Sprint(recover()), "generation timed out after 10ms")
+		}()
+		return sleepy.Draw(rt, "sleepy")
+	}).Example(0)
+
+	// concurrent draws each have their own deadline: the slow draw times out, though
+	// the quick draw that started before it ends in time
+	var calls atomic.Int32
+	shared := NewGenerationOptions().WithGenerationTimeout(50 * time.Millisecond).
+		WithPatternFunc(func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+			if calls.Add(1) == 1 {
+				time.Sleep(20 * time.Millisecond)
+			} else {
+				time.Sleep(200 * time.Millisecond)
+			}
+			return rapid.Just("slow").Draw(t, "slow")
+		}).
+		GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^slow$"}`))
+	draw := func() (err any) {
+		rapid.Custom(func(rt *rapid.T) any {
+			defer func() { err = recover() }()
+			return shared.Draw(rt, "shared")
+		}).Example(0)
+		return err
+	}
+	quick := make(chan any)
+	go func() { quick <- draw() }()
+	time.Sleep(5 * time.Millisecond)
+	assert.Contains(t, fmt.Sprint(draw()), "generation timed out after 50ms")
+	assert.Nil(t, <-quick)
+
+	// fast draws are unaffected, each draw gets its own deadline
+	fast := NewGenerationOptions().WithGenerationTimeout(time.Second).GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {"id": {"type": "integer"}, "tags": {"type": "array", "items": {"type": "string"}}}
+	}`))
+	for seed := 0; seed < 20; seed++ {
+		assert.Contains(t, string(fast.Example(seed)), `"id"`)
+	}
+}
+
+func TestUniqueItemsOfObjects(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "array",
+		"uniqueItems": true,
+		"minItems": 3,
+		"maxItems": 4,
+		"items": {
+			"type": "object",
+			"required": ["kind", "on"],
+			"additionalProperties": false,
+			"properties": {
+				"kind": {"type": "string", "enum": ["a", "b"]},
+				"on": {"type": "boolean"}
+			}
+		}
+	}`)
+	gen := NewGenerationOptions().GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var items []any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "array"), &items))
+		assert.NoError(t, schema.VisitJSON(items))
+	})
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	assert.Equal(t, canonicalJSON(json.RawMessage(`{"b":1.0,"a":[true]}`)), canonicalJSON(json.RawMessage(`{"a":[true],"b":1}`)))
+}
+
+func TestXNullable(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: x-nullable
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Name:
+      type: string
+      x-nullable: true
+`)
+	gen := NewGenerationOptions().GenFromSchema(doc.Components.Schemas["Name"].Value)
+
+	sawNull := false
+	for i := range 50 {
+		value := gen.Example(i)
+		if string(value) == "null" {
+			sawNull = true
+			continue
+		}
+		var s string
+		assert.NoError(t, json.Unmarshal(value, &s))
+	}
+	assert.True(t, sawNull, "x-nullable must generate null")
+}
+
+func TestBigIntegers(t *testing.T) {
+	opts := NewGenerationOptions().WithBigIntegers(true)
+	unbounded := opts.GenFromSchema(mustSchema(t, `{"type": "integer"}`))
+	bounded := opts.GenFromSchema(mustSchema(t, `{"type": "integer", "format": "int64"}`))
+
+	sawBig := false
+	for i := range 100 {
+		var n big.Int
+		require.NoError(t, n.UnmarshalJSON(unbounded.Example(i)))
+		if !n.IsInt64() {
+			sawBig = true
+		}
+
+		var m int64
+		assert.NoError(t, json.Unmarshal(bounded.Example(i), &m))
+	}
+	assert.True(t, sawBig, "expected integers beyond int64")
+}
+
+func TestInteger53BitSafe(t *testing.T) {
+	opts := NewGenerationOptions().WithInteger53BitSafe(true).WithBigIntegers(true)
+	safe := opts.GenFromSchema(mustSchema(t, `{"type": "integer", "format": "int64"}`))
+	aboveMinimum := opts.GenFromSchema(mustSchema(t, `{"type": "integer", "minimum": -10}`))
+	explicit := opts.GenFromSchema(mustSchema(t, `{"type": "integer", "minimum": 9007199254740993}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var n int64
+		require.NoError(t, json.Unmarshal(safe.Draw(rt, "safe"), &n))
+		assert.LessOrEqual(t, n, int64(1<<53-1))
+		assert.GreaterOrEqual(t, n, int64(-(1<<53 - 1)))
+
+		require.NoError(t, json.Unmarshal(aboveMinimum.Draw(rt, "aboveMinimum"), &n))
+		assert.LessOrEqual(t, n, int64(1<<53-1))
+		assert.GreaterOrEqual(t, n, int64(-10))
+
+		// the schema's own bound beyond the safe range is kept
+		require.NoError(t, json.Unmarshal(explicit.Draw(rt, "explicit"), &n))
+		assert.GreaterOrEqual(t, n, int64(9007199254740993))
+	})
+}
+
+func TestResetClearsEnumCoverage(t *testing.T) {
+	opts := NewGenerationOptions().WithEnumCoverage(true)
+	first := mustSchema(t, `{"type": "string", "enum": ["a", "b"]}`)
+	second := mustSchema(t, `{"type": "string", "enum": ["a", "b"]}`)
+
+	firstGen := opts.GenFromSchema(first)
+	firstGen.Example(0)
+	firstGen.Example(1)
+	require.Len(t, opts.enumCoverage.seen[first], 2)
+
+	opts.Reset()
+	assert.Empty(t, opts.enumCoverage.seen, "no state of the first spec is kept")
+
+	// generators built before and after Reset use the fresh state
+	opts.GenFromSchema(second).Example(0)
+	_, kept := opts.enumCoverage.seen[first]
+	assert.False(t, kept)
+	assert.Len(t, opts.enumCoverage.seen[second], 1)
+	firstGen.Example(2)
+	assert.Len(t, opts.enumCoverage.seen[first], 1)
+}
+
+func TestLargeIntegerBounds(t *testing.T) {
+	// both bounds parse to float64s that are off by one from what is written
+	narrow := NewGenerationOptions().GenFromSchema(mustSchema(t, `{"type": "integer", "minimum": 9007199254740993, "maximum": 9007199254740995}`))
+	wide := NewGenerationOptions().GenFromSchema(mustSchema(t, `{"type": "integer", "minimum": -5, "maximum": 9223372036854775807}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var n int64
+		require.NoError(t, json.Unmarshal(narrow.Draw(rt, "narrow"), &n))
+		assert.GreaterOrEqual(t, n, int64(9007199254740993))
+		assert.LessOrEqual(t, n, int64(9007199254740995))
+
+		require.NoError(t, json.Unmarshal(wide.Draw(rt, "wide"), &n))
+		assert.GreaterOrEqual(t, n, int64(-5))
+	})
+}
+
+func TestStringEncodedIntegers(t *testing.T) {
+	opts := NewGenerationOptions().WithPatternFunc(rapidPatternFunc)
+	int64Gen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "int64"}`))
+	uint64Gen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "uint64"}`))
+	positiveGen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "int64", "pattern": "^[1-9][0-9]*$"}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var s string
+		require.NoError(t, json.Unmarshal(int64Gen.Draw(rt, "int64"), &s))
+		_, err := strconv.ParseInt(s, 10, 64)
+		assert.NoError(t, err)
+
+		require.NoError(t, json.Unmarshal(uint64Gen.Draw(rt, "uint64"), &s))
+		_, err = strconv.ParseUint(s, 10, 64)
+		assert.NoError(t, err)
+
+		require.NoError(t, json.Unmarshal(positiveGen.Draw(rt, "positive"), &s))
+		assert.Regexp(t, `^[1-9][0-9]*$`, s)
+	})
+
+	// lengths bound the digits, and values of the PatternFunc stay in range
+	short := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "int64", "maxLength": 3}`))
+	long := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "uint64", "minLength": 20}`))
+	patterned := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "int64", "pattern": "^[0-9]{19}$"}`))
+	var sawNegative bool
+	rapid.Check(t, func(rt *rapid.T) {
+		var s string
+		require.NoError(t, json.Unmarshal(short.Draw(rt, "short"), &s))
+		assert.LessOrEqual(t, len(s), 3)
+		n, err := strconv.ParseInt(s, 10, 64)
+		assert.NoError(t, err)
+		sawNegative = sawNegative || n < 0
+
+		require.NoError(t, json.Unmarshal(long.Draw(rt, "long"), &s))
+		assert.Len(t, s, 20)
+		_, err = strconv.ParseUint(s, 10, 64)
+		assert.NoError(t, err)
+
+		require.NoError(t, json.Unmarshal(patterned.Draw(rt, "patterned"), &s))
+		assert.Regexp(t, `^[0-9]{19}$`, s)
+		_, err = strconv.ParseInt(s, 10, 64)
+		assert.NoError(t, err)
+	})
+	assert.True(t, sawNegative, "no short int64 was negative")
+
+	func() {
+		defer func() {
+			assert.Contains(t, fmt.Sprint(recover()), "no int32 value has a length in [12, -1] at ''")
+		}()
+		opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "int32", "minLength": 12}`)).Example(0)
+	}()
+}
+
+func TestPropertyOrder(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: order
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Signed:
+      type: object
+      required: [zeta, alpha, mid, nested]
+      additionalProperties: false
+      properties:
+        zeta:
+          type: string
+        alpha:
+          $ref: '#/components/schemas/Amount'
+        mid:
+          type: boolean
+        nested:
+          type: object
+          required: [y, x]
+          additionalProperties: false
+          properties:
+            y:
+              type: integer
+            x:
+              type: integer
+    Amount:
+      type: integer
+`)
+	gen := NewGenerationOptions().WithPropertyOrder(true).GenFromSchema(doc.Components.Schemas["Signed"].Value)
+	keysRe := regexp.MustCompile(`"(zeta|alpha|mid|nested|y|x)":`)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := gen.Draw(rt, "payload")
+		var keys []string
+		for _, m := range keysRe.FindAllStringSubmatch(string(payload), -1) {
+			keys = append(keys, m[1])
+		}
+		assert.Equal(t, []string{"zeta", "alpha", "mid", "nested", "y", "x"}, keys, string(payload))
+
+		var obj map[string]any
+		assert.NoError(t, json.Unmarshal(payload, &obj))
+	})
+}
+
+func TestEnumWithNull(t *testing.T) {
+	plain := NewGenerationOptions().GenFromSchema(mustSchema(t, `{"type": "string", "enum": ["a", "b", null]}`))
+	nullable := NewGenerationOptions().GenFromSchema(mustSchema(t, `{"type": "string", "nullable": true, "enum": ["a", "b", null]}`))
+
+	nulls := 0
+	for i := range 300 {
+		value := nullable.Example(i)
+		// nullable does not add null on top of the enum's own null member
+		assert.Equal(t, string(plain.Example(i)), string(value))
+		assert.Contains(t, []string{`"a"`, `"b"`, `null`}, string(value))
+		if string(value) == "null" {
+			nulls++
+		}
+	}
+	assert.Positive(t, nulls)
+	assert.Less(t, nulls, 200, "null is one enum member of three")
+}
+
+func TestEnumMembersRoundTrip(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: enum escaping
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Label:
+      type: string
+      enum:
+        - 'a"b'
+        - "line\nbreak\ttab"
+        - 'back\slash'
+        - "caf\u00e9 \u2603 \U0001F600"
+        - 'R&D <draft>'
+`)
+	schema := doc.Components.Schemas["Label"].Value
+	gen := NewGenerationOptions().WithEnumCoverage(true).GenFromSchema(schema)
+
+	seen := make(map[string]bool)
+	for i := range 20 {
+		seen[string(gen.Example(i))] = true
+	}
+	assert.Equal(t, map[string]bool{
+		`"a\"b"`:             true,
+		`"line\nbreak\ttab"`: true,
+		`"back\\slash"`:      true,
+		`"café ☃ 😀"`:         true,
+		`"R&D <draft>"`:      true,
+	}, seen)
+
+	for encoded := range seen {
+		var decoded string
+		require.NoError(t, json.Unmarshal([]byte(encoded), &decoded))
+		assert.Contains(t, schema.Enum, decoded)
+	}
+}
+
+func TestRetryLimit(t *testing.T) {
+	calls := 0
+	opts := NewGenerationOptions().WithRetryLimit(3).WithPatternFunc(func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+		calls++
+		return rapid.SampledFrom([]string{"ab"}).Draw(t, "pattern")
+	})
+	gen := opts.GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^[a-z]+$", "minLength": 5}`))
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "could not satisfy pattern '^[a-z]+$' with length in [5, -1] after 3 attempts")
+		// Example tries every draw several times, each giving up after 3 calls
+		assert.Zero(t, calls%3)
+	}()
+	gen.Example()
+}
+
+func TestColorFormat(t *testing.T) {
+	opts := NewGenerationOptions().WithPatternFunc(rapidPatternFunc)
+	color := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "color"}`))
+	opaque := opts.GenFromSchema(mustSchema(t, `{"type": "string", "format": "hex-color", "pattern": "^#[0-9a-f]{6}$"}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var s string
+		require.NoError(t, json.Unmarshal(color.Draw(rt, "color"), &s))
+		assert.Regexp(t, `^#([0-9a-f]{3}|[0-9a-f]{6}|[0-9a-f]{8})$`, s)
+
+		require.NoError(t, json.Unmarshal(opaque.Draw(rt, "opaque"), &s))
+		assert.Regexp(t, `^#[0-9a-f]{6}$`, s)
+	})
+}
+
+func TestOneOfExactlyOneRequired(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {
+			"id": {"type": "integer"},
+			"email": {"type": "string"},
+			"phone": {"type": "string"}
+		},
+		"oneOf": [
+			{"required": ["email"]},
+			{"required": ["phone"]}
+		]
+	}`)
+	gen := NewGenerationOptions().GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		assert.NoError(t, schema.VisitJSON(obj))
+
+		_, email := obj["email"]
+		_, phone := obj["phone"]
+		assert.True(t, email != phone, "exactly one of email and phone: %v", obj)
+		assert.Contains(t, obj, "id")
+	})
+}
+
+func TestAssertOneOfExclusive(t *testing.T) {
+	schema := mustSchema(t, `{
+		"oneOf": [
+			{"type": "object", "required": ["email"]},
+			{"type": "object", "required": ["phone"]},
+			{"type": "object", "properties": {"kind": {"const": "anonymous"}}, "required": ["kind"]}
+		]
+	}`)
+
+	assert.NoError(t, AssertOneOfExclusive(schema, json.RawMessage(`{"email": "a@b.c"}`)))
+	assert.NoError(t, AssertOneOfExclusive(schema, json.RawMessage(`{"kind": "anonymous"}`)))
+	assert.ErrorContains(t, AssertOneOfExclusive(schema, json.RawMessage(`{"email": "a@b.c", "phone": "1"}`)), "matches 2: [0 1]")
+	assert.ErrorContains(t, AssertOneOfExclusive(schema, json.RawMessage(`{"kind": "known"}`)), "matches 0")
+	assert.Error(t, AssertOneOfExclusive(mustSchema(t, `{"type": "object"}`), json.RawMessage(`{}`)))
+
+	// generated payloads of the exactly-one-of idiom are exclusive
+	idiom := mustSchema(t, `{
+		"type": "object",
+		"properties": {"email": {"type": "string"}, "phone": {"type": "string"}},
+		"oneOf": [{"required": ["email"]}, {"required": ["phone"]}]
+	}`)
+	gen := GenFromSchema(idiom)
+	rapid.Check(t, func(rt *rapid.T) {
+		assert.NoError(t, AssertOneOfExclusive(idiom, gen.Draw(rt, "payload")))
+	})
+}
+
+func TestMaxContainsBelowMinItems(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "array",
+		"items": {"type": "string"},
+		"minItems": 3,
+		"maxItems": 3,
+		"contains": {"const": "x"},
+		"maxContains": 1
+	}`)
+	gen := NewGenerationOptions().GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var items []string
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "array"), &items))
+		require.Len(t, items, 3)
+		xs := 0
+		for _, item := range items {
+			if item == "x" {
+				xs++
+			}
+		}
+		assert.Equal(t, 1, xs, items)
+	})
+}
+
+func TestContainsWithUniqueItems(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "array",
+		"uniqueItems": true,
+		"items": {"type": "integer", "minimum": 0, "maximum": 20},
+		"minItems": 5,
+		"contains": {"type": "integer", "minimum": 0, "maximum": 4},
+		"minContains": 3
+	}`)
+	gen := NewGenerationOptions().GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var items []int
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "array"), &items))
+		require.GreaterOrEqual(t, len(items), 5)
+		matching := 0
+		for _, item := range items {
+			if item <= 4 {
+				matching++
+			}
+		}
+		assert.GreaterOrEqual(t, matching, 3, items)
+		assert.Len(t, items, len(slices.Compact(slices.Sorted(slices.Values(items)))), items)
+	})
+}
+
+func TestClosedTuple(t *testing.T) {
+	for _, closing := range []string{`"items": {"not": {}}`, `"additionalItems": false`} {
+		schema := mustSchema(t, `{
+			"type": "array",
+			"prefixItems": [{"type": "string"}, {"type": "integer"}, {"type": "boolean"}],
+			"maxItems": 2,
+			`+closing+`
+		}`)
+		gen := NewGenerationOptions().GenFromSchema(schema)
+
+		rapid.Check(t, func(rt *rapid.T) {
+			var items []any
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "tuple"), &items))
+			require.Len(t, items, 2, closing)
+			assert.IsType(t, "", items[0])
+			assert.IsType(t, float64(0), items[1])
+		})
+	}
+}
+
+func TestOpenTuple(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "array",
+		"prefixItems": [{"type": "string"}],
+		"items": {"type": "boolean"},
+		"minItems": 3
+	}`)
+	gen := NewGenerationOptions().GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var items []any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "tuple"), &items))
+		require.GreaterOrEqual(t, len(items), 3)
+		assert.IsType(t, "", items[0])
+		for _, item := range items[1:] {
+			assert.IsType(t, true, item)
+		}
+	})
+}
+
+func TestClosedTupleBelowMinItems(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "array",
+		"prefixItems": [{"type": "string"}],
+		"items": {"not": {}},
+		"minItems": 2
+	}`)
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "minItems is 2")
+	}()
+	GenFromSchema(schema).Example(0)
+}
+
+func TestInvertedBounds(t *testing.T) {
+	for _, tc := range []struct {
+		schema string
+		want   string
+	}{
+		{`{"type": "array", "items": {"type": "string"}, "minItems": 4, "maxItems": 3}`, "maxItems 3 is less than minItems 4 at '/components/schemas/Bounds'"},
+		{`{"type": "string", "minLength": 5, "maxLength": 2}`, "maxLength 2 is less than minLength 5 at '/components/schemas/Bounds'"},
+	} {
+		func() {
+			defer func() {
+				assert.Contains(t, fmt.Sprint(recover()), tc.want)
+			}()
+			NewGenerationOptions().GenFromSchemaAt("/components/schemas/Bounds", mustSchema(t, tc.schema)).Example(0)
+		}()
+	}
+}
+
+func TestContainsLoadsFromSpec(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: contains
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Tags:
+      type: array
+      items:
+        type: integer
+        minimum: 0
+        maximum: 9
+      contains:
+        type: integer
+        minimum: 7
+      minContains: 2
+`)
+	gen := NewGenerationOptions().GenFromSchema(doc.Components.Schemas["Tags"].Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var items []int
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "array"), &items))
+		large := 0
+		for _, item := range items {
+			assert.LessOrEqual(t, item, 9)
+			if item >= 7 {
+				large++
+			}
+		}
+		assert.GreaterOrEqual(t, large, 2, items)
+	})
+}
+
+func TestTimeFormat(t *testing.T) {
+	strict := regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9](\.[0-9]+)?(Z|[+-]([01][0-9]|2[0-3]):[0-5][0-9])$`)
+	gen := GenFromSchema(mustSchema(t, `{"type": "string", "format": "time"}`))
+
+	var sawFraction, sawUTC, sawOffset bool
+	rapid.Check(t, func(rt *rapid.T) {
+		var value string
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "time"), &value))
+		require.Regexp(t, strict, value)
+		_, err := time.Parse("15:04:05.999999999Z07:00", value)
+		require.NoError(t, err, value)
+
+		sawFraction = sawFraction || strings.Contains(value, ".")
+		sawUTC = sawUTC || strings.HasSuffix(value, "Z")
+		sawOffset = sawOffset || !strings.HasSuffix(value, "Z")
+	})
+	assert.True(t, sawFraction && sawUTC && sawOffset, "fraction %v, utc %v, offset %v", sawFraction, sawUTC, sawOffset)
+}
+
+func TestDurationFormat(t *testing.T) {
+	// RFC 3339 appendix A
+	strict := regexp.MustCompile(`^P(\d+W|((\d+Y(\d+M(\d+D)?)?|\d+M(\d+D)?|\d+D)(T(\d+H(\d+M(\d+S)?)?|\d+M(\d+S)?|\d+S))?|T(\d+H(\d+M(\d+S)?)?|\d+M(\d+S)?|\d+S)))$`)
+	gen := GenFromSchema(mustSchema(t, `{"type": "string", "format": "duration"}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var value string
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "duration"), &value))
+		assert.Regexp(t, strict, value)
+	})
+}
+
+func TestFormatExampleBias(t *testing.T) {
+	const sentinel = "00000000-0000-4000-8000-000000000000"
+	schema := mustSchema(t, `{"type": "string", "format": "uuid", "example": "`+sentinel+`"}`)
+
+	count := func(opts *GenerationOptions, schema *openapi3.Schema) int {
+		gen := opts.GenFromSchema(schema)
+		n := 0
+		for i := 0; i < 200; i++ {
+			if string(gen.Example(i)) == `"`+sentinel+`"` {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Positive(t, count(NewGenerationOptions().WithFormatExampleBias(true), schema))
+	assert.Less(t, count(NewGenerationOptions().WithFormatExampleBias(true), schema), 200)
+	assert.Zero(t, count(NewGenerationOptions(), schema))
+
+	// examples that violate the schema are left out
+	invalid := mustSchema(t, `{"type": "string", "format": "uuid", "maxLength": 10, "example": "`+sentinel+`"}`)
+	assert.Zero(t, count(NewGenerationOptions().WithFormatExampleBias(true), invalid))
+}
+
+func TestFinancialFormats(t *testing.T) {
+	ibanGen := GenFromSchema(mustSchema(t, `{"type": "string", "format": "iban"}`))
+	bicGen := GenFromSchema(mustSchema(t, `{"type": "string", "format": "bic"}`))
+	ibanPattern := regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	bicPattern := regexp.MustCompile(`^[A-Z]{6}[A-Z2-9][A-NP-Z1-9]([A-Z0-9]{3})?$`)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var iban, bic string
+		require.NoError(t, json.Unmarshal(ibanGen.Draw(rt, "iban"), &iban))
+		require.NoError(t, json.Unmarshal(bicGen.Draw(rt, "bic"), &bic))
+		assert.Regexp(t, ibanPattern, iban)
+		assert.True(t, validIBAN(iban), iban)
+		assert.Regexp(t, bicPattern, bic)
+	})
+
+	// published example IBANs
+	assert.True(t, validIBAN("DE89370400440532013000"))
+	assert.True(t, validIBAN("GB82WEST12345698765432"))
+	assert.False(t, validIBAN("DE88370400440532013000"))
+	assert.Equal(t, "89", ibanCheckDigits("DE", "370400440532013000"))
+}
+
+func TestTypelessFormatImpliesString(t *testing.T) {
+	gen := GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"required": ["createdAt", "anything"],
+		"additionalProperties": false,
+		"properties": {
+			"createdAt": {"format": "date-time"},
+			"anything": {"format": "int64"}
+		}
+	}`))
+
+	sawNonString := false
+	rapid.Check(t, func(rt *rapid.T) {
+		var value struct {
+			CreatedAt string `json:"createdAt"`
+			Anything  any    `json:"anything"`
+		}
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+		_, err := time.Parse(time.RFC3339, value.CreatedAt)
+		assert.NoError(t, err, value.CreatedAt)
+		if _, ok := value.Anything.(string); !ok {
+			sawNonString = true
+		}
+	})
+	// int64 is not a string-only format, so it does not imply a type
+	assert.True(t, sawNonString)
+}
+
+func TestJSONPointerFormats(t *testing.T) {
+	gen := GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"required": ["target", "relative"],
+		"properties": {
+			"target": {"type": "string", "format": "json-pointer"},
+			"relative": {"type": "string", "format": "relative-json-pointer"}
+		}
+	}`))
+	pointer := `(/([^/~]|~[01])*)*`
+	pointerPattern := regexp.MustCompile(`^` + pointer + `$`)
+	relativePattern := regexp.MustCompile(`^(0|[1-9][0-9]*)(#|` + pointer + `)$`)
+
+	sawHash, sawTail := false, false
+	rapid.Check(t, func(rt *rapid.T) {
+		var value struct {
+			Target   string `json:"target"`
+			Relative string `json:"relative"`
+		}
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+		assert.Regexp(t, pointerPattern, value.Target)
+		assert.Regexp(t, relativePattern, value.Relative)
+		sawHash = sawHash || strings.HasSuffix(value.Relative, "#")
+		sawTail = sawTail || strings.Contains(value.Relative, "/")
+	})
+	assert.True(t, sawHash)
+	assert.True(t, sawTail)
+}
+
+func TestByteLengthSemantics(t *testing.T) {
+	schema := mustSchema(t, `{"type": "string", "format": "byte", "minLength": 5, "maxLength": 12}`)
+	encoded := GenFromSchema(schema)
+	decoded := NewGenerationOptions().WithByteLengthSemantics(ByteLengthDecoded).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var text string
+		require.NoError(t, json.Unmarshal(encoded.Draw(rt, "encoded"), &text))
+		assert.GreaterOrEqual(t, len(text), 5)
+		assert.LessOrEqual(t, len(text), 12)
+		_, err := base64.StdEncoding.DecodeString(text)
+		assert.NoError(t, err)
+
+		require.NoError(t, json.Unmarshal(decoded.Draw(rt, "decoded"), &text))
+		b, err := base64.StdEncoding.DecodeString(text)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(b), 5)
+		assert.LessOrEqual(t, len(b), 12)
+	})
+
+	// base64 text is a multiple of 4 long
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "no base64 text has a length between minLength 5 and maxLength 7")
+	}()
+	GenFromSchema(mustSchema(t, `{"type": "string", "format": "byte", "minLength": 5, "maxLength": 7}`)).Example(0)
+}
+
+func TestFixedArrayLength(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["tags", "matrix", "pair"],
+		"properties": {
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"matrix": {"type": "array", "maxItems": 5, "items": {"type": "array", "items": {"type": "integer"}}},
+			"pair": {"type": "array", "uniqueItems": true, "minItems": 2, "items": {"type": "integer"}}
+		}
+	}`)
+	gen := NewGenerationOptions().WithFixedArrayLength(3).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj struct {
+			Tags   []string `json:"tags"`
+			Matrix [][]int  `json:"matrix"`
+			Pair   []int    `json:"pair"`
+		}
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		assert.Len(t, obj.Tags, 3)
+		assert.Len(t, obj.Pair, 3)
+		require.Len(t, obj.Matrix, 3)
+		for _, row := range obj.Matrix {
+			assert.Len(t, row, 3)
+		}
+	})
+
+	empty := NewGenerationOptions().WithFixedArrayLength(0).GenFromSchema(mustSchema(t, `{"type": "array", "items": {"type": "string"}}`))
+	assert.Equal(t, "[]", string(empty.Example(0)))
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "fixed array length 6 is outside minItems 0 and maxItems 5")
+	}()
+	NewGenerationOptions().WithFixedArrayLength(6).GenFromSchema(mustSchema(t, `{"type": "array", "maxItems": 5}`)).Example(0)
+}
+
+func TestDependentSchemas(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: dependents
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Payment:
+      type: object
+      required: [amount]
+      properties:
+        amount:
+          type: integer
+          minimum: 0
+          maximum: 1000
+        creditCard:
+          type: string
+        billingAddress:
+          type: string
+        currency:
+          type: string
+      dependentSchemas:
+        creditCard:
+          required: [billingAddress, cvc]
+          properties:
+            amount:
+              minimum: 1
+            cvc:
+              type: string
+              pattern: "^[0-9]{3}$"
+      dependentRequired:
+        billingAddress: [currency]
+`)
+	schema := doc.Components.Schemas["Payment"].Value
+	gen := NewGenerationOptions().WithPatternFunc(rapidPatternFunc).GenFromSchema(schema)
+
+	sawCard, sawNoCard := false, false
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "payment"), &obj))
+		if _, ok := obj["billingAddress"]; ok {
+			assert.Contains(t, obj, "currency", "dependentRequired of billingAddress")
+		}
+		if _, ok := obj["creditCard"]; !ok {
+			sawNoCard = true
+			return
+		}
+		sawCard = true
+		assert.Contains(t, obj, "billingAddress")
+		assert.Contains(t, obj, "currency", "required by billingAddress, which creditCard requires")
+		assert.Regexp(t, `^[0-9]{3}$`, obj["cvc"])
+		assert.GreaterOrEqual(t, obj["amount"], 1.0)
+	})
+	assert.True(t, sawCard)
+	assert.True(t, sawNoCard)
+
+	// an object property refined with a required property is generated from the merge
+	refined := mustSchema(t, `{
+		"type": "object",
+		"required": ["gift", "wrapping"],
+		"properties": {
+			"gift": {"type": "boolean"},
+			"wrapping": {"type": "object", "properties": {"color": {"type": "string"}}}
+		},
+		"dependentSchemas": {
+			"gift": {"properties": {"wrapping": {"type": "object", "required": ["message"], "properties": {"message": {"type": "string"}}}}}
+		}
+	}`)
+	refinedGen := GenFromSchema(refined)
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj struct {
+			Wrapping map[string]any `json:"wrapping"`
+		}
+		require.NoError(t, json.Unmarshal(refinedGen.Draw(rt, "order"), &obj))
+		assert.IsType(t, "", obj.Wrapping["message"])
+	})
+
+	// properties required by dependentSchemas count towards maxProperties
+	bounded := mustSchema(t, `{
+		"type": "object",
+		"maxProperties": 2,
+		"additionalProperties": true,
+		"properties": {"a": {"type": "integer"}, "b": {"type": "integer"}},
+		"dependentSchemas": {"a": {"required": ["b"]}}
+	}`)
+	boundedGen := GenFromSchema(bounded)
+	sawDependent := false
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(boundedGen.Draw(rt, "bounded"), &obj))
+		assert.NoError(t, bounded.VisitJSON(obj))
+		if _, ok := obj["a"]; ok {
+			sawDependent = true
+			assert.Contains(t, obj, "b")
+		}
+	})
+	assert.True(t, sawDependent, "no object had a")
+}
+
+func TestEmbeddedJSON(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: embedded
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Event:
+      type: object
+      required: [payload, settings, raw]
+      properties:
+        payload:
+          type: string
+          contentMediaType: application/json
+          contentSchema:
+            type: object
+            required: [id]
+            properties:
+              id:
+                type: integer
+                minimum: 1
+        settings:
+          type: string
+          format: json
+        raw:
+          type: string
+          contentMediaType: application/json
+          contentEncoding: base64
+`)
+	gen := GenFromSchema(doc.Components.Schemas["Event"].Value)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var event struct {
+			Payload  string `json:"payload"`
+			Settings string `json:"settings"`
+			Raw      string `json:"raw"`
+		}
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "event"), &event))
+
+		var payload struct {
+			ID *int `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(event.Payload), &payload), event.Payload)
+		require.NotNil(t, payload.ID, event.Payload)
+		assert.GreaterOrEqual(t, *payload.ID, 1)
+
+		assert.True(t, json.Valid([]byte(event.Settings)), event.Settings)
+
+		raw, err := base64.StdEncoding.DecodeString(event.Raw)
+		require.NoError(t, err)
+		assert.True(t, json.Valid(raw), string(raw))
+	})
+
+	// the document text keeps to the length bounds of the string
+	bounded := mustSchema(t, `{"type": "string", "format": "json", "minLength": 2, "maxLength": 5}`)
+	boundedGen := GenFromSchema(bounded)
+	rapid.Check(t, func(rt *rapid.T) {
+		var text string
+		payload := boundedGen.Draw(rt, "bounded")
+		require.NoError(t, json.Unmarshal(payload, &text))
+		assert.True(t, json.Valid([]byte(text)), text)
+		assert.True(t, validAgainst(bounded, payload), text)
+	})
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "no JSON document fits maxLength 3")
+	}()
+	GenFromSchema(mustSchema(t, `{"type": "string", "contentMediaType": "application/json", "contentEncoding": "base64", "maxLength": 3}`)).Example(0)
+}
+
+func TestPropertyCountWindow(t *testing.T) {
+	schemas := []string{
+		`{"type": "object", "minProperties": 3, "maxProperties": 5, "additionalProperties": true,
+			"properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`,
+		`{"type": "object", "minProperties": 3, "maxProperties": 5, "additionalProperties": false,
+			"properties": {"a": {"type": "string"}}, "patternProperties": {"^x-[a-z]{1,4}$": {"type": "integer"}}}`,
+		`{"type": "object", "minProperties": 3, "maxProperties": 5, "required": ["a"],
+			"properties": {"a": {"type": "string"}, "b": {"type": "string"}, "c": {"type": "string"},
+				"d": {"type": "string"}, "e": {"type": "string"}, "f": {"type": "string"}, "g": {"type": "string"}}}`,
+	}
+	opts := NewGenerationOptions().WithPatternFunc(rapidPatternFunc)
+	for _, raw := range schemas {
+		gen := opts.GenFromSchema(mustSchema(t, raw))
+		rapid.Check(t, func(rt *rapid.T) {
+			var obj map[string]any
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+			assert.GreaterOrEqual(t, len(obj), 3, raw)
+			assert.LessOrEqual(t, len(obj), 5, raw)
+		})
+	}
+
+	onlyRequired := GenFromSchema(mustSchema(t, `{"type": "object", "maxProperties": 1, "required": ["a"],
+		"properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`))
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(onlyRequired.Draw(rt, "object"), &obj))
+		assert.Len(t, obj, 1)
+		assert.Contains(t, obj, "a")
+	})
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "maxProperties is 1, but the object requires 2 properties")
+	}()
+	GenFromSchema(mustSchema(t, `{"type": "object", "maxProperties": 1, "required": ["a", "b"],
+		"properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`)).Example(0)
+}
+
+func TestPropertyNames(t *testing.T) {
+	enumGen := GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"minProperties": 2,
+		"properties": {"name": {"type": "string"}},
+		"additionalProperties": {"type": "integer"},
+		"propertyNames": {"enum": ["name", "red", "green", "blue"]}
+	}`))
+	sawExtra := false
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(enumGen.Draw(rt, "object"), &obj))
+		assert.GreaterOrEqual(t, len(obj), 2)
+		for key, value := range obj {
+			assert.Contains(t, []string{"name", "red", "green", "blue"}, key)
+			if key != "name" {
+				sawExtra = true
+				assert.IsType(t, 0.0, value, key)
+			}
+		}
+	})
+	assert.True(t, sawExtra)
+
+	lengthGen := GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"minProperties": 1,
+		"additionalProperties": true,
+		"propertyNames": {"minLength": 2, "maxLength": 3}
+	}`))
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(lengthGen.Draw(rt, "object"), &obj))
+		for key := range obj {
+			assert.True(t, utf8.RuneCountInString(key) >= 2 && utf8.RuneCountInString(key) <= 3, key)
+		}
+	})
+
+	// short keys collide, and are drawn again until minProperties distinct keys exist
+	short := mustSchema(t, `{
+		"type": "object",
+		"minProperties": 4,
+		"additionalProperties": true,
+		"propertyNames": {"maxLength": 1}
+	}`)
+	shortGen := GenFromSchema(short)
+	rapid.Check(t, func(rt *rapid.T) {
+		payload := shortGen.Draw(rt, "object")
+		assert.True(t, validAgainst(short, payload), string(payload))
+	})
+
+	// a single possible key cannot reach minProperties 2
+	func() {
+		defer func() {
+			assert.Contains(t, fmt.Sprint(recover()), "could not satisfy 2 distinct undeclared property names for minProperties 2")
+		}()
+		GenFromSchema(mustSchema(t, `{
+			"type": "object",
+			"minProperties": 2,
+			"additionalProperties": true,
+			"propertyNames": {"maxLength": 0}
+		}`)).Example(0)
+	}()
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "propertyNames only allows 1 undeclared names")
+	}()
+	GenFromSchema(mustSchema(t, `{
+		"type": "object",
+		"minProperties": 3,
+		"properties": {"name": {"type": "string"}},
+		"additionalProperties": true,
+		"propertyNames": {"enum": ["name", "red"]}
+	}`)).Example(0)
+}
+
+func TestMinPropertiesSelectsOptionals(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"additionalProperties": false,
+		"minProperties": 4,
+		"required": ["a", "b"],
+		"properties": {
+			"a": {"type": "string"},
+			"b": {"type": "string"},
+			"c": {"type": "string"},
+			"d": {"type": "string"},
+			"e": {"type": "string"},
+			"f": {"type": "string"},
+			"g": {"type": "string"}
+		}
+	}`)
+	gen := GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		assert.GreaterOrEqual(t, len(obj), 4)
+		require.NoError(t, schema.VisitJSON(obj))
+	})
+}
+
+func TestMinPropertiesUsesAdditionalProperties(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"additionalProperties": {"type": "integer"},
+		"minProperties": 3,
+		"properties": {"a": {"type": "string"}}
+	}`)
+	// at the depth cutoff, additional properties are only generated for minProperties
+	opts := NewGenerationOptions()
+	opts.MaxDepth = 0
+	gen := opts.GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		assert.GreaterOrEqual(t, len(obj), 3)
+		require.NoError(t, schema.VisitJSON(obj))
+	})
+}
+
+func TestMapMinEntries(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"additionalProperties": {"type": "string"}
+	}`)
+	withProps := mustSchema(t, `{
+		"type": "object",
+		"additionalProperties": {"type": "string"},
+		"properties": {"a": {"type": "string"}}
+	}`)
+	opts := NewGenerationOptions().WithMapMinEntries(2)
+	gen := opts.GenFromSchema(schema)
+	withPropsGen := opts.GenFromSchema(withProps)
+
+	var sawEmpty bool
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "map"), &obj))
+		assert.GreaterOrEqual(t, len(obj), 2)
+		require.NoError(t, schema.VisitJSON(obj))
+
+		// objects with declared properties are no maps
+		var other map[string]any
+		require.NoError(t, json.Unmarshal(withPropsGen.Draw(rt, "object"), &other))
+		sawEmpty = sawEmpty || len(other) == 0
+	})
+	assert.True(t, sawEmpty)
+}
+
+func TestRequiredUndeclaredProperties(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["x-count", "label", "id"],
+		"properties": {"id": {"type": "string"}},
+		"patternProperties": {"^x-": {"type": "integer", "minimum": 1}},
+		"additionalProperties": {"type": "boolean"}
+	}`)
+	gen := NewGenerationOptions().WithPatternFunc(rapidPatternFunc).GenFromSchema(schema)
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "object"), &obj))
+		require.Contains(t, obj, "x-count")
+		count, ok := obj["x-count"].(float64)
+		assert.True(t, ok && count >= 1, "x-count follows its patternProperties schema: %v", obj["x-count"])
+		assert.IsType(t, true, obj["label"])
+		assert.IsType(t, "", obj["id"])
+	})
+
+	closed := mustSchema(t, `{
+		"type": "object",
+		"required": ["label"],
+		"properties": {"id": {"type": "string"}},
+		"additionalProperties": false
+	}`)
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "required property 'label' is not declared")
+	}()
+	GenFromSchema(closed).Example(0)
+}
+
+func TestMinPropertiesUnreachable(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"additionalProperties": false,
+		"minProperties": 3,
+		"properties": {"a": {"type": "string"}, "b": {"type": "string"}}
+	}`)
+
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "minProperties is 3")
+	}()
+	GenFromSchema(schema).Example(0)
+}
+
+func TestOneOfWithNullBranch(t *testing.T) {
+	schema := mustSchema(t, `{
+		"oneOf": [
+			{"type": "object", "required": ["name"], "additionalProperties": false, "properties": {"name": {"type": "string"}}},
+			{"type": "null"}
+		]
+	}`)
+	gen := GenFromSchema(schema)
+
+	var sawNull, sawObject bool
+	rapid.Check(t, func(rt *rapid.T) {
+		var value any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "value"), &value))
+		require.NoError(t, schema.VisitJSON(value))
+		if value == nil {
+			sawNull = true
+		} else {
+			assert.Contains(t, value, "name")
+			sawObject = true
+		}
+	})
+	assert.True(t, sawNull, "the null branch was never generated")
+	assert.True(t, sawObject, "the object branch was never generated")
+}
+
+func TestCapRepetitions(t *testing.T) {
+	for pattern, want := range map[string]string{
+		`.*`:             `.{0,8}`,
+		`^a+b*$`:         `^a{1,8}b{0,8}$`,
+		`x{2,}y{20,}`:    `x{2,8}y{20,20}`,
+		`a{2,5}b?`:       `a{2,5}b?`,
+		`\d+\*\+`:        `\d{1,8}\*\+`,
+		`[*+]+`:          `[*+]{1,8}`,
+		`[]*]*`:          `[]*]{0,8}`,
+		`[^]+]+(?:ab)*?`: `[^]+]{1,8}(?:ab){0,8}?`,
+		`*literal-first`: `*literal-first`,
+	} {
+		assert.Equal(t, want, capRepetitions(pattern, 8), pattern)
+	}
+	// + keeps at least one repetition, so the quantifier stays valid
+	assert.Equal(t, `a{1,1}b{0,0}`, capRepetitions(`a+b*`, 0))
+}
+
+func TestPatternRepeatCap(t *testing.T) {
+	opts := NewGenerationOptions().WithPatternFunc(rapidPatternFunc).WithPatternRepeatCap(16)
+	anything := opts.GenFromSchema(mustSchema(t, `{"type": "string", "pattern": ".*"}`))
+	short := opts.GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^a+$", "maxLength": 5}`))
+
+	rapid.Check(t, func(rt *rapid.T) {
+		var s string
+		require.NoError(t, json.Unmarshal(anything.Draw(rt, "anything"), &s))
+		assert.LessOrEqual(t, utf8.RuneCountInString(s), 16)
+
+		require.NoError(t, json.Unmarshal(short.Draw(rt, "short"), &s))
+		assert.Regexp(t, `^a{1,5}$`, s)
+	})
+
+	// the cap is raised to minLength, so a PatternFunc honoring it can reach minLength
+	var patterns []string
+	long := NewGenerationOptions().WithPatternRepeatCap(16).
+		WithPatternFunc(func(pattern string, format string, minLength int, maxLength int, t *rapid.T) string {
+			patterns = append(patterns, pattern)
+			return rapid.StringMatching(`^[a-z]{20}$`).Draw(t, "pattern")
+		}).
+		GenFromSchema(mustSchema(t, `{"type": "string", "pattern": "^[a-z]+$", "minLength": 20}`))
+	assert.Len(t, long.Example(0), 22)
+	assert.Equal(t, []string{`^[a-z]{1,20}$`}, patterns)
+}
+
+// recordingTB lets a rapid.Check fail without failing the test
+type recordingTB struct {
+	*testing.T
+	failed bool
+}
+
+func (tb *recordingTB) Error(args ...any)                 { tb.failed = true }
+func (tb *recordingTB) Errorf(format string, args ...any) { tb.failed = true }
+func (tb *recordingTB) Fatal(args ...any)                 { tb.failed = true }
+func (tb *recordingTB) Fatalf(format string, args ...any) { tb.failed = true }
+func (tb *recordingTB) Fail()                             { tb.failed = true }
+func (tb *recordingTB) FailNow()                          { tb.failed = true }
+func (tb *recordingTB) Failed() bool                      { return tb.failed }
+
+func TestNumberShrinksWithinRange(t *testing.T) {
+	// the checks below fail on purpose, they leave no fail files behind
+	require.NoError(t, flag.Set("rapid.nofailfile", "true"))
+	defer flag.Set("rapid.nofailfile", "false")
+
+	for schema, smallest := range map[string]float64{
+		`{"type": "number", "minimum": 3.25, "maximum": 100}`:                             3.25,
+		`{"type": "number", "minimum": 10.2, "maximum": 1000, "multipleOf": 0.5}`:         10.5,
+		`{"type": "number", "minimum": 0.1, "exclusiveMinimum": true, "multipleOf": 0.1}`: 0.2,
+		`{"type": "number", "minimum": -50, "maximum": -7.5, "multipleOf": 2.5}`:          -7.5,
+	} {
+		s := mustSchema(t, schema)
+		gen := GenFromSchema(s)
+		// kin-openapi rejects some exact multiples of fractions, see Limitations
+		bounds := *s
+		bounds.MultipleOf = nil
+
+		var last float64
+		tb := &recordingTB{T: t}
+		rapid.Check(tb, func(rt *rapid.T) {
+			var v float64
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "number"), &v))
+			// every value tried while shrinking stays within the bounds
+			require.NoError(t, bounds.VisitJSON(v), schema)
+			last = v
+			rt.Fatalf("always fails")
+		})
+		require.True(t, tb.failed)
+		assert.InDelta(t, smallest, last, 1e-9, schema)
+	}
+}
+
+func TestCheckNoShrink(t *testing.T) {
+	// the checks below fail on purpose, they leave no fail files behind
+	require.NoError(t, flag.Set("rapid.nofailfile", "true"))
+	defer flag.Set("rapid.nofailfile", "false")
+
+	gen := GenFromSchema(mustSchema(t, `{"type": "integer", "minimum": 1000, "maximum": 1000000000}`))
+	var drawn []float64
+	tb := &recordingTB{T: t}
+	shrinkTime := flag.Lookup("rapid.shrinktime").Value.String()
+	CheckNoShrink(tb, func(rt *rapid.T) {
+		var v float64
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "number"), &v))
+		drawn = append(drawn, v)
+		if v > 1000 {
+			rt.Fatalf("too large")
+		}
+	})
+	require.True(t, tb.failed)
+	// the failing case is replayed for the report, but never shrunk towards 1001
+	first := slices.IndexFunc(drawn, func(v float64) bool { return v > 1000 })
+	for _, v := range drawn[first:] {
+		assert.Equal(t, drawn[first], v)
+	}
+	assert.Equal(t, shrinkTime, flag.Lookup("rapid.shrinktime").Value.String())
+}
+
+func TestSeedFromString(t *testing.T) {
+	schema := mustSchema(t, `{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}, "name": {"type": "string"}}}`)
+	draws := func(seed string) []string {
+		opts := NewGenerationOptions().WithSeedFromString(seed)
+		gen := opts.GenFromSchema(schema)
+		var payloads []string
+		opts.Check(t, func(rt *rapid.T) {
+			payloads = append(payloads, string(gen.Draw(rt, "payload")))
+		})
+		return payloads
+	}
+
+	first := draws("bug-1234")
+	assert.Equal(t, first, draws("bug-1234"))
+	assert.NotEqual(t, first, draws("bug-1235"))
+	assert.Equal(t, SeedFromString("bug-1234"), NewGenerationOptions().WithSeedFromString("bug-1234").Seed)
+	assert.Equal(t, "0", flag.Lookup("rapid.seed").Value.String())
+}
+
+func TestRecursiveAdditionalProperties(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: recursive
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Tree:
+      type: object
+      additionalProperties:
+        $ref: '#/components/schemas/Tree'
+    NonEmptyTree:
+      type: object
+      minProperties: 1
+      additionalProperties:
+        $ref: '#/components/schemas/NonEmptyTree'
+`)
+	opts := NewGenerationOptions()
+	opts.MaxDepth = 3
+
+	var depth func(v any) int
+	depth = func(v any) int {
+		deepest := 0
+		for _, child := range v.(map[string]any) {
+			deepest = max(deepest, 1+depth(child))
+		}
+		return deepest
+	}
+
+	tree := doc.Components.Schemas["Tree"].Value
+	gen := opts.GenFromSchema(tree)
+	rapid.Check(t, func(rt *rapid.T) {
+		var value any
+		require.NoError(t, json.Unmarshal(gen.Draw(rt, "tree"), &value))
+		assert.LessOrEqual(t, depth(value), opts.MaxDepth)
+		assert.NoError(t, tree.VisitJSON(value))
+	})
+
+	// every level needs a property, so no finite value exists
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "contain the object again, so no finite value exists")
+	}()
+	opts.GenFromSchema(doc.Components.Schemas["NonEmptyTree"].Value).Example(0)
+}
+
+func TestFiniteSchemasPastMaxDepth(t *testing.T) {
+	chain := mustSchema(t, `{"type": "object", "required": ["a"], "properties": {"a":
+		{"type": "object", "required": ["b"], "properties": {"b":
+			{"type": "object", "required": ["c"], "properties": {"c":
+				{"type": "array", "minItems": 1, "items": {"type": "object", "required": ["leaf"], "properties": {"leaf": {"type": "string"}}}}}}}}}}`)
+	for _, maxDepth := range []int{0, 1} {
+		opts := NewGenerationOptions()
+		opts.MaxDepth = maxDepth
+		gen := opts.GenFromSchema(chain)
+		rapid.Check(t, func(rt *rapid.T) {
+			payload := gen.Draw(rt, "chain")
+			assert.True(t, validAgainst(chain, payload), string(payload))
+		})
+	}
+}
+
+func TestRequiredRecursionFails(t *testing.T) {
+	// required recursion has no finite value, however deep the cutoff
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: recursive
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Node:
+      type: object
+      required: [next]
+      properties:
+        next:
+          $ref: '#/components/schemas/Node'
+    Nested:
+      type: array
+      minItems: 1
+      items:
+        $ref: '#/components/schemas/Nested'
+`)
+	for name, want := range map[string]string{
+		"Node":   "the required properties of the object at '/components/schemas/Node' contain the object again",
+		"Nested": "the minItems items of the array at '/components/schemas/Nested' contain the array again",
+	} {
+		func() {
+			defer func() {
+				assert.Contains(t, fmt.Sprint(recover()), want, name)
+			}()
+			GenFromSchema(doc.Components.Schemas[name].Value).Example(0)
+		}()
+	}
+}
+
+func TestMixedTypeEnum(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: mixed enum
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Mixed:
+      enum: [1, "one", null, true, 2.5, {"a": [1]}]
+`)
+	schema := doc.Components.Schemas["Mixed"].Value
+	members := []string{`1`, `"one"`, `null`, `true`, `2.5`, `{"a":[1]}`}
+	gen := GenFromSchema(schema)
+
+	seen := make(map[string]bool)
+	rapid.Check(t, func(rt *rapid.T) {
+		value := string(gen.Draw(rt, "value"))
+		assert.Contains(t, members, value)
+		seen[value] = true
+	})
+	assert.Len(t, seen, len(members))
+	assert.ElementsMatch(t, members, toStrings(GenCoverageSuite(schema)))
+}
+
+func toStrings(values []json.RawMessage) []string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = string(v)
+	}
+	return strs
+}
+
+func TestNestedFormats(t *testing.T) {
+	schema := mustSchema(t, `{
+		"type": "object",
+		"required": ["a"],
+		"properties": {
+			"a": {
+				"type": "array",
+				"minItems": 1,
+				"items": {
+					"type": "object",
+					"required": ["b"],
+					"properties": {
+						"b": {
+							"type": "object",
+							"required": ["id", "ids"],
+							"properties": {
+								"id": {"type": "string", "format": "uuid"},
+								"ids": {"type": "array", "minItems": 1, "items": {"type": "string", "format": "uuid"}}
+							}
+						}
+					}
+				}
+			}
+		}
+	}`)
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	type payload struct {
+		A []struct {
+			B struct {
+				ID  string   `json:"id"`
+				IDs []string `json:"ids"`
+			} `json:"b"`
+		} `json:"a"`
+	}
+
+	// formats apply below the depth cutoff as well, where only required values are generated
+	for _, maxDepth := range []int{10, 2} {
+		opts := NewGenerationOptions()
+		opts.MaxDepth = maxDepth
+		gen := opts.GenFromSchema(schema)
+
+		rapid.Check(t, func(rt *rapid.T) {
+			var p payload
+			require.NoError(t, json.Unmarshal(gen.Draw(rt, "payload"), &p))
+			require.NotEmpty(t, p.A)
+			for _, item := range p.A {
+				assert.Regexp(t, uuidPattern, item.B.ID)
+				require.NotEmpty(t, item.B.IDs)
+				for _, id := range item.B.IDs {
+					assert.Regexp(t, uuidPattern, id)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownFormatPolicy(t *testing.T) {
+	schema := mustSchema(t, `{"type": "object", "required": ["a", "b", "c"], "properties": {
+		"a": {"type": "string", "format": "x-currency"},
+		"b": {"type": "string", "format": "x-currency"},
+		"c": {"type": "string", "format": "password"}
+	}}`)
+
+	ignored := NewGenerationOptions().GenFromSchema(schema)
+	assert.NotPanics(t, func() { ignored.Example(0) })
+
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	warned := NewGenerationOptions().WithUnknownFormatPolicy(UnknownFormatWarn).GenFromSchema(schema)
+	for i := range 10 {
+		warned.Example(i)
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "format 'x-currency'"), logs.String())
+	assert.Contains(t, logs.String(), "format 'x-currency' at '/properties/a' is unknown")
+	assert.NotContains(t, logs.String(), "password")
+
+	failing := NewGenerationOptions().WithUnknownFormatPolicy(UnknownFormatError).GenFromSchema(schema)
+	defer func() {
+		assert.Contains(t, fmt.Sprint(recover()), "format 'x-currency' at '/properties/a' is unknown")
+	}()
+	failing.Example(0)
+}
+
+func TestFormatDrawersKnownToAnalysis(t *testing.T) {
+	// every format we generate on strings is one Analyze accepts on strings
+	for format := range formatDrawers {
+		assert.Contains(t, formatTypes[format], "string", format)
+	}
+}
+
+func TestReadSpecUnknownKeywords(t *testing.T) {
+	spec := `
+openapi: 3.0.3
+info:
+  title: keywords
+  version: 1.0.0
+paths:
+  /pets:
+    post:
+      audience: internal
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $comment: pets are animals
+              type: object
+              deprecated: true
+              x-owner: team-a
+              properties:
+                name:
+                  type: string
+                  $comment: the pet's name
+                  sensitivity: low
+      responses:
+        '200':
+          description: ok
+`
+	// $comment is always accepted
+	_, err := ReadSpecFromReader(strings.NewReader(strings.ReplaceAll(strings.ReplaceAll(spec,
+		"audience: internal", ""), "sensitivity: low", "")))
+	require.NoError(t, err)
+
+	_, err = ReadSpecFromReader(strings.NewReader(spec))
+	assert.ErrorContains(t, err, "extra sibling fields")
+
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	doc, err := ReadSpecFromReader(strings.NewReader(spec), WithLenientValidation())
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), "audience")
+	assert.Contains(t, logs.String(), "sensitivity")
+
+	schema, ok := GetSchema(doc.Paths.Find("/pets").Post)
+	require.True(t, ok)
+	assert.NoError(t, schema.Value.VisitJSON(decode(t, GenFromSchema(schema.Value).Example(0))))
+
+	// other errors still fail
+	_, err = ReadSpecFromReader(strings.NewReader(strings.Replace(spec, "type: object", "type: objekt", 1)), WithLenientValidation())
+	assert.Error(t, err)
+}
+
+func decode(t *testing.T, payload []byte) any {
+	var v any
+	require.NoError(t, json.Unmarshal(payload, &v))
+	return v
+}
+
+func TestNumberFormatDecimal(t *testing.T) {
+	opts := NewGenerationOptions().WithNumberFormat(NumberFormatDecimal)
+	gens := []*rapid.Generator[json.RawMessage]{
+		opts.GenFromSchema(mustSchema(t, `{"type": "number"}`)),
+		opts.GenFromSchema(mustSchema(t, `{"type": "number", "minimum": 1e21}`)),
+		opts.GenFromSchema(mustSchema(t, `{"type": "number", "minimum": 0, "maximum": 1e-7}`)),
+		opts.GenFromSchema(mustSchema(t, `{"type": "number", "minimum": 1e25, "multipleOf": 1e20}`)),
+	}
+
+	rapid.Check(t, func(rt *rapid.T) {
+		for i, gen := range gens {
+			payload := string(gen.Draw(rt, strconv.Itoa(i)))
+			assert.NotContains(t, strings.ToLower(payload), "e", payload)
+			_, err := strconv.ParseFloat(payload, 64)
+			assert.NoError(t, err)
+			assert.True(t, json.Valid([]byte(payload)), payload)
+		}
+	})
+
+	assert.Equal(t, "1e+21", string(NewGenerationOptions().marshalNumber(1e21)))
+	assert.Equal(t, "1000000000000000000000", string(opts.marshalNumber(1e21)))
+	assert.Equal(t, "0.00000001", string(opts.marshalNumber(1e-8)))
+}
+
+func TestDecimalMultipleOf(t *testing.T) {
+	schema := mustSchema(t, `{"type": "number", "minimum": 0, "maximum": 1000, "multipleOf": 0.01}`)
+	for _, opts := range []*GenerationOptions{
+		NewGenerationOptions(),
+		NewGenerationOptions().WithNumberFormat(NumberFormatDecimal),
+	} {
+		gen := opts.GenFromSchema(schema)
+		rapid.Check(t, func(rt *rapid.T) {
+			payload := string(gen.Draw(rt, "price"))
+			require.Regexp(t, `^(0|[1-9][0-9]*)(\.[0-9]{1,2})?$`, payload)
+			v, err := strconv.ParseFloat(payload, 64)
+			require.NoError(t, err)
+			require.True(t, v >= 0 && v <= 1000, payload)
+		})
+	}
+
+	opts := NewGenerationOptions()
+	assert.Equal(t, "19.99", string(opts.marshalMultiple(1999, 0.01, 0, 1000)))
+	assert.Equal(t, "0.01", string(opts.marshalMultiple(1, 0.01, 0, 1000)))
+	assert.Equal(t, "1000", string(opts.marshalMultiple(100000, 0.01, 0, 1000)))
+	assert.Equal(t, "0.3", string(opts.marshalMultiple(3, 0.1, 0, 1)))
+}
+
+func TestPrecisionStress(t *testing.T) {
+	schema := mustSchema(t, `{"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 1e16}`)
+	gen := NewGenerationOptions().WithPrecisionStress(true).GenFromSchema(schema)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		payload := gen.Example(i)
+		seen[string(payload)] = true
+		var v float64
+		require.NoError(t, json.Unmarshal(payload, &v))
+		require.NoError(t, schema.VisitJSON(v))
+	}
+	for _, want := range []string{"0.30000000000000004", "9007199254740992", "5e-324"} {
+		assert.True(t, seen[want], "%s was never generated", want)
+	}
+
+	// values outside the bounds are left out
+	for _, v := range precisionStressValues(1, 2) {
+		assert.True(t, v >= 1 && v <= 2, v)
+	}
+}
+
+func TestPolymorphicArray(t *testing.T) {
+	doc := readSpecString(t, `
+openapi: 3.0.3
+info:
+  title: polymorphic
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+    Pets:
+      type: array
+      minItems: 2
+      maxItems: 6
+      items:
+        $ref: '#/components/schemas/Pet'
+    Pet:
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+        - $ref: '#/components/schemas/Dog'
+      discriminator:
+        propertyName: petType
+        mapping:
+          cat: '#/components/schemas/Cat'
+          dog: '#/components/schemas/Dog'
+    Cat:
+      type: object
+      required: [petType, lives]
+      additionalProperties: false
+      properties:
+        petType:
+          type: string
+        lives:
+          type: integer
+    Dog:
+      type: object
+      required: [petType, bark]
+      additionalProperties: false
+      properties:
+        petType:
+          type: string
+        bark:
+          type: boolean
+    Animal:
+      oneOf:
+        - $ref: '#/components/schemas/Cat'
+        - $ref: '#/components/schemas/Dog'
+      discriminator:
+        propertyName: petType
+`)
+	pets := doc.Components.Schemas["Pets"].Value
+	gen := GenFromSchema(pets)
+
+	mixed := false
+	for i := 0; i < 50; i++ {
+		payload := gen.Example(i)
+		require.True(t, validAgainst(pets, payload), "%s", payload)
+		var arr []map[string]any
+		require.NoError(t, json.Unmarshal(payload, &arr))
+		types := make(map[any]bool)
+		for _, pet := range arr {
+			_, isCat := pet["lives"]
+			assert.Equal(t, map[bool]string{true: "cat", false: "dog"}[isCat], pet["petType"], pet)
+			types[pet["petType"]] = true
+		}
+		mixed = mixed || len(types) > 1
+	}
+	assert.True(t, mixed, "no array mixed cats and dogs")
+
+	// without a mapping, the discriminator value is the referenced schema's name
+	animal := GenFromSchema(doc.Components.Schemas["Animal"].Value)
+	rapid.Check(t, func(rt *rapid.T) {
+		var obj map[string]any
+		require.NoError(t, json.Unmarshal(animal.Draw(rt, "animal"), &obj))
+		_, isCat := obj["lives"]
+		assert.Equal(t, map[bool]string{true: "Cat", false: "Dog"}[isCat], obj["petType"])
+	})
+}
@@ -0,0 +1,104 @@
+package SpecSmash
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
+)
+
+// documentKeywords are top-level fields of OpenAPI 3.1 documents that kin-openapi
+// does not know. SpecSmash loads them itself.
+var documentKeywords = []string{"webhooks"}
+
+// Webhooks returns the path items of the spec's OpenAPI 3.1 webhooks by webhook
+// name, as loaded by ReadSpec. Their $refs are resolved like those of paths.
+func Webhooks(doc *openapi3.T) map[string]*openapi3.PathItem {
+	webhooks, _ := doc.Extensions["webhooks"].(map[string]*openapi3.PathItem)
+	return webhooks
+}
+
+// locatedPathItem is a path item with its JSON pointer in the document
+type locatedPathItem struct {
+	pointer string
+	item    *openapi3.PathItem
+}
+
+// pathItems returns the path items of doc's paths, in matching order, followed by
+// those of its webhooks, by name. Webhooks are located under /webhooks/<name>, not
+// under the paths they are loaded as.
+func pathItems(doc *openapi3.T) []locatedPathItem {
+	var items []locatedPathItem
+	if doc.Paths != nil {
+		for _, p := range doc.Paths.InMatchingOrder() {
+			items = append(items, locatedPathItem{"/paths/" + escapePointer(p), doc.Paths.Value(p)})
+		}
+	}
+	webhooks := Webhooks(doc)
+	for _, name := range sortedKeys(webhooks) {
+		items = append(items, locatedPathItem{"/webhooks/" + escapePointer(name), webhooks[name]})
+	}
+	return items
+}
+
+// loadWebhooks loads the webhooks of data, the source of doc, and stores them in
+// doc's extensions for Webhooks. kin-openapi only loads paths, so the webhooks
+// are loaded as the paths of a copy of the document, with a / before each name.
+func loadWebhooks(data []byte, doc *openapi3.T, readOpts readOptions) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	top := root.Content[0]
+
+	var webhooks *yaml.Node
+	var fields []*yaml.Node
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		key, value := top.Content[i], top.Content[i+1]
+		switch key.Value {
+		case "webhooks":
+			webhooks = value
+		case "paths":
+			// replaced by the webhooks
+		default:
+			fields = append(fields, key, value)
+		}
+	}
+	if webhooks == nil || webhooks.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	paths := &yaml.Node{Kind: yaml.MappingNode}
+	names := make(map[string]string)
+	for i := 0; i+1 < len(webhooks.Content); i += 2 {
+		name := webhooks.Content[i].Value
+		names["/"+name] = name
+		paths.Content = append(paths.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "/" + name}, webhooks.Content[i+1])
+	}
+	top.Content = append(fields, &yaml.Node{Kind: yaml.ScalarNode, Value: "paths"}, paths)
+
+	webhookData, err := yaml.Marshal(&root)
+	if err != nil {
+		return err
+	}
+	webhookDoc, err := loadSpec(webhookData, readOpts)
+	if err != nil {
+		// name the webhooks rather than the paths they were loaded as
+		msg := strings.Replace(err.Error(), "invalid paths: ", "", 1)
+		for _, path := range sortedKeys(names) {
+			msg = strings.ReplaceAll(msg, "invalid path "+path+":", "invalid webhook "+names[path]+":")
+		}
+		return errors.New("webhooks: " + msg)
+	}
+
+	items := make(map[string]*openapi3.PathItem)
+	for path, item := range webhookDoc.Paths.Map() {
+		items[names[path]] = item
+	}
+	if doc.Extensions == nil {
+		doc.Extensions = make(map[string]any)
+	}
+	doc.Extensions["webhooks"] = items
+	return nil
+}
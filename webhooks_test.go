@@ -0,0 +1,101 @@
+package SpecSmash
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestWebhooks(t *testing.T) {
+	doc, err := ReadSpec("testdata/openapi_webhooks.yaml")
+	require.NoError(t, err)
+
+	webhooks := Webhooks(doc)
+	require.Len(t, webhooks, 2)
+	assert.NotContains(t, doc.Paths.Map(), "/orderPlaced")
+
+	schema, ok := GetSchema(webhooks["orderPlaced"].Post)
+	require.True(t, ok)
+	require.NotNil(t, schema.Value, "the $ref into components is resolved")
+	assert.Contains(t, schema.Value.Properties, "items")
+}
+
+func TestWebhooksAbsent(t *testing.T) {
+	doc, err := ReadSpec("testdata/openapi_simple.yaml")
+	require.NoError(t, err)
+	assert.Empty(t, Webhooks(doc))
+}
+
+func TestWebhooksInvalid(t *testing.T) {
+	_, err := ReadSpecFromReader(strings.NewReader(`
+openapi: 3.1.0
+info:
+  title: invalid webhook
+  version: 1.0.0
+paths: {}
+webhooks:
+  broken:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              minimum: 1
+              unknownKeyword: true
+`))
+	assert.ErrorContains(t, err, "webhooks: kin-openapi validate errors: invalid webhook broken: invalid operation POST")
+}
+
+func TestWebhookPointers(t *testing.T) {
+	doc, err := ReadSpecFromReader(strings.NewReader(`
+openapi: 3.1.0
+info:
+  title: webhook pointers
+  version: 1.0.0
+paths: {}
+webhooks:
+  orderPlaced:
+    post:
+      operationId: orderPlaced
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [id, contact]
+              properties:
+                id:
+                  type: integer
+                  example: none
+                contact:
+                  type: integer
+                  format: email
+      responses:
+        '200':
+          description: received
+`))
+	require.NoError(t, err)
+	body := "/webhooks/orderPlaced/post/requestBody/content/application~1json/schema"
+
+	// AnalyzeSpec, ValidateExamples and overrides share the webhook's pointers
+	var pointers []string
+	for _, issue := range AnalyzeSpec(doc) {
+		pointers = append(pointers, issue.Pointer)
+	}
+	assert.Contains(t, pointers, body+"/properties/contact")
+
+	errs := ValidateExamples(doc)
+	require.Len(t, errs, 1)
+	assert.True(t, strings.HasPrefix(errs[0].Error(), body+"/properties/id/example:"), errs[0].Error())
+
+	gen, err := NewGenerationOptions().
+		WithOverride(body+"/properties/id", rapid.Just(json.RawMessage(`7`))).
+		GenForOperationID(doc, "orderPlaced")
+	require.NoError(t, err)
+	assert.Contains(t, string(gen.Example(0)), `"id":7`)
+}